@@ -19,7 +19,7 @@ func (lc *LukatinCode) RegisterAllFunction() {
 		return
 	}
 
-	var functionDescs map[string]FunctionDescription
+	var functionDescs map[string]function.FunctionDescription
 	err = json.Unmarshal(data, &functionDescs)
 	if err != nil {
 		lc.Logger.Printf("解析函数描述文件失败: %v", err)
@@ -52,7 +52,7 @@ func (lc *LukatinCode) RegisterAllFunction() {
 				paramDescs = append(paramDescs, paramInfo.Description)
 			}
 		}
-		err := lc.CM.RegisterFunction("Bash", desc.Description, lc.Bash, paramNames, paramDescs)
+		err := lc.CM.RegisterFunction("Bash", desc.Description, lc.guardTool("Bash", lc.Bash), paramNames, paramDescs)
 		if err != nil {
 			lc.Logger.Printf("注册Bash函数失败: %v", err)
 			fmt.Printf("注册Bash函数失败: %v\n", err)
@@ -63,7 +63,7 @@ func (lc *LukatinCode) RegisterAllFunction() {
 
 	// 注册 TodoRead 函数
 	if desc, ok := functionDescs["TodoRead"]; ok {
-		err := lc.CM.RegisterFunction("TodoRead", desc.Description, function.TodoRead, []string{}, []string{})
+		err := lc.CM.RegisterFunction("TodoRead", desc.Description, lc.guardTool("TodoRead", function.TodoRead), []string{}, []string{})
 		if err != nil {
 			lc.Logger.Printf("注册TodoRead函数失败: %v", err)
 			fmt.Printf("注册TodoRead函数失败: %v\n", err)
@@ -83,7 +83,7 @@ func (lc *LukatinCode) RegisterAllFunction() {
 				paramDescs = append(paramDescs, paramInfo.Description)
 			}
 		}
-		err := lc.CM.RegisterFunction("TodoWrite", desc.Description, function.TodoWrite, paramNames, paramDescs)
+		err := lc.CM.RegisterFunction("TodoWrite", desc.Description, lc.guardTool("TodoWrite", function.TodoWrite), paramNames, paramDescs)
 		if err != nil {
 			lc.Logger.Printf("注册TodoWrite函数失败: %v", err)
 			fmt.Printf("注册TodoWrite函数失败: %v\n", err)
@@ -95,29 +95,8 @@ func (lc *LukatinCode) RegisterAllFunction() {
 
 	// 注册 Grep 函数
 	if desc, ok := functionDescs["Grep"]; ok {
-		var paramNames []string
-		var paramDescs []string
-		for _, param := range desc.Parameters.Required {
-			if paramInfo, exists := desc.Parameters.Properties[param]; exists {
-				paramNames = append(paramNames, param)
-				paramDescs = append(paramDescs, paramInfo.Description)
-			}
-		}
-		// 添加可选参数
-		for paramName, paramInfo := range desc.Parameters.Properties {
-			isRequired := false
-			for _, req := range desc.Parameters.Required {
-				if req == paramName {
-					isRequired = true
-					break
-				}
-			}
-			if !isRequired {
-				paramNames = append(paramNames, paramName)
-				paramDescs = append(paramDescs, paramInfo.Description)
-			}
-		}
-		err := lc.CM.RegisterFunction("Grep", desc.Description, function.Grep, paramNames, paramDescs)
+		paramNames, paramDescs := desc.OrderedParams(function.GrepParams...)
+		err := lc.CM.RegisterFunction("Grep", desc.Description, lc.guardTool("Grep", function.Grep), paramNames, paramDescs)
 		if err != nil {
 			lc.Logger.Printf("注册Grep函数失败: %v", err)
 			fmt.Printf("注册Grep函数失败: %v\n", err)
@@ -129,29 +108,8 @@ func (lc *LukatinCode) RegisterAllFunction() {
 
 	// 注册 Glob 函数
 	if desc, ok := functionDescs["Glob"]; ok {
-		var paramNames []string
-		var paramDescs []string
-		for _, param := range desc.Parameters.Required {
-			if paramInfo, exists := desc.Parameters.Properties[param]; exists {
-				paramNames = append(paramNames, param)
-				paramDescs = append(paramDescs, paramInfo.Description)
-			}
-		}
-		// 添加可选参数
-		for paramName, paramInfo := range desc.Parameters.Properties {
-			isRequired := false
-			for _, req := range desc.Parameters.Required {
-				if req == paramName {
-					isRequired = true
-					break
-				}
-			}
-			if !isRequired {
-				paramNames = append(paramNames, paramName)
-				paramDescs = append(paramDescs, paramInfo.Description)
-			}
-		}
-		err := lc.CM.RegisterFunction("Glob", desc.Description, function.Glob, paramNames, paramDescs)
+		paramNames, paramDescs := desc.OrderedParams(function.GlobParams...)
+		err := lc.CM.RegisterFunction("Glob", desc.Description, lc.guardTool("Glob", function.Glob), paramNames, paramDescs)
 		if err != nil {
 			lc.Logger.Printf("注册Glob函数失败: %v", err)
 			fmt.Printf("注册Glob函数失败: %v\n", err)
@@ -163,15 +121,13 @@ func (lc *LukatinCode) RegisterAllFunction() {
 
 	// 注册 Task 函数
 	if desc, ok := functionDescs["Task"]; ok {
-		var paramNames []string
-		var paramDescs []string
-		for _, param := range desc.Parameters.Required {
-			if paramInfo, exists := desc.Parameters.Properties[param]; exists {
-				paramNames = append(paramNames, param)
-				paramDescs = append(paramDescs, paramInfo.Description)
-			}
+		paramNames, paramDescs := desc.OrderedParams(function.TaskParams...)
+		// 工具描述中列出 .lukatin/agents 中定义的代理类型
+		_, agentErrs := function.LoadAgentDefinitions()
+		for _, agentErr := range agentErrs {
+			lc.Logger.Printf("加载代理定义失败: %v", agentErr)
 		}
-		err := lc.CM.RegisterFunction("Task", desc.Description, function.Task, paramNames, paramDescs)
+		err := lc.CM.RegisterFunction("Task", function.TaskDescription(desc.Description), lc.guardTool("Task", lc.task), paramNames, paramDescs)
 		if err != nil {
 			lc.Logger.Printf("注册Task函数失败: %v", err)
 			fmt.Printf("注册Task函数失败: %v\n", err)
@@ -183,29 +139,8 @@ func (lc *LukatinCode) RegisterAllFunction() {
 
 	// 注册 LS 函数
 	if desc, ok := functionDescs["LS"]; ok {
-		var paramNames []string
-		var paramDescs []string
-		for _, param := range desc.Parameters.Required {
-			if paramInfo, exists := desc.Parameters.Properties[param]; exists {
-				paramNames = append(paramNames, param)
-				paramDescs = append(paramDescs, paramInfo.Description)
-			}
-		}
-		// 添加可选参数
-		for paramName, paramInfo := range desc.Parameters.Properties {
-			isRequired := false
-			for _, req := range desc.Parameters.Required {
-				if req == paramName {
-					isRequired = true
-					break
-				}
-			}
-			if !isRequired {
-				paramNames = append(paramNames, paramName)
-				paramDescs = append(paramDescs, paramInfo.Description)
-			}
-		}
-		err := lc.CM.RegisterFunction("LS", desc.Description, function.LS, paramNames, paramDescs)
+		paramNames, paramDescs := desc.OrderedParams(function.LSParams...)
+		err := lc.CM.RegisterFunction("LS", desc.Description, lc.guardTool("LS", function.LS), paramNames, paramDescs)
 		if err != nil {
 			lc.Logger.Printf("注册LS函数失败: %v", err)
 			fmt.Printf("注册LS函数失败: %v\n", err)
@@ -215,6 +150,54 @@ func (lc *LukatinCode) RegisterAllFunction() {
 		}
 	}
 
+	// 注册 FindDefinition 函数
+	if desc, ok := functionDescs["FindDefinition"]; ok {
+		paramNames, paramDescs := desc.OrderedParams(function.SymbolParams...)
+		err := lc.CM.RegisterFunction("FindDefinition", desc.Description, lc.guardTool("FindDefinition", function.FindDefinition), paramNames, paramDescs)
+		if err != nil {
+			lc.Logger.Printf("注册FindDefinition函数失败: %v", err)
+			fmt.Printf("注册FindDefinition函数失败: %v\n", err)
+		} else {
+			lc.Logger.Println("成功注册FindDefinition函数")
+		}
+	}
+
+	// 注册 FindReferences 函数
+	if desc, ok := functionDescs["FindReferences"]; ok {
+		paramNames, paramDescs := desc.OrderedParams(function.SymbolParams...)
+		err := lc.CM.RegisterFunction("FindReferences", desc.Description, lc.guardTool("FindReferences", function.FindReferences), paramNames, paramDescs)
+		if err != nil {
+			lc.Logger.Printf("注册FindReferences函数失败: %v", err)
+			fmt.Printf("注册FindReferences函数失败: %v\n", err)
+		} else {
+			lc.Logger.Println("成功注册FindReferences函数")
+		}
+	}
+
+	// 注册 ListSymbols 函数
+	if desc, ok := functionDescs["ListSymbols"]; ok {
+		paramNames, paramDescs := desc.OrderedParams(function.ListSymbolsParams...)
+		err := lc.CM.RegisterFunction("ListSymbols", desc.Description, lc.guardTool("ListSymbols", function.ListSymbols), paramNames, paramDescs)
+		if err != nil {
+			lc.Logger.Printf("注册ListSymbols函数失败: %v", err)
+			fmt.Printf("注册ListSymbols函数失败: %v\n", err)
+		} else {
+			lc.Logger.Println("成功注册ListSymbols函数")
+		}
+	}
+
+	// 注册 CodeSearch 函数
+	if desc, ok := functionDescs["CodeSearch"]; ok {
+		paramNames, paramDescs := desc.OrderedParams(function.CodeSearchParams...)
+		err := lc.CM.RegisterFunction("CodeSearch", desc.Description, lc.guardTool("CodeSearch", function.CodeSearch), paramNames, paramDescs)
+		if err != nil {
+			lc.Logger.Printf("注册CodeSearch函数失败: %v", err)
+			fmt.Printf("注册CodeSearch函数失败: %v\n", err)
+		} else {
+			lc.Logger.Println("成功注册CodeSearch函数")
+		}
+	}
+
 	// 注册 Read 函数
 	if desc, ok := functionDescs["Read"]; ok {
 		var paramNames []string
@@ -239,7 +222,7 @@ func (lc *LukatinCode) RegisterAllFunction() {
 				paramDescs = append(paramDescs, paramInfo.Description)
 			}
 		}
-		err := lc.CM.RegisterFunction("Read", desc.Description, function.Read, paramNames, paramDescs)
+		err := lc.CM.RegisterFunction("Read", desc.Description, lc.guardTool("Read", function.Read), paramNames, paramDescs)
 		if err != nil {
 			lc.Logger.Printf("注册Read函数失败: %v", err)
 			fmt.Printf("注册Read函数失败: %v\n", err)
@@ -273,7 +256,7 @@ func (lc *LukatinCode) RegisterAllFunction() {
 				paramDescs = append(paramDescs, paramInfo.Description)
 			}
 		}
-		err := lc.CM.RegisterFunction("Edit", desc.Description, lc.Editor, paramNames, paramDescs)
+		err := lc.CM.RegisterFunction("Edit", desc.Description, lc.guardTool("Edit", lc.Editor), paramNames, paramDescs)
 		if err != nil {
 			lc.Logger.Printf("注册Edit函数失败: %v", err)
 			fmt.Printf("注册Edit函数失败: %v\n", err)
@@ -293,7 +276,7 @@ func (lc *LukatinCode) RegisterAllFunction() {
 				paramDescs = append(paramDescs, paramInfo.Description)
 			}
 		}
-		err := lc.CM.RegisterFunction("MultiEdit", desc.Description, function.MultiEdit, paramNames, paramDescs)
+		err := lc.CM.RegisterFunction("MultiEdit", desc.Description, lc.guardTool("MultiEdit", function.MultiEdit), paramNames, paramDescs)
 		if err != nil {
 			lc.Logger.Printf("注册MultiEdit函数失败: %v", err)
 			fmt.Printf("注册MultiEdit函数失败: %v\n", err)
@@ -313,7 +296,7 @@ func (lc *LukatinCode) RegisterAllFunction() {
 				paramDescs = append(paramDescs, paramInfo.Description)
 			}
 		}
-		err := lc.CM.RegisterFunction("Write", desc.Description, lc.Writer, paramNames, paramDescs)
+		err := lc.CM.RegisterFunction("Write", desc.Description, lc.guardTool("Write", lc.Writer), paramNames, paramDescs)
 		if err != nil {
 			lc.Logger.Printf("注册Write函数失败: %v", err)
 			fmt.Printf("注册Write函数失败: %v\n", err)
@@ -323,6 +306,123 @@ func (lc *LukatinCode) RegisterAllFunction() {
 		}
 	}
 
+	// 注册 ApplyPatch 函数
+	if desc, ok := functionDescs["ApplyPatch"]; ok {
+		var paramNames []string
+		var paramDescs []string
+		for _, param := range desc.Parameters.Required {
+			if paramInfo, exists := desc.Parameters.Properties[param]; exists {
+				paramNames = append(paramNames, param)
+				paramDescs = append(paramDescs, paramInfo.Description)
+			}
+		}
+		err := lc.CM.RegisterFunction("ApplyPatch", desc.Description, lc.guardTool("ApplyPatch", lc.PatchApplier), paramNames, paramDescs)
+		if err != nil {
+			lc.Logger.Printf("注册ApplyPatch函数失败: %v", err)
+			fmt.Printf("注册ApplyPatch函数失败: %v\n", err)
+		} else {
+			lc.Logger.Println("成功注册ApplyPatch函数")
+		}
+	}
+
+	// 注册 MultiFileEdit 函数
+	if desc, ok := functionDescs["MultiFileEdit"]; ok {
+		var paramNames []string
+		var paramDescs []string
+		for _, param := range desc.Parameters.Required {
+			if paramInfo, exists := desc.Parameters.Properties[param]; exists {
+				paramNames = append(paramNames, param)
+				paramDescs = append(paramDescs, paramInfo.Description)
+			}
+		}
+		err := lc.CM.RegisterFunction("MultiFileEdit", desc.Description, lc.guardTool("MultiFileEdit", lc.MultiFileEditor), paramNames, paramDescs)
+		if err != nil {
+			lc.Logger.Printf("注册MultiFileEdit函数失败: %v", err)
+			fmt.Printf("注册MultiFileEdit函数失败: %v\n", err)
+		} else {
+			lc.Logger.Println("成功注册MultiFileEdit函数")
+		}
+	}
+
+	// 注册 RenameSymbol 函数（带UI确认）
+	if desc, ok := functionDescs["RenameSymbol"]; ok {
+		paramNames, paramDescs := desc.OrderedParams(function.RenameParams...)
+		err := lc.CM.RegisterFunction("RenameSymbol", desc.Description, lc.guardTool("RenameSymbol", lc.Renamer), paramNames, paramDescs)
+		if err != nil {
+			lc.Logger.Printf("注册RenameSymbol函数失败: %v", err)
+			fmt.Printf("注册RenameSymbol函数失败: %v\n", err)
+		} else {
+			lc.Logger.Println("成功注册RenameSymbol函数")
+		}
+	}
+
+	// 注册 Move 函数
+	if desc, ok := functionDescs["Move"]; ok {
+		paramNames, paramDescs := desc.OrderedParams(function.MoveParams...)
+		err := lc.CM.RegisterFunction("Move", desc.Description, lc.guardTool("Move", lc.Mover), paramNames, paramDescs)
+		if err != nil {
+			lc.Logger.Printf("注册Move函数失败: %v", err)
+			fmt.Printf("注册Move函数失败: %v\n", err)
+		} else {
+			lc.Logger.Println("成功注册Move函数")
+		}
+	}
+
+	// 注册 Copy 函数
+	if desc, ok := functionDescs["Copy"]; ok {
+		paramNames, paramDescs := desc.OrderedParams(function.CopyParams...)
+		err := lc.CM.RegisterFunction("Copy", desc.Description, lc.guardTool("Copy", lc.Copier), paramNames, paramDescs)
+		if err != nil {
+			lc.Logger.Printf("注册Copy函数失败: %v", err)
+			fmt.Printf("注册Copy函数失败: %v\n", err)
+		} else {
+			lc.Logger.Println("成功注册Copy函数")
+		}
+	}
+
+	// 注册 Delete 函数
+	if desc, ok := functionDescs["Delete"]; ok {
+		paramNames, paramDescs := desc.OrderedParams(function.DeleteParams...)
+		err := lc.CM.RegisterFunction("Delete", desc.Description, lc.guardTool("Delete", lc.Deleter), paramNames, paramDescs)
+		if err != nil {
+			lc.Logger.Printf("注册Delete函数失败: %v", err)
+			fmt.Printf("注册Delete函数失败: %v\n", err)
+		} else {
+			lc.Logger.Println("成功注册Delete函数")
+		}
+	}
+
+	// 注册 NotebookRead 函数
+	if desc, ok := functionDescs["NotebookRead"]; ok {
+		var paramNames []string
+		var paramDescs []string
+		for _, param := range desc.Parameters.Required {
+			if paramInfo, exists := desc.Parameters.Properties[param]; exists {
+				paramNames = append(paramNames, param)
+				paramDescs = append(paramDescs, paramInfo.Description)
+			}
+		}
+		err := lc.CM.RegisterFunction("NotebookRead", desc.Description, lc.guardTool("NotebookRead", function.NotebookRead), paramNames, paramDescs)
+		if err != nil {
+			lc.Logger.Printf("注册NotebookRead函数失败: %v", err)
+			fmt.Printf("注册NotebookRead函数失败: %v\n", err)
+		} else {
+			lc.Logger.Println("成功注册NotebookRead函数")
+		}
+	}
+
+	// 注册 NotebookEdit 函数
+	if desc, ok := functionDescs["NotebookEdit"]; ok {
+		paramNames, paramDescs := desc.OrderedParams(function.NotebookEditParams...)
+		err := lc.CM.RegisterFunction("NotebookEdit", desc.Description, lc.guardTool("NotebookEdit", lc.NotebookEditor), paramNames, paramDescs)
+		if err != nil {
+			lc.Logger.Printf("注册NotebookEdit函数失败: %v", err)
+			fmt.Printf("注册NotebookEdit函数失败: %v\n", err)
+		} else {
+			lc.Logger.Println("成功注册NotebookEdit函数")
+		}
+	}
+
 	// 注册 WebFetch 函数
 	if desc, ok := functionDescs["WebFetch"]; ok {
 		var paramNames []string
@@ -333,7 +433,7 @@ func (lc *LukatinCode) RegisterAllFunction() {
 				paramDescs = append(paramDescs, paramInfo.Description)
 			}
 		}
-		err := lc.CM.RegisterFunction("WebFetch", desc.Description, function.WebFetch, paramNames, paramDescs)
+		err := lc.CM.RegisterFunction("WebFetch", desc.Description, lc.guardTool("WebFetch", function.WebFetch), paramNames, paramDescs)
 		if err != nil {
 			lc.Logger.Printf("注册WebFetch函数失败: %v", err)
 			fmt.Printf("注册WebFetch函数失败: %v\n", err)
@@ -367,7 +467,7 @@ func (lc *LukatinCode) RegisterAllFunction() {
 				paramDescs = append(paramDescs, paramInfo.Description)
 			}
 		}
-		err := lc.CM.RegisterFunction("WebSearch", desc.Description, function.WebSearch, paramNames, paramDescs)
+		err := lc.CM.RegisterFunction("WebSearch", desc.Description, lc.guardTool("WebSearch", function.WebSearch), paramNames, paramDescs)
 		if err != nil {
 			lc.Logger.Printf("注册WebSearch函数失败: %v", err)
 			fmt.Printf("注册WebSearch函数失败: %v\n", err)
@@ -377,6 +477,24 @@ func (lc *LukatinCode) RegisterAllFunction() {
 		}
 	}
 
+	// 注册 ExitPlanMode 函数
+	if desc, ok := functionDescs["ExitPlanMode"]; ok {
+		var paramNames []string
+		var paramDescs []string
+		for _, param := range desc.Parameters.Required {
+			if paramInfo, exists := desc.Parameters.Properties[param]; exists {
+				paramNames = append(paramNames, param)
+				paramDescs = append(paramDescs, paramInfo.Description)
+			}
+		}
+		err := lc.CM.RegisterFunction("ExitPlanMode", desc.Description, lc.guardTool("ExitPlanMode", lc.ExitPlanMode), paramNames, paramDescs)
+		if err != nil {
+			lc.Logger.Printf("注册ExitPlanMode函数失败: %v", err)
+			fmt.Printf("注册ExitPlanMode函数失败: %v\n", err)
+		} else {
+			lc.Logger.Println("成功注册ExitPlanMode函数")
+		}
+	}
 
 	lc.Logger.Println("函数注册完成")
 }
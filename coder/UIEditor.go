@@ -3,6 +3,7 @@ package coder
 import (
 	"fmt"
 	"log"
+	"lukatincode/function"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -51,6 +52,14 @@ func (lc *LukatinCode) Editor(file_path string, old_string string, new_string st
 		return fmt.Sprintf("Error checking file: %v", err)
 	}
 
+	// 检查文件已被Read且之后未被修改
+	if err := function.CheckFileFresh(file_path); err != nil {
+		if logger != nil {
+			logger.Printf("Editor函数返回 - 读取状态校验失败: %v", err)
+		}
+		return err.Error()
+	}
+
 	// 3. 处理来自Read工具的行号前缀
 	cleanedOldString := lc.cleanLineNumberPrefix(old_string)
 	if cleanedOldString != old_string {
@@ -59,48 +68,38 @@ func (lc *LukatinCode) Editor(file_path string, old_string string, new_string st
 		}
 		old_string = cleanedOldString
 	}
+	// 统一为LF，与解码后的文件内容对齐
+	old_string = function.NormalizeLineEndings(old_string)
+	new_string = function.NormalizeLineEndings(new_string)
 
-	// 4. 读取文件内容
+	// 4. 读取文件内容（检测编码、BOM与换行符，写回时保持不变）
 	content, err := os.ReadFile(file_path)
 	if err != nil {
 		return fmt.Sprintf("Error reading file: %v", err)
 	}
 
 	originalSize := len(content)
-	contentStr := string(content)
-
-	// 5. 计算匹配次数
-	count := strings.Count(contentStr, old_string)
-	if count == 0 {
-		return fmt.Sprintf("Error: old_string not found in file. Searched for: %q", old_string)
-	}
+	contentStr, format := function.DecodeText(content)
 
-	// 6. 验证期望的替换次数
-	if expected_replacements <= 0 {
-		if replace_all {
-			expected_replacements = count // replace_all时，期望替换所有匹配
-		} else {
-			expected_replacements = 1 // 默认期望替换1次
+	// 5-7. 匹配并生成预览内容：精确匹配失败时依次尝试空白归一化和缩进相对匹配
+	match, err := function.ApplyEditMatch(contentStr, old_string, new_string, replace_all, expected_replacements)
+	if err != nil {
+		if logger != nil {
+			logger.Printf("Editor函数返回 - 匹配失败: %v", err)
 		}
+		return err.Error()
 	}
-
-	if !replace_all && count != expected_replacements {
-		return fmt.Sprintf("Error: Expected %d replacements but found %d occurrences. Use replace_all=true to replace all %d occurrences", expected_replacements, count, count)
-	}
-
-	// 7. UI确认逻辑 - 生成预览内容
-	var newContentStr string
-	if replace_all {
-		newContentStr = strings.ReplaceAll(contentStr, old_string, new_string)
-	} else {
-		newContentStr = strings.Replace(contentStr, old_string, new_string, expected_replacements)
-	}
+	newContentStr := match.NewContent
 
 	// 8. 请求用户确认
 	operation := "edit"
 	if replace_all {
 		operation = "edit (replace_all)"
 	}
+	if match.Fuzzy() {
+		// 在确认diff中提示使用了模糊匹配
+		operation += fmt.Sprintf(" ⚠ 模糊匹配: %s", match.Describe())
+	}
 
 	if logger != nil {
 		logger.Printf("请求用户确认修改 - 文件: %s", file_path)
@@ -119,29 +118,65 @@ func (lc *LukatinCode) Editor(file_path string, old_string string, new_string st
 		logger.Printf("用户确认修改，继续执行")
 	}
 
-	// 9. 执行替换
-	var newContent string
-	var actualReplacements int
-
-	if replace_all {
-		newContent = strings.ReplaceAll(contentStr, old_string, new_string)
-		actualReplacements = count
-	} else {
-		newContent = strings.Replace(contentStr, old_string, new_string, expected_replacements)
-		actualReplacements = expected_replacements
+	// 9. 确认期间文件可能在编辑器中被保存：重新校验磁盘内容，变化时在最新内容上重新应用，无法应用则中止
+	reapplied := false
+	current, changed, err := function.ReverifyFile(file_path, content)
+	if err != nil {
+		return fmt.Sprintf("Error reading file: %v", err)
+	}
+	if changed {
+		if current == nil {
+			lc.warnExternalChange(file_path, "文件已被删除，修改已中止")
+			return fmt.Sprintf("Error: %s was deleted while waiting for confirmation. No changes were written.", file_path)
+		}
+		currentStr, currentFormat := function.DecodeText(current)
+		rematch, err := function.ApplyEditMatch(currentStr, old_string, new_string, replace_all, expected_replacements)
+		if err != nil {
+			if logger != nil {
+				logger.Printf("Editor函数返回 - 文件在确认期间被修改且无法重新应用: %v", err)
+			}
+			lc.warnExternalChange(file_path, "修改已无法应用，已中止")
+			return fmt.Sprintf("Error: %s was modified on disk while waiting for confirmation and the edit no longer applies to the new content (%s). No changes were written; the external changes were kept. Read the file again and retry.",
+				file_path, strings.TrimPrefix(err.Error(), "Error: "))
+		}
+		if logger != nil {
+			logger.Printf("文件在确认期间被修改，已在最新内容上重新应用")
+		}
+		lc.warnExternalChange(file_path, "已在最新内容上重新应用修改")
+		content, format, match, newContentStr = current, currentFormat, rematch, rematch.NewContent
+		originalSize = len(content)
+		reapplied = true
 	}
 
-	// 10. 写入文件
-	err = os.WriteFile(file_path, []byte(newContent), fileInfo.Mode())
+	// 10. 执行替换
+	newContent := newContentStr
+	actualReplacements := match.Replaced
+
+	// 11. 按原格式编码并写入文件
+	newData, err := function.EncodeText(newContent, format)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	err = os.WriteFile(file_path, newData, fileInfo.Mode())
 	if err != nil {
 		return fmt.Sprintf("Error writing file: %v", err)
 	}
+	function.RecordFileWrite(file_path)
 
-	// 11. 返回结果
-	newSize := len(newContent)
+	// 12. 返回结果
+	newSize := len(newData)
 	sizeDelta := newSize - originalSize
 	result := fmt.Sprintf("Successfully made %d replacement(s) in %s. Size changed by %+d bytes (%d -> %d)",
 		actualReplacements, filepath.Base(file_path), sizeDelta, originalSize, newSize)
+	if match.Fuzzy() {
+		result += fmt.Sprintf("\nNote: old_string did not match exactly; used %s. Read the file again if you need its exact current text.", match.Describe())
+	}
+	if reapplied {
+		result += "\nNote: the file was modified on disk while waiting for confirmation; the edit was re-applied to the current content and the external changes were kept."
+	}
+
+	// 执行项目设置中的格式化/检查hook，诊断信息附加到结果中
+	result += function.RunPostEditHooks(file_path)
 
 	if logger != nil {
 		logger.Printf("Editor函数返回 - 成功编辑: %s", result)
@@ -151,6 +186,10 @@ func (lc *LukatinCode) Editor(file_path string, old_string string, new_string st
 
 // requestEditConfirmation 请求用户确认编辑
 func (lc *LukatinCode) requestEditConfirmation(filePath, oldContent, newContent, operation string) bool {
+	// 权限模式允许时跳过确认
+	if lc.autoApproveChange(filePath, oldContent, newContent, operation) {
+		return true
+	}
 	if lc.BubbleTUI == nil {
 		// 如果没有UI，默认确认
 		return true
@@ -188,6 +227,44 @@ func (lc *LukatinCode) requestEditConfirmation(filePath, oldContent, newContent,
 	return true
 }
 
+// requestChangeConfirmation 请求用户确认预先生成diff的修改（补丁、notebook等），paths用于判断能否自动批准
+func (lc *LukatinCode) requestChangeConfirmation(change codeChangeMsg, paths ...string) bool {
+	// 权限模式允许时跳过确认
+	if lc.canAutoApprove(paths...) {
+		lc.notifyAutoApproved(change)
+		return true
+	}
+	if lc.BubbleTUI == nil || lc.BubbleTUI.program == nil {
+		// 如果没有UI，默认确认
+		return true
+	}
+
+	change.needConfirm = true
+	change.changeId = fmt.Sprintf("change_%d", time.Now().UnixNano())
+
+	// 创建响应channel并等待确认
+	responseChan := make(chan bool, 1)
+	lc.BubbleTUI.responseChannels[change.changeId] = responseChan
+	lc.BubbleTUI.program.Send(change)
+
+	// 阻塞等待用户确认
+	confirmed := <-responseChan
+
+	// 清理
+	delete(lc.BubbleTUI.responseChannels, change.changeId)
+	delete(lc.BubbleTUI.pendingChanges, change.changeId)
+
+	return confirmed
+}
+
+// warnExternalChange 确认期间文件被外部修改时在UI中提示
+func (lc *LukatinCode) warnExternalChange(filePath, detail string) {
+	lc.Logger.Printf("文件在等待确认期间被外部修改: %s, %s", filePath, detail)
+	if lc.BubbleTUI != nil && lc.BubbleTUI.program != nil {
+		lc.BubbleTUI.program.Send(externalChangeMsg{filePath: filePath, detail: detail})
+	}
+}
+
 // cleanLineNumberPrefix 清理从Read工具输出中复制的行号前缀
 func (lc *LukatinCode) cleanLineNumberPrefix(text string) string {
 	// 匹配格式: "  123\t内容" 或 " 123\t内容"
@@ -0,0 +1,96 @@
+package coder
+
+import (
+	"fmt"
+	"log"
+	"lukatincode/function"
+	"os"
+	"strings"
+	"time"
+)
+
+// Mover 带UI确认的移动方法
+func (lc *LukatinCode) Mover(source string, destination string, update_imports bool) string {
+	logger, done := fileOpLogger("Mover", "source: %s, destination: %s, update_imports: %v", source, destination, update_imports)
+	defer done()
+
+	op, err := function.PrepareMove(source, destination, update_imports)
+	return lc.finishFileOp(logger, "Mover", op, err)
+}
+
+// Copier 带UI确认的复制方法
+func (lc *LukatinCode) Copier(source string, destination string, overwrite bool) string {
+	logger, done := fileOpLogger("Copier", "source: %s, destination: %s, overwrite: %v", source, destination, overwrite)
+	defer done()
+
+	op, err := function.PrepareCopy(source, destination, overwrite)
+	return lc.finishFileOp(logger, "Copier", op, err)
+}
+
+// Deleter 带UI确认的删除方法，删除前备份到回收目录
+func (lc *LukatinCode) Deleter(path string, recursive bool) string {
+	logger, done := fileOpLogger("Deleter", "path: %s, recursive: %v", path, recursive)
+	defer done()
+
+	op, err := function.PrepareDelete(path, recursive)
+	return lc.finishFileOp(logger, "Deleter", op, err)
+}
+
+// fileOpLogger 打开log/fileops.txt并记录调用参数，返回的done记录耗时并关闭文件
+func fileOpLogger(name string, format string, args ...interface{}) (*log.Logger, func()) {
+	start := time.Now()
+	logFile, err := os.OpenFile("./log/fileops.txt", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, func() {}
+	}
+	logger := log.New(logFile, "", log.LstdFlags)
+	logger.Printf(name+"函数调用 - "+format, args...)
+	return logger, func() {
+		logger.Printf("%s函数执行完成 - 耗时: %v", name, time.Since(start))
+		logFile.Close()
+	}
+}
+
+// finishFileOp 请求用户确认并执行已校验的文件操作
+func (lc *LukatinCode) finishFileOp(logger *log.Logger, name string, op *function.FileOperation, err error) string {
+	if err != nil {
+		if logger != nil {
+			logger.Printf("%s函数返回 - 校验失败: %v", name, err)
+		}
+		return err.Error()
+	}
+
+	confirmed := lc.requestChangeConfirmation(codeChangeMsg{
+		filePath:  op.Source,
+		operation: op.Operation,
+		diffText:  op.Diff(),
+	}, op.Paths()...)
+	if !confirmed {
+		if logger != nil {
+			logger.Printf("用户取消%s操作", op.Operation)
+		}
+		return "File " + op.Operation + " operation cancelled by user"
+	}
+
+	// 确认期间源、目标或需要更新的文件被外部修改时中止
+	if changed := op.ChangedOnDisk(); len(changed) > 0 {
+		lc.warnExternalChange(strings.Join(changed, ", "), "文件操作已中止")
+		if logger != nil {
+			logger.Printf("%s函数返回 - 确认期间被外部修改: %v", name, changed)
+		}
+		return fmt.Sprintf("Error: these paths were changed on disk while waiting for confirmation: %s. The %s was not performed. Check them again and retry.", strings.Join(changed, ", "), op.Operation)
+	}
+
+	if err := op.Commit(); err != nil {
+		if logger != nil {
+			logger.Printf("%s函数返回 - 执行失败: %v", name, err)
+		}
+		return err.Error()
+	}
+
+	result := op.Result() + function.RunPostEditHooks(function.PatchedPaths(op.Updates)...)
+	if logger != nil {
+		logger.Printf("%s函数返回 - 成功: %s", name, result)
+	}
+	return result
+}
@@ -0,0 +1,78 @@
+package coder
+
+import (
+	"fmt"
+	"log"
+	"lukatincode/function"
+	"os"
+	"strings"
+	"time"
+)
+
+// MultiFileEditor 带UI确认的跨文件事务性编辑，所有文件作为一次修改确认
+func (lc *LukatinCode) MultiFileEditor(edits string) string {
+	start := time.Now()
+
+	// 记录日志
+	logFile, err := os.OpenFile("./log/multifileedit.txt", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	var logger *log.Logger
+	if err == nil {
+		defer logFile.Close()
+		logger = log.New(logFile, "", log.LstdFlags)
+		logger.Printf("MultiFileEditor函数调用 - edits_length: %d", len(edits))
+		defer func() {
+			logger.Printf("MultiFileEditor函数执行完成 - 耗时: %v", time.Since(start))
+		}()
+	}
+
+	// 1. 解析并在内存中校验所有文件的所有编辑
+	operations, err := function.ParseFileEdits(edits)
+	if err != nil {
+		return err.Error()
+	}
+	files, err := function.PrepareMultiFileEdit(operations)
+	if err != nil {
+		if logger != nil {
+			logger.Printf("MultiFileEditor函数返回 - 校验失败: %v", err)
+		}
+		return err.Error()
+	}
+
+	// 2. 合并diff，请求用户一次性确认
+	var paths []string
+	for _, pf := range files {
+		paths = append(paths, pf.Path)
+	}
+	confirmed := lc.requestChangeConfirmation(codeChangeMsg{
+		filePath:  fmt.Sprintf("%d个文件", len(files)),
+		operation: "multifile",
+		diffText:  function.CombinedDiff(files),
+	}, paths...)
+	if !confirmed {
+		if logger != nil {
+			logger.Printf("用户取消跨文件编辑")
+		}
+		return "MultiFileEdit operation cancelled by user"
+	}
+
+	// 3. 确认期间有文件被外部修改时中止，避免覆盖用户的改动
+	if changed := function.ChangedOnDisk(files); len(changed) > 0 {
+		lc.warnExternalChange(strings.Join(changed, ", "), "跨文件修改已中止")
+		return fmt.Sprintf("Error: these files were changed on disk while waiting for confirmation: %s. No changes were written. Read them again and retry.", strings.Join(changed, ", "))
+	}
+
+	// 4. 全部写入或全部回滚
+	if err := function.CommitPatch(files); err != nil {
+		if logger != nil {
+			logger.Printf("MultiFileEditor函数返回 - 写入失败: %v", err)
+		}
+		return err.Error()
+	}
+
+	result := function.FormatMultiFileEditResult(files, len(operations))
+	result += function.RunPostEditHooks(function.PatchedPaths(files)...)
+	if logger != nil {
+		logger.Printf("MultiFileEditor函数返回 - 成功: %d个文件", len(files))
+	}
+	return result
+}
@@ -0,0 +1,89 @@
+package coder
+
+import (
+	"fmt"
+	"log"
+	"lukatincode/function"
+	"os"
+	"strings"
+	"time"
+)
+
+// NotebookEditor 带UI确认的notebook cell修改方法
+func (lc *LukatinCode) NotebookEditor(notebook_path string, cell_number int, new_source string, cell_id string, cell_type string, edit_mode string) string {
+	start := time.Now()
+
+	// 记录日志
+	logFile, err := os.OpenFile("./log/notebook.txt", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	var logger *log.Logger
+	if err == nil {
+		defer logFile.Close()
+		logger = log.New(logFile, "", log.LstdFlags)
+		logger.Printf("NotebookEditor函数调用 - notebook_path: %s, cell_number: %d, cell_id: %s, edit_mode: %s", notebook_path, cell_number, cell_id, edit_mode)
+		defer func() {
+			logger.Printf("NotebookEditor函数执行完成 - 耗时: %v", time.Since(start))
+		}()
+	}
+
+	// 1. 在内存中完成修改（记录修改前的磁盘内容，确认后校验）
+	originalData, _ := os.ReadFile(notebook_path)
+	nb, change, err := function.PrepareNotebookEdit(notebook_path, cell_number, new_source, cell_id, cell_type, edit_mode)
+	if err != nil {
+		if logger != nil {
+			logger.Printf("NotebookEditor函数返回 - 错误: %v", err)
+		}
+		return err.Error()
+	}
+
+	// 2. 请求用户确认
+	confirmed := lc.requestChangeConfirmation(codeChangeMsg{
+		filePath:  notebook_path,
+		operation: "notebook " + change.Mode,
+		diffText:  notebookChangeDiff(change),
+	}, notebook_path)
+	if !confirmed {
+		if logger != nil {
+			logger.Printf("用户取消notebook修改")
+		}
+		return "NotebookEdit operation cancelled by user"
+	}
+
+	// 3. 确认期间notebook被外部修改（如在Jupyter中保存）时中止
+	if _, changed, err := function.ReverifyFile(notebook_path, originalData); err != nil || changed {
+		lc.warnExternalChange(notebook_path, "notebook修改已中止")
+		return fmt.Sprintf("Error: %s was changed on disk while waiting for confirmation; saving now would overwrite those changes. No changes were written. Read the notebook again and retry.", notebook_path)
+	}
+
+	// 4. 写回磁盘
+	if err := nb.Save(); err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+
+	result := change.Describe(notebook_path)
+	if logger != nil {
+		logger.Printf("NotebookEditor函数返回 - 成功: %s", result)
+	}
+	return result
+}
+
+// notebookChangeDiff 生成cell修改前后的diff文本
+func notebookChangeDiff(change *function.NotebookChange) string {
+	header := fmt.Sprintf("@@ cell %d", change.Index)
+	if change.CellID != "" {
+		header += fmt.Sprintf(" (id %s)", change.CellID)
+	}
+	header += fmt.Sprintf(" %s, %s @@", change.CellType, change.Mode)
+
+	lines := []string{header}
+	if change.Mode != "insert" {
+		for _, line := range strings.Split(change.OldSource, "\n") {
+			lines = append(lines, "-"+line)
+		}
+	}
+	if change.Mode != "delete" {
+		for _, line := range strings.Split(change.NewSource, "\n") {
+			lines = append(lines, "+"+line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
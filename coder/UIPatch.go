@@ -0,0 +1,96 @@
+package coder
+
+import (
+	"fmt"
+	"log"
+	"lukatincode/function"
+	"os"
+	"strings"
+	"time"
+)
+
+// PatchApplier 带UI确认的补丁应用方法，整个补丁作为一次修改确认
+func (lc *LukatinCode) PatchApplier(patch string) string {
+	start := time.Now()
+
+	// 记录日志
+	logFile, err := os.OpenFile("./log/applypatch.txt", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	var logger *log.Logger
+	if err == nil {
+		defer logFile.Close()
+		logger = log.New(logFile, "", log.LstdFlags)
+		logger.Printf("PatchApplier函数调用 - patch_length: %d", len(patch))
+		defer func() {
+			logger.Printf("PatchApplier函数执行完成 - 耗时: %v", time.Since(start))
+		}()
+	}
+
+	if strings.TrimSpace(patch) == "" {
+		return "Error: patch cannot be empty"
+	}
+
+	// 1. 解析补丁并在内存中计算所有文件的修改结果
+	files, err := function.PreparePatch(patch)
+	if err != nil {
+		if logger != nil {
+			logger.Printf("PatchApplier函数返回 - 补丁校验失败: %v", err)
+		}
+		return err.Error()
+	}
+
+	// 2. 请求用户确认
+	if logger != nil {
+		logger.Printf("请求用户确认补丁 - 文件数: %d", len(files))
+	}
+	if !lc.requestPatchConfirmation(files, patch) {
+		if logger != nil {
+			logger.Printf("用户取消补丁操作")
+		}
+		return "ApplyPatch operation cancelled by user"
+	}
+
+	// 3. 确认期间有文件被外部修改时中止，避免覆盖用户的改动
+	if changed := function.ChangedOnDisk(files); len(changed) > 0 {
+		lc.warnExternalChange(strings.Join(changed, ", "), "补丁已中止")
+		return fmt.Sprintf("Error: these files were changed on disk while waiting for confirmation: %s. No changes were written. Read them again and regenerate the patch.", strings.Join(changed, ", "))
+	}
+
+	// 4. 写入磁盘
+	if err := function.CommitPatch(files); err != nil {
+		if logger != nil {
+			logger.Printf("PatchApplier函数返回 - 写入失败: %v", err)
+		}
+		return err.Error()
+	}
+
+	result := function.FormatPatchResult(files)
+	result += function.RunPostEditHooks(function.PatchedPaths(files)...)
+	if logger != nil {
+		logger.Printf("PatchApplier函数返回 - 成功: %d个文件", len(files))
+	}
+	return result
+}
+
+// requestPatchConfirmation 请求用户确认整个补丁
+func (lc *LukatinCode) requestPatchConfirmation(files []*function.PatchedFile, patch string) bool {
+	var paths []string
+	var summary []string
+	for _, pf := range files {
+		paths = append(paths, pf.Path, pf.OldPath)
+		if pf.Operation == "rename" {
+			summary = append(summary, fmt.Sprintf("%s %s -> %s", pf.Operation, pf.OldPath, pf.Path))
+		} else {
+			summary = append(summary, fmt.Sprintf("%s %s", pf.Operation, pf.Path))
+		}
+		for _, note := range pf.Notes {
+			summary = append(summary, "  "+note)
+		}
+	}
+	title := fmt.Sprintf("%d个文件", len(files))
+
+	return lc.requestChangeConfirmation(codeChangeMsg{
+		filePath:  title,
+		operation: "patch",
+		diffText:  strings.Join(summary, "\n") + "\n" + strings.TrimRight(patch, "\n"),
+	}, paths...)
+}
@@ -0,0 +1,70 @@
+package coder
+
+import (
+	"fmt"
+	"log"
+	"lukatincode/function"
+	"os"
+	"strings"
+	"time"
+)
+
+// Renamer 带UI确认的Go标识符重命名，所有受影响的文件作为一次修改确认
+func (lc *LukatinCode) Renamer(symbol string, new_name string, path string) string {
+	start := time.Now()
+
+	// 记录日志
+	logFile, err := os.OpenFile("./log/symbols.txt", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	var logger *log.Logger
+	if err == nil {
+		defer logFile.Close()
+		logger = log.New(logFile, "", log.LstdFlags)
+		logger.Printf("Renamer函数调用 - symbol: %q, new_name: %q, path: %q", symbol, new_name, path)
+		defer func() {
+			logger.Printf("Renamer函数执行完成 - 耗时: %v", time.Since(start))
+		}()
+	}
+
+	// 1. 类型检查、冲突检查，并验证改名后仍能编译
+	files, summary, err := function.PrepareRename(symbol, new_name, path)
+	if err != nil {
+		if logger != nil {
+			logger.Printf("Renamer函数返回 - 失败: %v", err)
+		}
+		return err.Error()
+	}
+
+	// 2. 合并diff，请求用户一次性确认
+	confirmed := lc.requestChangeConfirmation(codeChangeMsg{
+		filePath:  fmt.Sprintf("%s -> %s（%d个文件）", symbol, new_name, len(files)),
+		operation: "rename",
+		diffText:  summary + "\n" + function.CombinedDiff(files),
+	}, function.PatchedPaths(files)...)
+	if !confirmed {
+		if logger != nil {
+			logger.Printf("用户取消重命名")
+		}
+		return "RenameSymbol operation cancelled by user"
+	}
+
+	// 3. 确认期间有文件被外部修改时中止
+	if changed := function.ChangedOnDisk(files); len(changed) > 0 {
+		lc.warnExternalChange(strings.Join(changed, ", "), "重命名已中止")
+		return fmt.Sprintf("Error: these files were changed on disk while waiting for confirmation: %s. No changes were written. Retry the rename.", strings.Join(changed, ", "))
+	}
+
+	// 4. 全部写入或全部回滚
+	if err := function.CommitPatch(files); err != nil {
+		if logger != nil {
+			logger.Printf("Renamer函数返回 - 写入失败: %v", err)
+		}
+		return err.Error()
+	}
+
+	result := function.FormatRenameResult(files, summary)
+	result += function.RunPostEditHooks(function.PatchedPaths(files)...)
+	if logger != nil {
+		logger.Printf("Renamer函数返回 - 成功: %s", summary)
+	}
+	return result
+}
@@ -0,0 +1,260 @@
+package coder
+
+import (
+	"fmt"
+	"lukatincode/function"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// 子代理面板：对话进行中有Task子代理时显示在输入框上方，每个子代理一节。
+// 每节默认折叠为一行（描述、当前工具调用、token用量、耗时），展开后显示模型和最近的工具调用。
+// Ctrl+O 把焦点切换到面板（uiMode为"agents"），选中子代理后单独展开或折叠。与待办事项面板一样随tick刷新。
+
+const subAgentPanelMaxAgents = 6 // 面板最多显示的子代理数，超出时优先隐藏已结束的
+
+// subAgentHelp 面板获得焦点时状态栏中的按键说明
+const subAgentHelp = "↑↓ 选择 | Enter/空格 展开/折叠 | a 全部展开/折叠 | Ctrl+O/Esc 返回输入框"
+
+// showSubAgents 是否显示子代理面板：对话进行中，或仍有子代理未结束
+func (b *BubbleTeaTUI) showSubAgents() bool {
+	if b.isProcessing && len(b.subAgents) > 0 {
+		return true
+	}
+	for _, agent := range b.subAgents {
+		if agent.Status == "running" || agent.Status == "queued" {
+			return true
+		}
+	}
+	return false
+}
+
+// refreshSubAgents 同步本轮子代理的进度，面板消失时焦点回到输入框
+func (b *BubbleTeaTUI) refreshSubAgents() {
+	b.subAgents = function.SubAgentSnapshot()
+	b.clampSubAgentCursor()
+	if b.uiMode == "agents" && !b.showSubAgents() {
+		b.leaveSubAgentMode()
+	}
+}
+
+// enterSubAgentMode 把焦点切换到子代理面板，默认选中第一个运行中的子代理
+func (b *BubbleTeaTUI) enterSubAgentMode() {
+	if !b.showSubAgents() {
+		return
+	}
+	b.uiMode = "agents"
+	b.input.Blur()
+	b.subAgentCursor = 0
+	for i, agent := range b.subAgents {
+		if agent.Status == "running" {
+			b.subAgentCursor = i
+			break
+		}
+	}
+	b.layout()
+}
+
+// leaveSubAgentMode 焦点回到输入框
+func (b *BubbleTeaTUI) leaveSubAgentMode() {
+	b.uiMode = "normal"
+	b.input.Focus()
+	b.layout()
+}
+
+func (b *BubbleTeaTUI) clampSubAgentCursor() {
+	if b.subAgentCursor >= len(b.subAgents) {
+		b.subAgentCursor = len(b.subAgents) - 1
+	}
+	if b.subAgentCursor < 0 {
+		b.subAgentCursor = 0
+	}
+}
+
+// updateSubAgentMode 处理面板获得焦点时的按键
+func (b *BubbleTeaTUI) updateSubAgentMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return b, tea.Quit
+	case "ctrl+o", "esc", "tab":
+		b.leaveSubAgentMode()
+		return b, nil
+	case "up", "k":
+		b.subAgentCursor--
+	case "down", "j":
+		b.subAgentCursor++
+	case "enter", " ":
+		if b.subAgentCursor < len(b.subAgents) {
+			id := b.subAgents[b.subAgentCursor].ID
+			b.subAgentExpanded[id] = !b.subAgentExpanded[id]
+		}
+	case "a":
+		b.toggleAllSubAgents()
+	}
+	b.clampSubAgentCursor()
+	b.layout()
+	b.viewport.GotoBottom()
+	return b, nil
+}
+
+// toggleAllSubAgents 有折叠的子代理时全部展开，否则全部折叠
+func (b *BubbleTeaTUI) toggleAllSubAgents() {
+	expand := false
+	for _, agent := range b.subAgents {
+		if !b.subAgentExpanded[agent.ID] {
+			expand = true
+			break
+		}
+	}
+	for _, agent := range b.subAgents {
+		b.subAgentExpanded[agent.ID] = expand
+	}
+}
+
+// renderSubAgents 渲染子代理面板，不显示时返回空串
+func (b *BubbleTeaTUI) renderSubAgents() string {
+	if !b.showSubAgents() {
+		return ""
+	}
+	inner := b.width - 8 // 与对话区域同宽，减去边框和内边距
+
+	running, queued, finished := 0, 0, 0
+	for _, agent := range b.subAgents {
+		switch agent.Status {
+		case "running":
+			running++
+		case "queued":
+			queued++
+		default:
+			finished++
+		}
+	}
+	focused := b.uiMode == "agents"
+	muted := lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
+	header := fmt.Sprintf(" 运行中 %d · 排队 %d · 已结束 %d/%d", running, queued, finished, len(b.subAgents))
+	if !focused {
+		header += " · Ctrl+O 展开详情"
+	}
+	lines := []string{lipgloss.NewStyle().Bold(true).Render("🤖 子代理") + muted.Render(truncateWidth(header, inner-lipgloss.Width("🤖 子代理")))}
+
+	// 子代理过多时从前往后隐藏已结束的，选中的子代理始终显示
+	var sections []string
+	hidden := 0
+	excess := len(b.subAgents) - subAgentPanelMaxAgents
+	for i, agent := range b.subAgents {
+		selected := focused && i == b.subAgentCursor
+		if hidden < excess && !selected && agent.Status != "running" && agent.Status != "queued" {
+			hidden++
+			continue
+		}
+		sections = append(sections, b.subAgentSection(agent, inner, selected)...)
+	}
+	if hidden > 0 {
+		lines = append(lines, muted.Render(fmt.Sprintf("  … 另有 %d 个已结束", hidden)))
+	}
+	lines = append(lines, sections...)
+
+	return b.todoStyle.
+		Padding(0, 1).
+		Width(b.width - 6).
+		Render(strings.Join(lines, "\n"))
+}
+
+// subAgentSection 单个子代理：状态图标、描述和当前工具调用、右对齐的统计；展开时附带模型和最近的工具调用。
+// selected为面板获得焦点时选中的子代理，反色显示
+func (b *BubbleTeaTUI) subAgentSection(agent function.SubAgentProgress, width int, selected bool) []string {
+	icon := "◌"
+	style := lipgloss.NewStyle().Foreground(lipgloss.Color("252"))
+	detail := ""
+	switch agent.Status {
+	case "queued":
+		style = lipgloss.NewStyle().Foreground(lipgloss.Color("243"))
+		detail = "排队中"
+	case "running":
+		icon = "▶"
+		style = lipgloss.NewStyle().Foreground(lipgloss.Color("10")).Bold(true)
+		detail = "思考中…"
+		if agent.CurrentTool != "" {
+			detail = "🔧 " + agent.CurrentTool
+		}
+	case "completed":
+		icon = "✓"
+		style = lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
+		detail = "已完成"
+	case "failed":
+		icon = "✗"
+		style = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+		detail = "失败"
+	case "cancelled":
+		icon = "⊘"
+		style = lipgloss.NewStyle().Foreground(lipgloss.Color("243"))
+		detail = "已取消"
+	}
+
+	stats := fmt.Sprintf("%s tokens · %d 次工具", formatTokens(agent.Usage.TotalTokens), agent.ToolCount)
+	if elapsed := formatElapsed(subAgentElapsed(agent)); elapsed != "" {
+		stats += " · " + elapsed
+	}
+	main := agent.Description + " · " + detail
+	if agent.AgentType != "" && agent.AgentType != function.GeneralPurposeAgent {
+		main = "[" + agent.AgentType + "] " + main
+	}
+	mainWidth := width - 3 - lipgloss.Width(stats)
+	if mainWidth < 10 {
+		// 太窄时不显示统计
+		stats = ""
+		mainWidth = width - 2
+	}
+	main = truncateWidth(main, mainWidth)
+	gap := width - 2 - lipgloss.Width(main) - lipgloss.Width(stats)
+	if gap < 0 {
+		gap = 0
+	}
+	rowStyle := style
+	if selected {
+		rowStyle = style.Reverse(true)
+	}
+	lines := []string{rowStyle.Render(icon + " " + main + strings.Repeat(" ", gap) + stats)}
+	if !b.subAgentExpanded[agent.ID] {
+		return lines
+	}
+
+	muted := lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
+	if agent.Model != "" {
+		lines = append(lines, muted.Render(truncateWidth(fmt.Sprintf("    模型: %s/%s", agent.Provider, agent.Model), width)))
+	}
+	for _, tool := range agent.RecentTools {
+		line := truncateWidth("    ⎿ "+tool, width)
+		if agent.Status == "running" && tool == agent.CurrentTool {
+			lines = append(lines, lipgloss.NewStyle().Foreground(lipgloss.Color("10")).Render(line))
+		} else {
+			lines = append(lines, muted.Render(line))
+		}
+	}
+	if agent.Error != "" {
+		lines = append(lines, lipgloss.NewStyle().Foreground(lipgloss.Color("9")).Render(truncateWidth("    "+agent.Error, width)))
+	}
+	return lines
+}
+
+// subAgentElapsed 运行中的子代理已用时间，已结束的子代理从开始到结束的用时
+func subAgentElapsed(agent function.SubAgentProgress) time.Duration {
+	switch {
+	case agent.StartedAt.IsZero():
+		return 0
+	case agent.FinishedAt.IsZero():
+		return time.Since(agent.StartedAt)
+	}
+	return agent.FinishedAt.Sub(agent.StartedAt)
+}
+
+// formatTokens 紧凑的token数：850、12.3k
+func formatTokens(n int) string {
+	if n < 1000 {
+		return fmt.Sprintf("%d", n)
+	}
+	return fmt.Sprintf("%.1fk", float64(n)/1000)
+}
@@ -0,0 +1,292 @@
+package coder
+
+import (
+	"fmt"
+	"lukatincode/function"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// 待办事项面板的键盘编辑：Tab把焦点切换到面板（uiMode为"todo"），用户的修改写入主代理的清单，
+// 并在下一轮以系统提醒告知模型
+
+// todoEditHelp 编辑模式下状态栏中的按键说明
+const todoEditHelp = "↑↓ 选择 | Shift+↑↓ 移动 | a 新增 | s 开始 | x 完成/取消 | d 删除 | Tab/Esc 返回输入框"
+
+// enterTodoMode 打开面板并把焦点切换到面板
+func (b *BubbleTeaTUI) enterTodoMode() {
+	if !b.showTodos {
+		b.toggleTodos()
+	}
+	b.uiMode = "todo"
+	b.todoAdding = false
+	b.input.Blur()
+	b.refreshTodos()
+	// 默认选中进行中的事项
+	for i, todo := range b.todos {
+		if todo.Status == "in_progress" {
+			b.todoCursor = i
+		}
+	}
+	b.clampTodoCursor()
+}
+
+// leaveTodoMode 焦点回到输入框
+func (b *BubbleTeaTUI) leaveTodoMode() {
+	if b.todoAdding {
+		b.finishTodoAdd(false)
+	}
+	b.uiMode = "normal"
+	b.input.Focus()
+}
+
+func (b *BubbleTeaTUI) clampTodoCursor() {
+	if b.todoCursor >= len(b.todos) {
+		b.todoCursor = len(b.todos) - 1
+	}
+	if b.todoCursor < 0 {
+		b.todoCursor = 0
+	}
+}
+
+// updateTodoMode 处理面板获得焦点时的按键
+func (b *BubbleTeaTUI) updateTodoMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if msg.String() == "ctrl+c" {
+		return b, tea.Quit
+	}
+
+	// 新增事项：输入框接收内容
+	if b.todoAdding {
+		switch msg.String() {
+		case "enter":
+			b.finishTodoAdd(true)
+		case "esc":
+			b.finishTodoAdd(false)
+		default:
+			var cmd tea.Cmd
+			b.input, cmd = b.input.Update(msg)
+			return b, cmd
+		}
+		return b, nil
+	}
+
+	switch msg.String() {
+	case "tab", "esc":
+		b.leaveTodoMode()
+	case "up", "k":
+		b.todoCursor--
+	case "down", "j":
+		b.todoCursor++
+	case "shift+up", "K":
+		b.moveTodo(-1)
+	case "shift+down", "J":
+		b.moveTodo(1)
+	case "a":
+		b.startTodoAdd()
+	case "s":
+		b.startTodo()
+	case "x", " ":
+		b.toggleTodoDone()
+	case "d", "delete":
+		b.deleteTodo()
+	case "ctrl+t":
+		b.leaveTodoMode()
+		b.toggleTodos()
+	}
+	b.clampTodoCursor()
+	return b, nil
+}
+
+// selectedTodo 当前选中的事项
+func (b *BubbleTeaTUI) selectedTodo() (TodoItem, bool) {
+	if b.todoCursor < 0 || b.todoCursor >= len(b.todos) {
+		return TodoItem{}, false
+	}
+	return b.todos[b.todoCursor], true
+}
+
+// editTodos 修改主代理的清单，失败时在对话区域显示原因
+func (b *BubbleTeaTUI) editTodos(description string, edit func([]function.TodoItem) []function.TodoItem) bool {
+	err := function.EditTodos(function.MainTodoAgent, description, edit)
+	if err != nil {
+		b.addMessage(fmt.Sprintf("❌ 无法修改待办事项: %v", err), "error")
+		return false
+	}
+	b.lukatinCode.Logger.Printf("用户修改待办事项: %s", description)
+	b.refreshTodos()
+	b.layout()
+	return true
+}
+
+// todoIndexOf 按ID查找事项在清单中的位置
+func todoIndexOf(items []function.TodoItem, id string) int {
+	for i, item := range items {
+		if item.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (b *BubbleTeaTUI) moveTodo(delta int) {
+	todo, ok := b.selectedTodo()
+	target := b.todoCursor + delta
+	if !ok || target < 0 || target >= len(b.todos) {
+		return
+	}
+	direction := "up"
+	if delta > 0 {
+		direction = "down"
+	}
+	if b.editTodos(fmt.Sprintf("moved #%s %q %s", todo.ID, todo.Content, direction), func(items []function.TodoItem) []function.TodoItem {
+		i := todoIndexOf(items, todo.ID)
+		j := i + delta
+		if i >= 0 && j >= 0 && j < len(items) {
+			items[i], items[j] = items[j], items[i]
+		}
+		return items
+	}) {
+		b.todoCursor = target
+	}
+}
+
+// startTodo 将选中的事项设为进行中，原来进行中的事项回到待处理
+func (b *BubbleTeaTUI) startTodo() {
+	todo, ok := b.selectedTodo()
+	if !ok || todo.Status == "in_progress" {
+		return
+	}
+	description := fmt.Sprintf("started #%s %q", todo.ID, todo.Content)
+	for _, other := range b.todos {
+		if other.Status == "in_progress" {
+			description += fmt.Sprintf(" (#%s %q moved back to pending)", other.ID, other.Content)
+		}
+	}
+	b.editTodos(description, func(items []function.TodoItem) []function.TodoItem {
+		for i := range items {
+			if items[i].ID == todo.ID {
+				items[i].Status = "in_progress"
+			} else if items[i].Status == "in_progress" {
+				items[i].Status = "pending"
+			}
+		}
+		return items
+	})
+}
+
+// toggleTodoDone 在已完成和待处理之间切换
+func (b *BubbleTeaTUI) toggleTodoDone() {
+	todo, ok := b.selectedTodo()
+	if !ok {
+		return
+	}
+	status := "completed"
+	if todo.Status == "completed" {
+		status = "pending"
+	}
+	b.editTodos(fmt.Sprintf("marked #%s %q as %s", todo.ID, todo.Content, status), func(items []function.TodoItem) []function.TodoItem {
+		if i := todoIndexOf(items, todo.ID); i >= 0 {
+			items[i].Status = status
+		}
+		return items
+	})
+}
+
+// deleteTodo 删除选中的事项；其子任务挂到它的父任务下，其他事项对它的依赖一并移除
+func (b *BubbleTeaTUI) deleteTodo() {
+	todo, ok := b.selectedTodo()
+	if !ok {
+		return
+	}
+	b.editTodos(fmt.Sprintf("deleted #%s %q", todo.ID, todo.Content), func(items []function.TodoItem) []function.TodoItem {
+		parent := ""
+		if i := todoIndexOf(items, todo.ID); i >= 0 {
+			parent = items[i].ParentID
+		}
+		var kept []function.TodoItem
+		for _, item := range items {
+			if item.ID == todo.ID {
+				continue
+			}
+			if item.ParentID == todo.ID {
+				item.ParentID = parent
+			}
+			var blockedBy []string
+			for _, dep := range item.BlockedBy {
+				if dep != todo.ID {
+					blockedBy = append(blockedBy, dep)
+				}
+			}
+			item.BlockedBy = blockedBy
+			kept = append(kept, item)
+		}
+		return kept
+	})
+}
+
+// startTodoAdd 在输入框中输入新事项的内容，暂存输入框中原有的内容
+func (b *BubbleTeaTUI) startTodoAdd() {
+	b.todoAdding = true
+	b.stashedInput = b.input.Value()
+	b.input.SetValue("")
+	b.input.Placeholder = "新待办事项内容（Enter 添加，Esc 取消）"
+	b.input.Focus()
+}
+
+// finishTodoAdd 结束输入；add为true时把内容作为新事项插入到选中事项之后
+func (b *BubbleTeaTUI) finishTodoAdd(add bool) {
+	content := strings.TrimSpace(b.input.Value())
+	b.todoAdding = false
+	b.input.SetValue(b.stashedInput)
+	b.stashedInput = ""
+	b.input.Placeholder = "输入消息..."
+	b.input.Blur()
+	if !add || content == "" {
+		return
+	}
+
+	id := b.nextTodoID()
+	position := b.todoCursor + 1
+	if len(b.todos) == 0 {
+		position = 0
+	}
+	if b.editTodos(fmt.Sprintf("added #%s %q", id, content), func(items []function.TodoItem) []function.TodoItem {
+		item := function.TodoItem{ID: id, Content: content, Status: "pending", Priority: "medium"}
+		if position > len(items) {
+			position = len(items)
+		}
+		items = append(items[:position], append([]function.TodoItem{item}, items[position:]...)...)
+		return items
+	}) {
+		b.todoCursor = position
+	}
+}
+
+// nextTodoID 新事项的ID：现有数字ID的最大值加一
+func (b *BubbleTeaTUI) nextTodoID() string {
+	last := 0
+	for _, todo := range b.todos {
+		if n, err := strconv.Atoi(todo.ID); err == nil && n > last {
+			last = n
+		}
+	}
+	return strconv.Itoa(last + 1)
+}
+
+// todoEditReminder 用户在面板中修改过清单时，下一轮告知模型修改内容和当前清单
+func (lc *LukatinCode) todoEditReminder() string {
+	edits := function.TakeUserTodoEdits(function.MainTodoAgent)
+	if len(edits) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("The user edited your todo list since your last turn:\n")
+	for _, edit := range edits {
+		sb.WriteString("- " + edit + "\n")
+	}
+	sb.WriteString("The current todo list is below. Respect the user's changes and keep using TodoWrite on top of this list:\n")
+	sb.WriteString(function.TodoRead())
+	return sb.String()
+}
@@ -0,0 +1,341 @@
+package coder
+
+import (
+	"fmt"
+	"lukatincode/function"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// 待办事项面板（Ctrl+T切换）：宽终端显示在右侧，较窄时显示在输入框上方，
+// 终端过窄或过矮时折叠为一行摘要。每秒随tick刷新，与TodoWrite保持同步。
+
+const (
+	todoPanelSideMinWidth = 110 // 不小于该宽度时面板显示在右侧
+	todoPanelMinWidth     = 60  // 小于该宽度时折叠为一行
+	todoPanelMinHeight    = 20  // 小于该高度时折叠为一行
+	todoPanelMinSide      = 32  // 右侧面板的最小宽度
+	todoPanelMaxSide      = 50  // 右侧面板的最大宽度
+	todoPanelBottomRows   = 5   // 底部面板最多显示的事项数
+)
+
+// todoPanelMode 当前面板的显示方式："side"、"bottom"、"line"，隐藏时为空
+func (b *BubbleTeaTUI) todoPanelMode() string {
+	switch {
+	case !b.showTodos:
+		return ""
+	case b.width < todoPanelMinWidth || b.height < todoPanelMinHeight:
+		return "line"
+	case b.width >= todoPanelSideMinWidth:
+		return "side"
+	default:
+		return "bottom"
+	}
+}
+
+func (b *BubbleTeaTUI) todoPanelWidth() int {
+	w := b.width / 3
+	if w < todoPanelMinSide {
+		w = todoPanelMinSide
+	}
+	if w > todoPanelMaxSide {
+		w = todoPanelMaxSide
+	}
+	return w
+}
+
+// layout 根据窗口大小和面板显示方式调整对话区域的大小
+func (b *BubbleTeaTUI) layout() {
+	if b.width == 0 {
+		return
+	}
+	width := b.width - 4
+	height := b.height - 5
+	switch b.todoPanelMode() {
+	case "side":
+		width -= b.todoPanelWidth()
+	case "bottom":
+		height -= lipgloss.Height(b.renderTodos())
+	case "line":
+		height--
+	}
+	if b.showSubAgents() {
+		height -= lipgloss.Height(b.renderSubAgents())
+	}
+	if height < 3 {
+		height = 3
+	}
+	b.viewport.Width = width
+	b.viewport.Height = height
+}
+
+// toggleTodos 显示或隐藏待办事项面板
+func (b *BubbleTeaTUI) toggleTodos() {
+	b.showTodos = !b.showTodos
+	b.lukatinCode.Logger.Printf("待办事项面板: %v", b.showTodos)
+	if b.showTodos {
+		b.refreshTodos()
+	}
+	b.layout()
+	b.viewport.GotoBottom()
+}
+
+// renderTodos 按当前显示方式渲染待办事项面板
+func (b *BubbleTeaTUI) renderTodos() string {
+	switch b.todoPanelMode() {
+	case "side":
+		return b.renderTodoSide()
+	case "bottom":
+		return b.renderTodoBottom()
+	case "line":
+		return b.renderTodoLine()
+	}
+	return ""
+}
+
+// renderTodoSide 右侧面板：标题、进度条和所有事项，超出高度时保留进行中的事项附近
+func (b *BubbleTeaTUI) renderTodoSide() string {
+	width := b.todoPanelWidth()
+	inner := width - 4 // 左右边框和内边距
+	height := b.viewport.Height
+	lines := []string{lipgloss.NewStyle().Bold(true).Render("📝 TodoList")}
+	if len(b.todos) == 0 {
+		lines = append(lines, "", "暂无任务")
+	} else {
+		lines = append(lines, b.todoProgressBar(inner), "")
+		rows := height - 4 - len(lines) // 上下边框和内边距
+		lines = append(lines, b.todoRows(inner, rows)...)
+	}
+	// Width/Height包含内边距，不含边框
+	return b.todoStyle.
+		Width(width - 2).
+		Height(height - 2).
+		MaxHeight(height).
+		Render(strings.Join(lines, "\n"))
+}
+
+// renderTodoBottom 输入框上方的面板：进度条一行，最多显示todoPanelBottomRows个事项
+func (b *BubbleTeaTUI) renderTodoBottom() string {
+	inner := b.width - 8 // 与对话区域同宽，减去边框和内边距
+	var lines []string
+	if len(b.todos) == 0 {
+		lines = append(lines, "📝 暂无任务")
+	} else {
+		lines = append(lines, "📝 "+b.todoProgressBar(inner-3))
+		lines = append(lines, b.todoRows(inner, todoPanelBottomRows)...)
+	}
+	return b.todoStyle.
+		Padding(0, 1).
+		Width(b.width - 6).
+		Render(strings.Join(lines, "\n"))
+}
+
+// renderTodoLine 折叠后的一行摘要：完成数和进行中的事项
+func (b *BubbleTeaTUI) renderTodoLine() string {
+	if len(b.todos) == 0 {
+		return b.statusStyle.Render("📝 暂无任务")
+	}
+	completed := 0
+	current := ""
+	for _, todo := range b.todos {
+		if todo.Status == "completed" {
+			completed++
+		}
+		if todo.Status == "in_progress" {
+			current = fmt.Sprintf(" ▶ %s %s", todo.Content, formatElapsed(todoElapsed(todo)))
+		}
+	}
+	// 编辑时显示选中的事项
+	if todo, ok := b.selectedTodo(); ok && b.uiMode == "todo" {
+		prefix := fmt.Sprintf("📝 %d/%d [%d] ", completed, len(b.todos), b.todoCursor+1)
+		return b.statusStyle.Render(prefix) + b.todoRow(todo, b.width-2-lipgloss.Width(prefix), true)
+	}
+	line := fmt.Sprintf("📝 %d/%d%s", completed, len(b.todos), current)
+	return b.statusStyle.Render(truncateWidth(line, b.width-2))
+}
+
+// todoProgressBar 形如 "████░░░░ 3/7" 的进度条，总宽度为width
+func (b *BubbleTeaTUI) todoProgressBar(width int) string {
+	completed := 0
+	for _, todo := range b.todos {
+		if todo.Status == "completed" {
+			completed++
+		}
+	}
+	label := fmt.Sprintf(" %d/%d", completed, len(b.todos))
+	barWidth := width - len(label)
+	if barWidth < 4 {
+		return strings.TrimSpace(label)
+	}
+	filled := barWidth * completed / len(b.todos)
+	bar := lipgloss.NewStyle().Foreground(lipgloss.Color("10")).Render(strings.Repeat("█", filled)) +
+		lipgloss.NewStyle().Foreground(lipgloss.Color("240")).Render(strings.Repeat("░", barWidth-filled))
+	return bar + label
+}
+
+// todoRows 渲染最多rows行事项；事项过多时以进行中的事项为中心截取，并提示省略的数量
+func (b *BubbleTeaTUI) todoRows(width, rows int) []string {
+	if rows < 1 {
+		rows = 1
+	}
+	start, end := 0, len(b.todos)
+	if end > rows {
+		// 留两行给省略提示
+		visible := rows - 2
+		if visible < 1 {
+			visible = 1
+		}
+		// 编辑时以选中的事项为中心；否则以进行中的事项为中心，
+		// 没有进行中的事项时以第一个待处理的事项为中心，全部完成时显示末尾
+		current := -1
+		for i, todo := range b.todos {
+			if todo.Status == "in_progress" {
+				current = i
+				break
+			}
+			if todo.Status == "pending" && current < 0 {
+				current = i
+			}
+		}
+		if b.uiMode == "todo" {
+			current = b.todoCursor
+		}
+		if current < 0 {
+			current = len(b.todos) - 1
+		}
+		start = current - visible/2
+		if start < 0 {
+			start = 0
+		}
+		end = start + visible
+		if end > len(b.todos) {
+			end = len(b.todos)
+			start = end - visible
+		}
+	}
+
+	var lines []string
+	muted := lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
+	if start > 0 {
+		lines = append(lines, muted.Render(fmt.Sprintf("  … 前面还有 %d 项", start)))
+	}
+	for i := start; i < end; i++ {
+		lines = append(lines, b.todoRow(b.todos[i], width, b.uiMode == "todo" && i == b.todoCursor))
+	}
+	if end < len(b.todos) {
+		lines = append(lines, muted.Render(fmt.Sprintf("  … 后面还有 %d 项", len(b.todos)-end)))
+	}
+	return lines
+}
+
+// todoRow 单个事项：按层级缩进的状态图标、内容和右对齐的耗时；
+// 进行中的事项高亮，被阻塞的事项显示为⊘，selected为编辑时选中的事项
+func (b *BubbleTeaTUI) todoRow(todo TodoItem, width int, selected bool) string {
+	icon := "☐"
+	style := lipgloss.NewStyle().Foreground(lipgloss.Color("252"))
+	switch {
+	case todo.Status == "in_progress":
+		icon = "▶"
+		style = lipgloss.NewStyle().Foreground(lipgloss.Color("10")).Bold(true)
+	case todo.Status == "completed":
+		icon = "☑"
+		style = lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
+	case len(todo.Blocked) > 0:
+		icon = "⊘"
+		style = lipgloss.NewStyle().Foreground(lipgloss.Color("243"))
+	}
+	if selected {
+		style = style.Reverse(true)
+	}
+
+	indent := strings.Repeat("  ", todo.Depth)
+	if lipgloss.Width(indent) > width/2 {
+		indent = indent[:width/2]
+	}
+	icon = indent + icon
+	width -= len(indent)
+
+	elapsed := formatElapsed(todoElapsed(todo))
+	contentWidth := width - 2 - lipgloss.Width(elapsed)
+	if elapsed != "" {
+		contentWidth--
+	}
+	content := truncateWidth(todo.Content, contentWidth)
+	gap := width - 2 - lipgloss.Width(content) - lipgloss.Width(elapsed)
+	if gap < 0 {
+		gap = 0
+	}
+	return style.Render(icon + " " + content + strings.Repeat(" ", gap) + elapsed)
+}
+
+// todoElapsed 进行中的事项已用时间，已完成的事项从开始到完成的用时；未开始时为0
+func todoElapsed(todo TodoItem) time.Duration {
+	switch {
+	case todo.StartedAt.IsZero():
+		return 0
+	case todo.Status == "in_progress":
+		return time.Since(todo.StartedAt)
+	case todo.Status == "completed" && !todo.FinishedAt.IsZero():
+		return todo.FinishedAt.Sub(todo.StartedAt)
+	}
+	return 0
+}
+
+// formatElapsed 紧凑的时长格式：45s、12m05s、1h02m；0时返回空串
+func formatElapsed(d time.Duration) string {
+	switch {
+	case d <= 0:
+		return ""
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm%02ds", int(d.Minutes()), int(d.Seconds())%60)
+	}
+	return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+}
+
+// truncateWidth 按显示宽度截断字符串（中文等宽字符占两列），超出时以…结尾
+func truncateWidth(s string, width int) string {
+	if lipgloss.Width(s) <= width {
+		return s
+	}
+	if width <= 0 {
+		return ""
+	}
+	var sb strings.Builder
+	used := 0
+	for _, r := range s {
+		w := lipgloss.Width(string(r))
+		if used+w > width-1 {
+			break
+		}
+		sb.WriteRune(r)
+		used += w
+	}
+	return sb.String() + "…"
+}
+
+// refreshTodos 从主代理的清单同步面板数据
+func (b *BubbleTeaTUI) refreshTodos() {
+	items, updated := function.TodoSnapshot(function.MainTodoAgent)
+	todos := make([]TodoItem, len(items))
+	for i, item := range items {
+		todos[i] = TodoItem{
+			ID:         item.ID,
+			Content:    item.Content,
+			Status:     item.Status,
+			StartedAt:  item.StartedAt,
+			FinishedAt: item.FinishedAt,
+			Depth:      item.Depth,
+			Blocked:    item.Blocked,
+		}
+	}
+	if !updated.Equal(b.todoUpdateTime) {
+		b.lukatinCode.Logger.Printf("TodoList已更新: %d项", len(todos))
+		b.todoUpdateTime = updated
+	}
+	b.todos = todos
+	b.clampTodoCursor()
+}
@@ -6,6 +6,7 @@ import (
 	"lukatincode/function"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"runtime"
@@ -16,6 +17,8 @@ import (
 
 type LukatinCode struct {
 	Lmmconfig       *general.LLMConfig
+	Provider        general.Provider // 会话使用的提供商，子代理默认继承
+	Model           string           // 会话使用的模型，子代理默认继承
 	CM              *ConversationManager.ConversationManager
 	BubbleTUI       *BubbleTeaTUI    // New Bubble Tea TUI
 	PersistentShell *PersistentShell // 持久化Shell
@@ -23,13 +26,25 @@ type LukatinCode struct {
 	LogFile         *os.File
 	cancelChan      chan struct{} // 用于取消AI任务
 	isProcessing    bool          // 标记是否正在处理AI任务
+	ProjectRoot     string        // 项目目录（启动时的工作目录）
+	SessionID       string        // 当前会话ID，待办事项按会话保存
+	resumed         bool          // 是否通过 --resume 继续之前的会话
+	todosAnnounced  bool          // 恢复的待办事项是否已告知模型
+
+	// 权限模式
+	permMu            sync.RWMutex
+	permissionMode    PermissionMode
+	allowBypass       bool           // 是否允许bypass模式（命令行参数开启）
+	lastAnnouncedMode PermissionMode // 上一次告知模型的权限模式，同样由permMu保护
 }
 
 func GenLukatinCode(lmmconfig *general.LLMConfig, system_promote string) *LukatinCode {
 	lc := &LukatinCode{
-		Lmmconfig:    lmmconfig,
-		cancelChan:   make(chan struct{}),
-		isProcessing: false,
+		Lmmconfig:         lmmconfig,
+		cancelChan:        make(chan struct{}),
+		isProcessing:      false,
+		permissionMode:    PermissionDefault,
+		lastAnnouncedMode: PermissionDefault,
 	}
 
 	// 初始化日志文件（写入 log 目录）
@@ -62,8 +77,18 @@ func GenLukatinCode(lmmconfig *general.LLMConfig, system_promote string) *Lukati
 	}
 	lc.CM = ConversationManager.NewConversationManager(agentManager)
 
+	// 会话使用的提供商和模型；Task/WebFetch等子代理继承同样的配置
+	lc.Provider = general.ProviderOpenAI
+	lc.Model = lc.Lmmconfig.AgentAPIKey.OpenAI.Model
+	function.SetSubAgentConfig(function.SubAgentConfig{
+		Providers: lc.Lmmconfig.ToProviderConfigs(),
+		Provider:  lc.Provider,
+		Model:     lc.Model,
+	})
+
 	// 动态注入环境信息到系统提示
 	wd, _ := os.Getwd()
+	lc.ProjectRoot = wd
 	gitRepo := "No"
 	if _, err := os.Stat(".git"); err == nil {
 		gitRepo = "Yes"
@@ -90,11 +115,18 @@ func GenLukatinCode(lmmconfig *general.LLMConfig, system_promote string) *Lukati
 	}
 
 	lc.CM.SetSystemPrompt(system_promote)
+
+	// 新会话；main中指定 --resume 时再切换到之前的会话
+	lc.startSession()
 	
 	// 检测和安装 ripgrep
 	lc.Logger.Println("检测 ripgrep 状态")
 	function.LogRipgrepStatus()
 	
+	// 后台构建代码检索索引（CodeSearch使用），不阻塞启动
+	lc.Logger.Println("启动代码检索索引")
+	function.StartCodeIndex(wd, lc.Logger)
+
 	lc.RegisterAllFunction()
 
 	// 初始化新的Bubble Tea TUI
@@ -117,21 +149,6 @@ func GenLukatinCode(lmmconfig *general.LLMConfig, system_promote string) *Lukati
 	return lc
 }
 
-type FunctionParam struct {
-	Description string `json:"description"`
-	Type        string `json:"type"`
-}
-
-type FunctionParameters struct {
-	Properties map[string]FunctionParam `json:"properties"`
-	Required   []string                 `json:"required"`
-}
-
-type FunctionDescription struct {
-	Description string             `json:"description"`
-	Parameters  FunctionParameters `json:"parameters"`
-}
-
 // StartBubbleTUI 启动新的Bubble Tea TUI界面模式
 func (lc *LukatinCode) StartBubbleTUI() error {
 	lc.Logger.Println("启动Bubble Tea TUI界面模式")
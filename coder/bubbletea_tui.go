@@ -56,17 +56,27 @@ type (
 		description string
 	}
 	codeChangeMsg struct {
-		filePath    string
-		oldContent  string
-		newContent  string
-		operation   string // "edit", "multiedit", "write"
-		needConfirm bool
-		changeId    string
+		filePath     string
+		oldContent   string
+		newContent   string
+		operation    string // "edit", "multiedit", "write"
+		needConfirm  bool
+		changeId     string
+		autoApproved bool   // 由权限模式自动批准，仅展示不需确认
+		diffText     string // 预先生成的diff（如ApplyPatch），优先于oldContent/newContent显示
 	}
 	userConfirmMsg struct {
 		changeId string
 		approved bool
 	}
+	planApprovalMsg struct {
+		plan     string
+		changeId string
+	}
+	externalChangeMsg struct {
+		filePath string
+		detail   string // 已重新应用 / 已中止
+	}
 )
 
 // BubbleTeaTUI represents the new TUI using Bubble Tea
@@ -87,13 +97,20 @@ type BubbleTeaTUI struct {
 	status         string
 	showTodos      bool
 	todoUpdateTime time.Time
+	todoCursor     int    // 面板获得焦点时选中的事项
+	todoAdding     bool   // 正在输入新的待办事项
+	stashedInput   string // 输入新事项时暂存的输入框内容
+	subAgents      []function.SubAgentProgress // 本轮Task子代理的进度
+	subAgentCursor int                         // 子代理面板获得焦点时选中的子代理
+	subAgentExpanded map[string]bool           // 按子代理ID记录是否展开
 	
 	// Code change confirmation
 	pendingChanges   map[string]codeChangeMsg
 	responseChannels map[string]chan bool
 	waitingForConfirm bool
 	currentChangeId   string
-	uiMode           string // "normal", "confirm"
+	confirmKind       string // "change", "plan"
+	uiMode           string // "normal", "confirm", "todo", "agents"
 
 	// Styles
 	inputStyle     lipgloss.Style
@@ -109,11 +126,15 @@ type BubbleTeaTUI struct {
 	height int
 }
 
+// TodoItem 待办事项面板中的一项
 type TodoItem struct {
-	ID        int
-	Content   string
-	Status    string // pending, in_progress, completed
-	Timestamp string
+	ID         string
+	Content    string
+	Status     string    // pending, in_progress, completed
+	StartedAt  time.Time // 开始执行的时间
+	FinishedAt time.Time // 完成时间
+	Depth      int       // 子任务的层级
+	Blocked    []string  // 尚未完成的依赖
 }
 
 // NewBubbleTeaTUI creates a new Bubble Tea TUI
@@ -140,6 +161,7 @@ func NewBubbleTeaTUI(lukatinCode *LukatinCode) *BubbleTeaTUI {
 		todos:       []TodoItem{},
 		status:      "就绪",
 		showTodos:   false, // 默认隐藏TodoList
+		subAgentExpanded: make(map[string]bool),
 		
 		// Code change confirmation
 		pendingChanges:    make(map[string]codeChangeMsg),
@@ -182,20 +204,7 @@ func NewBubbleTeaTUI(lukatinCode *LukatinCode) *BubbleTeaTUI {
 	}
 
 	// 初始化确认列表
-	options := []list.Item{
-		confirmOption{
-			title: "✅ 确认执行修改",
-			desc:  "继续执行代码修改操作",
-			value: true,
-		},
-		confirmOption{
-			title: "❌ 取消修改",
-			desc:  "停止AI任务并等待新指令",
-			value: false,
-		},
-	}
-	
-	b.confirmList = list.New(options, list.NewDefaultDelegate(), 50, 10)
+	b.confirmList = list.New(changeConfirmOptions(), list.NewDefaultDelegate(), 50, 10)
 	b.confirmList.Title = "请选择操作"
 	b.confirmList.SetShowStatusBar(false)
 	b.confirmList.SetFilteringEnabled(false)
@@ -214,7 +223,11 @@ func (b *BubbleTeaTUI) Init() tea.Cmd {
 	// Add welcome message
 	b.addMessage("🚀 欢迎使用 LukatinCode!", "system")
 	b.addMessage("💡 输入消息开始对话，输入 'exit' 退出", "system")
-	b.addMessage("🔧 快捷键: ESC=取消AI任务, Ctrl+S=导出历史, Ctrl+L=清空历史, Ctrl+C=退出", "system")
+	b.addMessage("🔧 快捷键: ESC=取消AI任务, Shift+Tab=切换权限模式, Ctrl+T=待办事项面板, Tab=编辑待办事项, Ctrl+O=展开/折叠子代理, Ctrl+S=导出历史, Ctrl+L=清空历史, Ctrl+C=退出", "system")
+	b.addMessage("📝 命令: /todos 查看待办事项与状态变化（/todos all 显示完整历史）", "system")
+	if b.lukatinCode.resumed {
+		b.addMessage(fmt.Sprintf("♻️ 已恢复会话 %s 的待办事项", b.lukatinCode.SessionID), "system")
+	}
 	b.addMessage("🖱️  提示: 可以用鼠标选中文字然后右键复制或使用终端快捷键复制", "system")
 
 	return tea.Batch(
@@ -235,9 +248,8 @@ func (b *BubbleTeaTUI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		b.width = msg.Width
 		b.height = msg.Height
 
-		// Update viewport size
-		b.viewport.Width = msg.Width - 4
-		b.viewport.Height = msg.Height - 5
+		// Update viewport size（待办事项面板占用的空间由layout扣除）
+		b.layout()
 
 		// Update input width
 		b.input.Width = msg.Width - 4
@@ -245,6 +257,14 @@ func (b *BubbleTeaTUI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		b.lukatinCode.Logger.Printf("窗口大小变化: %dx%d", msg.Width, msg.Height)
 
 	case tea.KeyMsg:
+		// 待办事项面板获得焦点时按键由面板处理
+		if b.uiMode == "todo" {
+			return b.updateTodoMode(msg)
+		}
+		// 子代理面板获得焦点时同样由面板处理
+		if b.uiMode == "agents" {
+			return b.updateSubAgentMode(msg)
+		}
 		switch msg.String() {
 		case "ctrl+c":
 			b.lukatinCode.Logger.Println("用户请求退出")
@@ -257,12 +277,39 @@ func (b *BubbleTeaTUI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			return b, nil
 
+		case "ctrl+t":
+			// 显示/隐藏待办事项面板
+			b.toggleTodos()
+			return b, nil
+
+		case "ctrl+o":
+			// 焦点切换到子代理面板，逐个展开/折叠
+			if b.uiMode == "normal" {
+				b.enterSubAgentMode()
+			}
+			return b, nil
+
+		case "tab":
+			// 焦点切换到待办事项面板，可用键盘编辑
+			if b.uiMode == "normal" {
+				b.enterTodoMode()
+			}
+			return b, nil
+
 		case "ctrl+s":
 			// 导出对话历史到文件
 			b.lukatinCode.Logger.Println("用户请求导出对话历史")
 			go b.exportHistory()
 			return b, nil
 
+		case "shift+tab":
+			// 切换权限模式
+			if b.uiMode == "normal" {
+				mode := b.lukatinCode.CyclePermissionMode()
+				b.addMessage(fmt.Sprintf("🔐 权限模式: %s", mode.Label()), "system")
+			}
+			return b, nil
+
 		case "ctrl+l":
 			// 清空对话历史
 			b.lukatinCode.Logger.Println("用户请求清空对话历史")
@@ -294,10 +341,22 @@ func (b *BubbleTeaTUI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return b, tea.Quit
 			}
 
+			// /todos [all]：查看本会话各代理的待办事项和状态变化，不发送给模型
+			if fields := strings.Fields(input); fields[0] == "/todos" {
+				b.addMessage(fmt.Sprintf("👤 %s", input), "user")
+				b.input.SetValue("")
+				b.addMessage(function.TodoReport(len(fields) > 1 && fields[1] == "all"), "todolist")
+				return b, nil
+			}
+
 			b.lukatinCode.Logger.Printf("用户输入: %s", input)
 			b.addMessage(fmt.Sprintf("👤 %s", input), "user")
 			b.input.SetValue("")
 			b.isProcessing = true
+			// 清空上一轮的子代理
+			function.ResetSubAgents()
+			b.subAgents = nil
+			b.subAgentExpanded = make(map[string]bool)
 
 			// Process input asynchronously
 			go b.processInput(input)
@@ -319,6 +378,7 @@ func (b *BubbleTeaTUI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		b.isProcessing = false
 		b.status = "就绪"
+		b.layout() // 收起子代理面板
 
 	case toolCallMsg:
 		// 构建合并的工具调用显示
@@ -339,8 +399,8 @@ func (b *BubbleTeaTUI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 
-		// 如果包含TodoList操作，单独处理
-		if hasTodoCall {
+		// 如果包含TodoList操作，单独处理；面板打开时由面板显示
+		if hasTodoCall && !b.showTodos {
 			b.addMessage("🔧 TodoList 管理", "tool")
 			todoData := function.ListTodosFormatted()
 			b.addMessage(todoData, "todolist")
@@ -375,23 +435,60 @@ func (b *BubbleTeaTUI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case codeChangeMsg:
 		if msg.needConfirm {
+			if b.uiMode == "todo" {
+				b.leaveTodoMode()
+			}
+			if b.uiMode == "agents" {
+				b.leaveSubAgentMode()
+			}
 			// 存储待确认的修改
 			b.pendingChanges[msg.changeId] = msg
 			b.waitingForConfirm = true
 			b.currentChangeId = msg.changeId
+			b.confirmKind = "change"
+			b.confirmList.SetItems(changeConfirmOptions())
+			b.confirmList.Select(0)
 			b.uiMode = "confirm" // 切换到确认模式
 			
 			// 显示diff
 			b.showCodeChangeDiff(msg)
+		} else if msg.autoApproved {
+			// 权限模式自动批准：只展示diff
+			b.showCodeChangeDiff(msg)
+			b.addMessage(fmt.Sprintf("%s: 已自动批准", b.lukatinCode.GetPermissionMode().Label()), "system")
 		} else {
 			// 直接显示修改结果
 			b.showCodeChangeResult(msg)
 		}
 
+	case externalChangeMsg:
+		b.addMessage(fmt.Sprintf("⚠ 文件 %s 在等待确认期间被外部修改，%s", msg.filePath, msg.detail), "error")
+
+	case planApprovalMsg:
+		if b.uiMode == "todo" {
+			b.leaveTodoMode()
+		}
+		if b.uiMode == "agents" {
+			b.leaveSubAgentMode()
+		}
+		b.waitingForConfirm = true
+		b.currentChangeId = msg.changeId
+		b.confirmKind = "plan"
+		b.confirmList.SetItems(planConfirmOptions())
+		b.confirmList.Select(0)
+		b.uiMode = "confirm"
+		b.showPlan(msg.plan)
+
 	case userConfirmMsg:
 		// 向响应channel发送确认结果
 		if responseChan, exists := b.responseChannels[msg.changeId]; exists {
-			if msg.approved {
+			if b.confirmKind == "plan" {
+				if msg.approved {
+					b.addMessage("✅ 用户批准计划，退出计划模式", "system")
+				} else {
+					b.addMessage("❌ 用户拒绝计划，继续保持计划模式", "system")
+				}
+			} else if msg.approved {
 				b.addMessage("✅ 用户确认修改，正在执行...", "system")
 			} else {
 				b.addMessage("❌ 用户取消修改操作", "system")
@@ -415,6 +512,13 @@ func (b *BubbleTeaTUI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 	case tickMsg:
+		// 同步待办事项面板（耗时每秒更新）
+		if b.showTodos {
+			b.refreshTodos()
+		}
+		// 同步子代理进度
+		b.refreshSubAgents()
+		b.layout()
 		// 继续发送tick消息
 		return b, tea.Tick(time.Second, func(t time.Time) tea.Msg {
 			return tickMsg{}
@@ -458,6 +562,19 @@ func (b *BubbleTeaTUI) View() string {
 	// Status line
 	statusLine := b.renderStatus()
 
+	// 待办事项面板：右侧与对话区域并排，否则位于输入框上方
+	switch b.todoPanelMode() {
+	case "side":
+		content = lipgloss.JoinHorizontal(lipgloss.Top, content, b.renderTodos())
+	case "bottom", "line":
+		content = lipgloss.JoinVertical(lipgloss.Left, content, b.renderTodos())
+	}
+
+	// 子代理面板：对话进行中位于输入框正上方
+	if subAgents := b.renderSubAgents(); subAgents != "" {
+		content = lipgloss.JoinVertical(lipgloss.Left, content, subAgents)
+	}
+
 	// Simple vertical layout
 	return lipgloss.JoinVertical(
 		lipgloss.Left,
@@ -614,50 +731,22 @@ func (b *BubbleTeaTUI) formatTodoListMessage(message string) string {
 		Render(finalMessage)
 }
 
-// // renderTodos renders the todo list with checkboxes
-// func (b *BubbleTeaTUI) renderTodos() string {
-// 	if len(b.todos) == 0 {
-// 		return b.todoStyle.Render("📝 TodoList\n\n暂无任务")
-// 	}
-
-// 	// 直接显示TodoList原始数据
-// 	for _, todo := range b.todos {
-// 		if todo.Status == "display" {
-// 			return b.todoStyle.Render(todo.Content)
-// 		}
-// 	}
-
-// 	return b.todoStyle.Render("📝 TodoList\n\n数据加载中...")
-// }
-
 // renderStatus renders the status line
 func (b *BubbleTeaTUI) renderStatus() string {
+	if b.uiMode == "todo" {
+		return b.statusStyle.Render("📝 " + todoEditHelp)
+	}
+	if b.uiMode == "agents" {
+		return b.statusStyle.Render("🤖 " + subAgentHelp)
+	}
+	modeText := fmt.Sprintf(" | %s (shift+tab 切换)", b.lukatinCode.GetPermissionMode().Label())
 	if b.isProcessing {
 		return b.statusStyle.Render(
-			fmt.Sprintf("%s %s", b.spinner.View(), b.status),
+			fmt.Sprintf("%s %s%s", b.spinner.View(), b.status, modeText),
 		)
 	}
 
-	return b.statusStyle.Render(fmt.Sprintf("⚡ %s", b.status))
-}
-
-// refreshTodos updates the todo list from the function
-func (b *BubbleTeaTUI) refreshTodos() {
-	b.lukatinCode.Logger.Println("刷新TodoList")
-
-	// 获取最新的格式化TodoList数据
-	todoData := function.ListTodosFormatted()
-	b.lukatinCode.Logger.Printf("TodoList数据: %s", todoData)
-
-	// 简化解析：直接将原始数据存储显示
-	b.todos = []TodoItem{
-		{
-			ID:      1,
-			Content: todoData,
-			Status:  "display",
-		},
-	}
-	b.lukatinCode.Logger.Println("TodoList已更新")
+	return b.statusStyle.Render(fmt.Sprintf("⚡ %s%s", b.status, modeText))
 }
 
 // processInput handles user input asynchronously
@@ -781,10 +870,14 @@ func (b *BubbleTeaTUI) chatWithBubbleTea(input string) {
 		}
 	}()
 
-	model := b.lukatinCode.Lmmconfig.AgentAPIKey.OpenAI.Model
+	// 这一轮中调用的子代理使用同一个context，取消时一并中断
+	function.SetSubAgentContext(ctx)
+	defer function.SetSubAgentContext(nil)
+
+	model := b.lukatinCode.Model
 	b.lukatinCode.Logger.Printf("================== 开始网络请求 ==================")
 	b.lukatinCode.Logger.Printf("请求模型: %s", model)
-	b.lukatinCode.Logger.Printf("请求提供商: %s", general.ProviderOpenAI)
+	b.lukatinCode.Logger.Printf("请求提供商: %s", b.lukatinCode.Provider)
 	b.lukatinCode.Logger.Printf("输入文本长度: %d 字符", len(input))
 
 	// 记录网络请求开始时间
@@ -792,7 +885,7 @@ func (b *BubbleTeaTUI) chatWithBubbleTea(input string) {
 
 	// 构建已注册的工具列表
 	b.lukatinCode.CM.SetMaxFunctionCallingNums(10000000)
-	_, _, err, usage := b.lukatinCode.CM.Chat(ctx, general.ProviderOpenAI, model, input, []string{}, info_chan)
+	_, _, err, usage := b.lukatinCode.CM.Chat(ctx, b.lukatinCode.Provider, model, b.lukatinCode.withSystemReminders(input), []string{}, info_chan)
 	networkDuration := time.Since(networkStart)
 
 	// 总体耗时
@@ -1043,7 +1136,11 @@ func (b *BubbleTeaTUI) showCodeChangeDiff(change codeChangeMsg) {
 	b.addMessage(fmt.Sprintf("🔧 操作类型: %s", change.operation), "system")
 	
 	// 显示diff
-	if change.oldContent != "" && change.newContent != "" {
+	if change.diffText != "" {
+		b.addMessage("═══════════ DIFF ═══════════", "diff_header")
+		b.showUnifiedDiff(change.diffText)
+		b.addMessage("═══════════════════════════", "diff_header")
+	} else if change.oldContent != "" && change.newContent != "" {
 		b.addMessage("═══════════ DIFF ═══════════", "diff_header")
 		
 		// 更好的diff算法：查找实际变化的内容
@@ -1064,6 +1161,62 @@ func (b *BubbleTeaTUI) showCodeChangeDiff(change codeChangeMsg) {
 	}
 }
 
+// showUnifiedDiff 按行前缀为unified diff着色显示
+func (b *BubbleTeaTUI) showUnifiedDiff(diffText string) {
+	for _, line := range strings.Split(diffText, "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"), strings.HasPrefix(line, "@@"), strings.HasPrefix(line, "diff "):
+			b.addMessage(line, "diff_header")
+		case strings.HasPrefix(line, "+"):
+			b.addMessage(line, "diff_added")
+		case strings.HasPrefix(line, "-"):
+			b.addMessage(line, "diff_removed")
+		default:
+			b.addMessage(line, "system")
+		}
+	}
+}
+
+// showPlan 显示模型提交的计划，等待用户审批
+func (b *BubbleTeaTUI) showPlan(plan string) {
+	b.addMessage("📋 模型提交了计划，请审批:", "confirm")
+	b.addMessage("═══════════ PLAN ═══════════", "diff_header")
+	b.addMessage(plan, "assistant")
+	b.addMessage("═══════════════════════════", "diff_header")
+}
+
+// changeConfirmOptions 代码修改确认的选项
+func changeConfirmOptions() []list.Item {
+	return []list.Item{
+		confirmOption{
+			title: "✅ 确认执行修改",
+			desc:  "继续执行代码修改操作",
+			value: true,
+		},
+		confirmOption{
+			title: "❌ 取消修改",
+			desc:  "停止AI任务并等待新指令",
+			value: false,
+		},
+	}
+}
+
+// planConfirmOptions 计划审批的选项
+func planConfirmOptions() []list.Item {
+	return []list.Item{
+		confirmOption{
+			title: "✅ 批准计划",
+			desc:  "退出计划模式并开始执行",
+			value: true,
+		},
+		confirmOption{
+			title: "❌ 继续计划",
+			desc:  "保持计划模式，让模型修改计划",
+			value: false,
+		},
+	}
+}
+
 // showLineDiff 显示行级diff
 func (b *BubbleTeaTUI) showLineDiff(oldLines, newLines []string) {
 	// 找到第一个不同的行
@@ -1142,6 +1295,18 @@ func (b *BubbleTeaTUI) showCodeChangeResult(change codeChangeMsg) {
 		b.addMessage(fmt.Sprintf("✅ 文件 %s 批量修改完成", change.filePath), "success")
 	case "write":
 		b.addMessage(fmt.Sprintf("✅ 文件 %s 写入完成", change.filePath), "success")
+	case "patch":
+		b.addMessage(fmt.Sprintf("✅ 补丁已应用到 %s", change.filePath), "success")
+	case "multifile":
+		b.addMessage(fmt.Sprintf("✅ %s 跨文件修改完成", change.filePath), "success")
+	case "rename":
+		b.addMessage(fmt.Sprintf("✅ 重命名 %s 完成", change.filePath), "success")
+	case "move":
+		b.addMessage(fmt.Sprintf("✅ %s 移动完成", change.filePath), "success")
+	case "copy":
+		b.addMessage(fmt.Sprintf("✅ %s 复制完成", change.filePath), "success")
+	case "delete":
+		b.addMessage(fmt.Sprintf("✅ %s 已删除（备份在log/trash）", change.filePath), "success")
 	}
 }
 
@@ -1196,7 +1361,7 @@ func (b *BubbleTeaTUI) logNetworkPerformance(input, model string, networkDuratio
 
 	fmt.Fprintf(file, "%s | %s | %s | Input:%d chars | Network:%v | Total:%v | Ratio:%.1f%% | %s | %s\n",
 		timestamp,
-		general.ProviderOpenAI,
+		b.lukatinCode.Provider,
 		model,
 		len(input),
 		networkDuration,
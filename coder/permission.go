@@ -0,0 +1,256 @@
+package coder
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PermissionMode 权限模式，决定工具调用是否需要用户确认
+type PermissionMode string
+
+const (
+	PermissionDefault     PermissionMode = "default"           // 每次文件修改都需要确认
+	PermissionAcceptEdits PermissionMode = "acceptEdits"       // 自动批准项目目录内的文件修改
+	PermissionPlan        PermissionMode = "plan"              // 只允许只读工具，最后由模型提交计划供审批
+	PermissionBypass      PermissionMode = "bypassPermissions" // 跳过所有确认，需通过命令行参数开启
+)
+
+// planModeTools 计划模式下允许调用的只读工具
+var planModeTools = map[string]bool{
+	"Read":           true,
+	"Glob":           true,
+	"Grep":           true,
+	"LS":             true,
+	"FindDefinition": true,
+	"FindReferences": true,
+	"ListSymbols":    true,
+	"CodeSearch":     true,
+	"NotebookRead":   true,
+	"TodoRead":       true,
+	"TodoWrite":      true,
+	"WebFetch":       true,
+	"WebSearch":      true,
+	"ExitPlanMode":   true,
+}
+
+// Label 状态栏中显示的模式名称
+func (m PermissionMode) Label() string {
+	switch m {
+	case PermissionAcceptEdits:
+		return "⏵⏵ 自动接受编辑"
+	case PermissionPlan:
+		return "⏸ 计划模式"
+	case PermissionBypass:
+		return "⚠ 跳过权限确认"
+	default:
+		return "默认模式"
+	}
+}
+
+// GetPermissionMode 获取当前权限模式
+func (lc *LukatinCode) GetPermissionMode() PermissionMode {
+	lc.permMu.RLock()
+	defer lc.permMu.RUnlock()
+	return lc.permissionMode
+}
+
+// SetPermissionMode 设置权限模式，未开启bypass时拒绝切换到bypass
+func (lc *LukatinCode) SetPermissionMode(mode PermissionMode) error {
+	lc.permMu.Lock()
+	defer lc.permMu.Unlock()
+	if mode == PermissionBypass && !lc.allowBypass {
+		return fmt.Errorf("bypass模式需要通过 --dangerously-skip-permissions 参数启动")
+	}
+	lc.permissionMode = mode
+	lc.Logger.Printf("权限模式切换为: %s", mode)
+	return nil
+}
+
+// EnableBypassPermissions 允许bypass模式并立即切换到该模式（由命令行参数开启）
+func (lc *LukatinCode) EnableBypassPermissions() {
+	lc.permMu.Lock()
+	lc.allowBypass = true
+	lc.permMu.Unlock()
+	lc.SetPermissionMode(PermissionBypass)
+}
+
+// CyclePermissionMode 按 default -> acceptEdits -> plan -> bypass(若允许) 的顺序切换模式
+func (lc *LukatinCode) CyclePermissionMode() PermissionMode {
+	next := PermissionDefault
+	switch lc.GetPermissionMode() {
+	case PermissionDefault:
+		next = PermissionAcceptEdits
+	case PermissionAcceptEdits:
+		next = PermissionPlan
+	case PermissionPlan:
+		lc.permMu.RLock()
+		if lc.allowBypass {
+			next = PermissionBypass
+		}
+		lc.permMu.RUnlock()
+	}
+	lc.SetPermissionMode(next)
+	return next
+}
+
+// guardTool 包装工具函数，调用前先按当前权限模式检查是否允许执行。
+// 所有工具都只返回一个string，被拒绝时直接把原因作为工具结果返回给模型。
+func (lc *LukatinCode) guardTool(name string, fn interface{}) interface{} {
+	fnValue := reflect.ValueOf(fn)
+	fnType := fnValue.Type()
+	if fnType.Kind() != reflect.Func || fnType.NumOut() != 1 || fnType.Out(0).Kind() != reflect.String {
+		return fn
+	}
+
+	return reflect.MakeFunc(fnType, func(args []reflect.Value) []reflect.Value {
+		if msg := lc.checkToolPermission(name); msg != "" {
+			lc.Logger.Printf("工具 %s 被权限模式拒绝: %s", name, lc.GetPermissionMode())
+			return []reflect.Value{reflect.ValueOf(msg).Convert(fnType.Out(0))}
+		}
+		return fnValue.Call(args)
+	}).Interface()
+}
+
+// checkToolPermission 返回空字符串表示允许，否则返回拒绝原因
+func (lc *LukatinCode) checkToolPermission(name string) string {
+	if lc.GetPermissionMode() != PermissionPlan || planModeTools[name] {
+		return ""
+	}
+
+	var allowed []string
+	for tool := range planModeTools {
+		allowed = append(allowed, tool)
+	}
+	sort.Strings(allowed)
+	return fmt.Sprintf("Error: Plan mode is active, %s is not available. Only read-only tools are allowed (%s). Finish researching, then call ExitPlanMode with your plan so the user can approve it.",
+		name, strings.Join(allowed, ", "))
+}
+
+// isInsideProject 判断路径是否位于项目目录（启动时的工作目录）内
+func (lc *LukatinCode) isInsideProject(filePath string) bool {
+	if lc.ProjectRoot == "" {
+		return false
+	}
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(lc.ProjectRoot, absPath)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// canAutoApprove 判断涉及这些路径的修改是否可以跳过确认
+func (lc *LukatinCode) canAutoApprove(paths ...string) bool {
+	switch lc.GetPermissionMode() {
+	case PermissionBypass:
+		return true
+	case PermissionAcceptEdits:
+		for _, p := range paths {
+			if !lc.isInsideProject(p) {
+				return false
+			}
+		}
+		return len(paths) > 0
+	}
+	return false
+}
+
+// notifyAutoApproved 在UI中展示被自动批准的修改
+func (lc *LukatinCode) notifyAutoApproved(change codeChangeMsg) {
+	lc.Logger.Printf("权限模式 %s 自动批准修改: %s", lc.GetPermissionMode(), change.filePath)
+	if lc.BubbleTUI != nil && lc.BubbleTUI.program != nil {
+		change.needConfirm = false
+		change.autoApproved = true
+		lc.BubbleTUI.program.Send(change)
+	}
+}
+
+// autoApproveChange 判断文件修改是否可以跳过确认，可以时在UI中展示修改内容
+func (lc *LukatinCode) autoApproveChange(filePath, oldContent, newContent, operation string) bool {
+	if !lc.canAutoApprove(filePath) {
+		return false
+	}
+	lc.notifyAutoApproved(codeChangeMsg{
+		filePath:   filePath,
+		oldContent: oldContent,
+		newContent: newContent,
+		operation:  operation,
+	})
+	return true
+}
+
+// ExitPlanMode 计划模式下由模型调用，提交计划并等待用户审批
+func (lc *LukatinCode) ExitPlanMode(plan string) string {
+	if lc.GetPermissionMode() != PermissionPlan {
+		return "Plan mode is not active. Continue with the task."
+	}
+	if strings.TrimSpace(plan) == "" {
+		return "Error: plan cannot be empty"
+	}
+
+	lc.Logger.Printf("模型提交计划，等待用户审批，长度: %d", len(plan))
+	if !lc.requestPlanApproval(plan) {
+		lc.Logger.Println("用户拒绝了计划")
+		return "The user rejected the plan. Stay in plan mode: do not make any changes, revise the plan according to the user's feedback and call ExitPlanMode again."
+	}
+
+	lc.SetPermissionMode(PermissionDefault)
+	lc.permMu.Lock()
+	lc.lastAnnouncedMode = PermissionDefault
+	lc.permMu.Unlock()
+	lc.Logger.Println("用户批准了计划，退出计划模式")
+	return "The user approved the plan. Plan mode is now off and you can start implementing the plan."
+}
+
+// requestPlanApproval 请求用户审批计划
+func (lc *LukatinCode) requestPlanApproval(plan string) bool {
+	if lc.BubbleTUI == nil || lc.BubbleTUI.program == nil {
+		return true
+	}
+
+	changeId := fmt.Sprintf("plan_%d", time.Now().UnixNano())
+	responseChan := make(chan bool, 1)
+	lc.BubbleTUI.responseChannels[changeId] = responseChan
+
+	lc.BubbleTUI.program.Send(planApprovalMsg{
+		plan:     plan,
+		changeId: changeId,
+	})
+
+	// 阻塞等待用户审批
+	approved := <-responseChan
+	delete(lc.BubbleTUI.responseChannels, changeId)
+	return approved
+}
+
+// permissionReminder 生成需要告知模型的权限模式提醒
+func (lc *LukatinCode) permissionReminder() string {
+	// 读取当前模式和记录已告知的模式需在同一把锁内完成，Shift+Tab会在UI goroutine中切换模式
+	lc.permMu.Lock()
+	mode := lc.permissionMode
+	previous := lc.lastAnnouncedMode
+	lc.lastAnnouncedMode = mode
+	lc.permMu.Unlock()
+	changed := mode != previous
+
+	switch {
+	case mode == PermissionPlan:
+		return "Plan mode is active. The user does not want you to make any changes yet: do not edit files, run non-readonly commands or otherwise change the system. Only use read-only tools to research, then present your plan by calling ExitPlanMode and wait for the user's approval."
+	case changed && mode == PermissionAcceptEdits:
+		return "The user switched to accept-edits mode: file changes inside the project are applied without confirmation."
+	case changed && mode == PermissionBypass:
+		return "The user switched to bypass-permissions mode: all tool calls run without confirmation."
+	case changed && previous == PermissionPlan:
+		return "Plan mode is off. You can now make changes; file edits need the user's confirmation."
+	case changed:
+		return "The user switched back to default mode: file edits need the user's confirmation."
+	}
+	return ""
+}
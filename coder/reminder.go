@@ -0,0 +1,22 @@
+package coder
+
+import "strings"
+
+// withSystemReminders 在发送给模型的用户输入前附加系统提醒（权限模式等），UI中仍显示原始输入
+func (lc *LukatinCode) withSystemReminders(input string) string {
+	var reminders []string
+	if r := lc.permissionReminder(); r != "" {
+		reminders = append(reminders, r)
+	}
+	if r := lc.todoReminder(); r != "" {
+		reminders = append(reminders, r)
+	}
+	if r := lc.todoEditReminder(); r != "" {
+		reminders = append(reminders, r)
+	}
+
+	if len(reminders) == 0 {
+		return input
+	}
+	return "<system-reminder>\n" + strings.Join(reminders, "\n") + "\n</system-reminder>\n\n" + input
+}
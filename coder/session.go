@@ -0,0 +1,53 @@
+package coder
+
+import (
+	"fmt"
+	"lukatincode/function"
+)
+
+// 会话：每次启动是一个新会话，待办事项按会话保存；通过 --resume 继续之前的会话
+
+// startSession 开始一个新会话
+func (lc *LukatinCode) startSession() {
+	id := function.NewTodoSessionID()
+	if _, err := function.StartTodoSession(id); err != nil {
+		lc.Logger.Printf("开始会话失败: %v", err)
+	}
+	lc.SessionID = id
+	lc.Logger.Printf("会话ID: %s", id)
+}
+
+// ResumeSession 继续会话id（"latest"表示最近保存过的会话），恢复其待办事项
+func (lc *LukatinCode) ResumeSession(id string) error {
+	if id == "latest" {
+		latest, err := function.LatestTodoSession()
+		if err != nil {
+			return err
+		}
+		id = latest
+	}
+	restored, err := function.StartTodoSession(id)
+	if err != nil {
+		return err
+	}
+	if !restored {
+		return fmt.Errorf("会话 %s 不存在", id)
+	}
+	lc.SessionID = id
+	lc.resumed = true
+	lc.Logger.Printf("恢复会话: %s", id)
+	return nil
+}
+
+// todoReminder 恢复会话后的第一轮告知模型之前的待办事项
+func (lc *LukatinCode) todoReminder() string {
+	if !lc.resumed || lc.todosAnnounced {
+		return ""
+	}
+	lc.todosAnnounced = true
+	todos := function.TodoRead()
+	if todos == "暂无待办事项" {
+		return ""
+	}
+	return "This session was resumed. Your todo list from the previous run has been restored (use TodoRead/TodoWrite to continue it):\n" + todos
+}
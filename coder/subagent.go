@@ -0,0 +1,47 @@
+package coder
+
+import (
+	"encoding/json"
+	"lukatincode/function"
+
+	"github.com/ccIisIaIcat/GoAgent/agent/general"
+)
+
+// task 注册给模型的Task：先在后台启动最近一条回复中的所有Task调用，使它们并行执行，
+// 再等待这一次调用的结果
+func (lc *LukatinCode) task(description string, prompt string, subagentType string) string {
+	if calls := lc.pendingTaskCalls(); len(calls) > 1 {
+		lc.Logger.Printf("并行启动%d个Task子代理", len(calls))
+		function.StartTasks(calls)
+	}
+	return function.Task(description, prompt, subagentType)
+}
+
+// pendingTaskCalls 最近一条助手消息中的Task调用。工具在Chat所在的goroutine中依次执行，
+// 此时读取历史是安全的
+func (lc *LukatinCode) pendingTaskCalls() []function.TaskCall {
+	history := lc.CM.GetHistory()
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role != general.RoleAssistant {
+			continue
+		}
+		var calls []function.TaskCall
+		for _, toolCall := range history[i].ToolCalls {
+			if toolCall.Function.Name != "Task" {
+				continue
+			}
+			var req function.TaskRequest
+			if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &req); err != nil {
+				continue
+			}
+			calls = append(calls, function.TaskCall{
+				ID:           toolCall.ID,
+				Description:  req.Description,
+				Prompt:       req.Prompt,
+				SubagentType: req.SubagentType,
+			})
+		}
+		return calls
+	}
+	return nil
+}
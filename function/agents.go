@@ -0,0 +1,218 @@
+package function
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// 自定义代理类型：.lukatin/agents/*.md，开头的front matter声明名称、描述、可用工具和模型，
+// 正文（或front matter中的system_prompt）作为系统提示词。Task通过subagent_type选择代理类型。
+
+// AgentsDir 自定义代理定义所在的目录，相对于项目根目录
+const AgentsDir = ".lukatin/agents"
+
+// GeneralPurposeAgent 内置的通用代理，未指定subagent_type时使用
+const GeneralPurposeAgent = "general-purpose"
+
+// TaskTools 子代理可以使用的工具，也是通用代理的工具列表。
+// 子代理的工具调用不经过用户确认和权限模式，Move/Copy/Delete等破坏性的文件操作只留给主代理
+var TaskTools = []string{"Bash", "Glob", "Grep", "LS", "FindDefinition", "FindReferences", "ListSymbols", "CodeSearch", "Read", "Edit", "MultiEdit", "Write", "ApplyPatch", "MultiFileEdit", "RenameSymbol", "NotebookRead", "NotebookEdit", "WebFetch", "TodoRead", "TodoWrite", "WebSearch"}
+
+// TaskParams Task函数签名中的参数顺序
+var TaskParams = []string{"description", "prompt", "subagent_type"}
+
+// AgentDefinition 一种子代理
+type AgentDefinition struct {
+	Name         string
+	Description  string
+	Tools        []string // 可用的工具，取自TaskTools
+	Model        string   // "model" 或 "provider:model"，为空时按agentModels选择
+	SystemPrompt string
+	Path         string // 定义文件，内置代理为空
+}
+
+// agentFrontMatter 定义文件的front matter；tools可以是列表或逗号分隔的字符串
+type agentFrontMatter struct {
+	Name         string      `yaml:"name"`
+	Description  string      `yaml:"description"`
+	Tools        interface{} `yaml:"tools"`
+	Model        string      `yaml:"model"`
+	SystemPrompt string      `yaml:"system_prompt"`
+}
+
+var agentNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// generalPurposeAgent 内置的通用代理：全部工具和通用的系统提示词
+func generalPurposeAgent() AgentDefinition {
+	return AgentDefinition{
+		Name:         GeneralPurposeAgent,
+		Description:  "General-purpose agent for researching complex questions, searching for code, and executing multi-step tasks.",
+		Tools:        TaskTools,
+		SystemPrompt: "You are a helpful AI assistant that can perform various tasks using available tools.",
+	}
+}
+
+// LoadAgentDefinitions 返回内置通用代理和AgentsDir中的自定义代理（按名称排序）；
+// 无法解析的定义文件被跳过，原因在errs中返回
+func LoadAgentDefinitions() (agents []AgentDefinition, errs []error) {
+	agents = []AgentDefinition{generalPurposeAgent()}
+	paths, err := filepath.Glob(filepath.Join(AgentsDir, "*.md"))
+	if err != nil {
+		return agents, []error{err}
+	}
+	sort.Strings(paths)
+
+	seen := map[string]string{GeneralPurposeAgent: "内置代理"}
+	for _, path := range paths {
+		agent, err := parseAgentDefinition(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", path, err))
+			continue
+		}
+		if other, exists := seen[agent.Name]; exists {
+			errs = append(errs, fmt.Errorf("%s: 代理名称 %s 与 %s 重复", path, agent.Name, other))
+			continue
+		}
+		seen[agent.Name] = path
+		agents = append(agents, agent)
+	}
+	sort.SliceStable(agents[1:], func(i, j int) bool { return agents[i+1].Name < agents[j+1].Name })
+	return agents, errs
+}
+
+// FindAgentDefinition 按名称查找代理类型，name为空时返回通用代理
+func FindAgentDefinition(name string) (AgentDefinition, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = GeneralPurposeAgent
+	}
+	agents, _ := LoadAgentDefinitions()
+	var names []string
+	for _, agent := range agents {
+		if agent.Name == name {
+			return agent, nil
+		}
+		names = append(names, agent.Name)
+	}
+	return AgentDefinition{}, fmt.Errorf("unknown subagent_type %q, available agent types: %s", name, strings.Join(names, ", "))
+}
+
+// parseAgentDefinition 解析一个定义文件；未写name时使用文件名
+func parseAgentDefinition(path string) (AgentDefinition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return AgentDefinition{}, err
+	}
+	content := strings.TrimPrefix(strings.ReplaceAll(string(data), "\r\n", "\n"), "\ufeff")
+	lines := strings.Split(content, "\n")
+	if strings.TrimSpace(lines[0]) != "---" {
+		return AgentDefinition{}, fmt.Errorf("缺少以 --- 开头的front matter")
+	}
+	end := -1
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "---" {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return AgentDefinition{}, fmt.Errorf("front matter 没有以 --- 结束")
+	}
+	header := strings.Join(lines[1:end], "\n")
+	body := strings.Join(lines[end+1:], "\n")
+
+	var fm agentFrontMatter
+	if err := yaml.Unmarshal([]byte(header), &fm); err != nil {
+		return AgentDefinition{}, fmt.Errorf("front matter 解析失败: %v", err)
+	}
+
+	agent := AgentDefinition{
+		Name:         strings.TrimSpace(fm.Name),
+		Description:  strings.TrimSpace(fm.Description),
+		Model:        strings.TrimSpace(fm.Model),
+		SystemPrompt: strings.TrimSpace(fm.SystemPrompt),
+		Path:         path,
+	}
+	if agent.Name == "" {
+		agent.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if !agentNamePattern.MatchString(agent.Name) {
+		return AgentDefinition{}, fmt.Errorf("代理名称 %q 只能包含小写字母、数字、- 和 _", agent.Name)
+	}
+	if agent.Description == "" {
+		return AgentDefinition{}, fmt.Errorf("缺少 description")
+	}
+	if agent.SystemPrompt == "" {
+		agent.SystemPrompt = strings.TrimSpace(body)
+	}
+	if agent.SystemPrompt == "" {
+		return AgentDefinition{}, fmt.Errorf("缺少系统提示词（正文或 system_prompt）")
+	}
+	agent.Tools, err = parseAgentTools(fm.Tools)
+	if err != nil {
+		return AgentDefinition{}, err
+	}
+	return agent, nil
+}
+
+// parseAgentTools 解析tools字段，未填写时可以使用全部TaskTools
+func parseAgentTools(value interface{}) ([]string, error) {
+	var names []string
+	switch v := value.(type) {
+	case nil:
+		return TaskTools, nil
+	case string:
+		for _, name := range strings.Split(v, ",") {
+			names = append(names, strings.TrimSpace(name))
+		}
+	case []interface{}:
+		for _, item := range v {
+			names = append(names, strings.TrimSpace(fmt.Sprint(item)))
+		}
+	default:
+		return nil, fmt.Errorf("tools 应为列表或逗号分隔的字符串")
+	}
+
+	known := make(map[string]bool, len(TaskTools))
+	for _, tool := range TaskTools {
+		known[tool] = true
+	}
+	var tools []string
+	seen := map[string]bool{}
+	for _, name := range names {
+		if name == "" || seen[name] {
+			continue
+		}
+		if !known[name] {
+			return nil, fmt.Errorf("未知的工具 %q，可用的工具: %s", name, strings.Join(TaskTools, ", "))
+		}
+		seen[name] = true
+		tools = append(tools, name)
+	}
+	if len(tools) == 0 {
+		return nil, fmt.Errorf("tools 不能为空")
+	}
+	return tools, nil
+}
+
+// TaskDescription 在Task工具描述后附上可用的代理类型及其工具
+func TaskDescription(base string) string {
+	agents, _ := LoadAgentDefinitions()
+	var sb strings.Builder
+	sb.WriteString(base)
+	sb.WriteString("\n\nAvailable agent types (pass one as subagent_type; defaults to " + GeneralPurposeAgent + "):\n")
+	for _, agent := range agents {
+		tools := "all tools"
+		if len(agent.Tools) != len(TaskTools) {
+			tools = strings.Join(agent.Tools, ", ")
+		}
+		fmt.Fprintf(&sb, "- %s: %s (Tools: %s)\n", agent.Name, agent.Description, tools)
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
@@ -0,0 +1,768 @@
+package function
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PatchLine 补丁hunk中的一行，Op为 ' '、'-' 或 '+'
+type PatchLine struct {
+	Op   byte
+	Text string
+}
+
+// PatchHunk 一个 @@ ... @@ 片段
+type PatchHunk struct {
+	Header   string
+	OldStart int
+	OldCount int
+	NewStart int
+	NewCount int
+	Lines    []PatchLine
+	OldNoEOL bool // 旧内容最后一行没有换行
+	NewNoEOL bool // 新内容最后一行没有换行
+}
+
+// FilePatch 补丁中针对单个文件的部分
+type FilePatch struct {
+	OldPath string // /dev/null 表示新建
+	NewPath string // /dev/null 表示删除
+	Hunks   []*PatchHunk
+}
+
+// PatchedFile 预先计算好的单个文件修改结果，确认后再写入磁盘
+type PatchedFile struct {
+	Path       string // 修改后的文件路径（删除时为被删除的文件）
+	OldPath    string // 重命名前的路径，其他情况与Path相同
+	Operation  string // "modify", "create", "delete", "rename"
+	OldContent string
+	NewContent string
+	Mode       os.FileMode
+	Format     TextFormat // 原文件的编码、BOM与换行符，写回时保持
+	Notes      []string   // 偏移、模糊匹配等提示
+	oldData    []byte     // 原始字节，回滚时使用
+	verifyPath string     // 确认后检查外部修改的路径，为空时为OldPath；随移动修改的文件在提交前仍位于移动前的位置
+}
+
+const (
+	devNull       = "/dev/null"
+	maxPatchFuzz  = 2 // 最多忽略hunk首尾各2行上下文
+	hunkMatchNone = -1
+)
+
+var hunkHeaderRegex = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// ParsePatch 解析标准unified diff（兼容git diff的扩展头），可包含多个文件
+func ParsePatch(patch string) ([]*FilePatch, error) {
+	lines := strings.Split(strings.ReplaceAll(patch, "\r\n", "\n"), "\n")
+	var files []*FilePatch
+	var current *FilePatch
+	var hunk *PatchHunk
+	gitHeader := false
+	// 当前hunk按 @@ 头中的行数还剩多少旧/新行；未用完之前以 "--- "/"+++ " 开头的行
+	// 是删除/新增的内容（如删除 "-- x" 这一行），不是下一个文件的头。
+	// 模型写的补丁常把行数多算，所以紧跟 @@ 的 "--- "/"+++ " 总是当作文件头
+	oldLeft, newLeft := 0, 0
+
+	finishHunk := func() {
+		if hunk == nil {
+			return
+		}
+		// 去掉hunk末尾的空行（通常是补丁文本末尾多余的换行）
+		for len(hunk.Lines) > 0 {
+			last := hunk.Lines[len(hunk.Lines)-1]
+			if last.Op != ' ' || last.Text != "" {
+				break
+			}
+			hunk.Lines = hunk.Lines[:len(hunk.Lines)-1]
+		}
+		current.Hunks = append(current.Hunks, hunk)
+		hunk = nil
+	}
+	startFile := func() {
+		finishHunk()
+		current = &FilePatch{}
+		files = append(files, current)
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			startFile()
+			gitHeader = true
+			if oldPath, newPath, ok := parseGitDiffHeader(line); ok {
+				current.OldPath, current.NewPath = oldPath, newPath
+			}
+		case hunk == nil && current != nil && strings.HasPrefix(line, "new file mode"):
+			current.OldPath = devNull
+		case hunk == nil && current != nil && strings.HasPrefix(line, "deleted file mode"):
+			current.NewPath = devNull
+		case hunk == nil && current != nil && strings.HasPrefix(line, "rename from "):
+			current.OldPath = strings.TrimSpace(strings.TrimPrefix(line, "rename from "))
+		case hunk == nil && current != nil && strings.HasPrefix(line, "rename to "):
+			current.NewPath = strings.TrimSpace(strings.TrimPrefix(line, "rename to "))
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ") &&
+			(hunk == nil || (oldLeft <= 0 && newLeft <= 0) || (i+2 < len(lines) && strings.HasPrefix(lines[i+2], "@@"))):
+			// 没有git头，或git头之后已经出现过hunk，说明是新文件
+			if current == nil || hunk != nil || len(current.Hunks) > 0 || !gitHeader {
+				startFile()
+				gitHeader = false
+			}
+			finishHunk()
+			current.OldPath = parsePatchPath(strings.TrimPrefix(line, "--- "))
+			current.NewPath = parsePatchPath(strings.TrimPrefix(lines[i+1], "+++ "))
+			i++
+		case strings.HasPrefix(line, "@@"):
+			if current == nil {
+				return nil, fmt.Errorf("line %d: hunk header found before any file header (--- / +++)", i+1)
+			}
+			finishHunk()
+			m := hunkHeaderRegex.FindStringSubmatch(line)
+			if m == nil {
+				return nil, fmt.Errorf("line %d: invalid hunk header: %s", i+1, line)
+			}
+			hunk = &PatchHunk{Header: line}
+			hunk.OldStart, _ = strconv.Atoi(m[1])
+			hunk.OldCount = 1
+			if m[2] != "" {
+				hunk.OldCount, _ = strconv.Atoi(m[2])
+			}
+			hunk.NewStart, _ = strconv.Atoi(m[3])
+			hunk.NewCount = 1
+			if m[4] != "" {
+				hunk.NewCount, _ = strconv.Atoi(m[4])
+			}
+			oldLeft, newLeft = hunk.OldCount, hunk.NewCount
+		case hunk != nil && strings.HasPrefix(line, `\`):
+			// "\ No newline at end of file" 作用于上一行
+			if len(hunk.Lines) > 0 {
+				switch hunk.Lines[len(hunk.Lines)-1].Op {
+				case '-':
+					hunk.OldNoEOL = true
+				case '+':
+					hunk.NewNoEOL = true
+				default:
+					hunk.OldNoEOL = true
+					hunk.NewNoEOL = true
+				}
+			}
+		case hunk != nil && line == "":
+			// 模型生成的补丁常把空白上下文行的前导空格省略
+			hunk.Lines = append(hunk.Lines, PatchLine{Op: ' ', Text: ""})
+			oldLeft--
+			newLeft--
+		case hunk != nil && (line[0] == ' ' || line[0] == '-' || line[0] == '+'):
+			hunk.Lines = append(hunk.Lines, PatchLine{Op: line[0], Text: line[1:]})
+			switch line[0] {
+			case ' ':
+				oldLeft--
+				newLeft--
+			case '-':
+				oldLeft--
+			case '+':
+				newLeft--
+			}
+		case hunk != nil:
+			// hunk之后的其他文本视为hunk结束
+			finishHunk()
+		}
+	}
+	finishHunk()
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no file headers found; the patch must be a unified diff with --- and +++ lines")
+	}
+	for _, fp := range files {
+		if fp.OldPath == "" || fp.NewPath == "" {
+			return nil, fmt.Errorf("could not determine file paths for one of the files in the patch")
+		}
+		if gitStyle := strings.HasPrefix(fp.OldPath, "a/") || strings.HasPrefix(fp.NewPath, "b/"); gitStyle {
+			fp.OldPath = stripPatchPrefix(fp.OldPath, "a/")
+			fp.NewPath = stripPatchPrefix(fp.NewPath, "b/")
+		}
+	}
+	return files, nil
+}
+
+// parseGitDiffHeader 解析 "diff --git a/x b/y"
+func parseGitDiffHeader(line string) (string, string, bool) {
+	rest := strings.TrimPrefix(line, "diff --git ")
+	idx := strings.Index(rest, " b/")
+	if idx < 0 {
+		return "", "", false
+	}
+	return rest[:idx], rest[idx+1:], true
+}
+
+// parsePatchPath 去掉 ---/+++ 行中路径后面的时间戳
+func parsePatchPath(s string) string {
+	if idx := strings.Index(s, "\t"); idx >= 0 {
+		s = s[:idx]
+	}
+	s = strings.TrimSpace(s)
+	return strings.Trim(s, `"`)
+}
+
+// stripPatchPrefix 去掉git风格的 a/ b/ 前缀，但保留确实以 a/ b/ 开头的真实路径
+func stripPatchPrefix(path, prefix string) string {
+	if path == devNull || !strings.HasPrefix(path, prefix) {
+		return path
+	}
+	if _, err := os.Stat(path); err == nil {
+		if _, err := os.Stat(strings.TrimPrefix(path, prefix)); err != nil {
+			return path
+		}
+	}
+	return strings.TrimPrefix(path, prefix)
+}
+
+// PreparePatch 解析补丁并在内存中计算每个文件的新内容，不写磁盘。
+// 任意hunk失败时返回包含所有失败hunk详情的错误，整个补丁不会被应用。
+func PreparePatch(patch string) ([]*PatchedFile, error) {
+	filePatches, err := ParsePatch(patch)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing patch: %v", err)
+	}
+
+	var results []*PatchedFile
+	var failures []string
+	seen := make(map[string]bool)
+
+	for _, fp := range filePatches {
+		pf, fileFailures, err := prepareFilePatch(fp)
+		if err != nil {
+			failures = append(failures, err.Error())
+			continue
+		}
+		if len(fileFailures) > 0 {
+			failures = append(failures, fileFailures...)
+			continue
+		}
+		paths := []string{pf.Path}
+		if pf.OldPath != pf.Path {
+			paths = append(paths, pf.OldPath)
+		}
+		for _, p := range paths {
+			if seen[p] {
+				failures = append(failures, fmt.Sprintf("%s: file appears more than once in the patch; combine its hunks into a single file section", p))
+			}
+			seen[p] = true
+		}
+		results = append(results, pf)
+	}
+
+	if len(failures) > 0 {
+		return nil, fmt.Errorf("Error: patch could not be applied, no files were changed.\n%s", strings.Join(failures, "\n"))
+	}
+	return results, nil
+}
+
+// prepareFilePatch 计算单个文件的修改结果，返回hunk级别的失败描述
+func prepareFilePatch(fp *FilePatch) (*PatchedFile, []string, error) {
+	switch {
+	case fp.OldPath == devNull && fp.NewPath == devNull:
+		return nil, nil, fmt.Errorf("invalid file section: both paths are /dev/null")
+
+	case fp.OldPath == devNull:
+		path, err := filepath.Abs(fp.NewPath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: error resolving path: %v", fp.NewPath, err)
+		}
+		if _, err := os.Stat(path); err == nil {
+			return nil, nil, fmt.Errorf("%s: cannot create file, it already exists", path)
+		}
+		var added []string
+		noEOL := false
+		for _, h := range fp.Hunks {
+			for _, l := range h.Lines {
+				if l.Op != '-' {
+					added = append(added, l.Text)
+				}
+			}
+			noEOL = noEOL || h.NewNoEOL
+		}
+		content := strings.Join(added, "\n")
+		if len(added) > 0 && !noEOL {
+			content += "\n"
+		}
+		return &PatchedFile{Path: path, OldPath: path, Operation: "create", NewContent: content, Mode: 0644, Format: DefaultTextFormat()}, nil, nil
+	}
+
+	oldPath, err := filepath.Abs(fp.OldPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: error resolving path: %v", fp.OldPath, err)
+	}
+	info, err := os.Stat(oldPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, fmt.Errorf("%s: file does not exist", oldPath)
+		}
+		return nil, nil, fmt.Errorf("%s: error checking file: %v", oldPath, err)
+	}
+	if info.IsDir() {
+		return nil, nil, fmt.Errorf("%s: is a directory", oldPath)
+	}
+	if err := CheckFileFresh(oldPath); err != nil {
+		return nil, nil, err
+	}
+	data, err := os.ReadFile(oldPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: error reading file: %v", oldPath, err)
+	}
+	oldContent, format := DecodeText(data)
+
+	pf := &PatchedFile{Path: oldPath, OldPath: oldPath, Operation: "modify", OldContent: oldContent, Mode: info.Mode(), Format: format, oldData: data}
+	if fp.NewPath == devNull {
+		pf.Operation = "delete"
+		return pf, nil, nil
+	}
+
+	newPath, err := filepath.Abs(fp.NewPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: error resolving path: %v", fp.NewPath, err)
+	}
+	if newPath != oldPath {
+		if _, err := os.Stat(newPath); err == nil {
+			return nil, nil, fmt.Errorf("%s: cannot rename %s, target already exists", newPath, oldPath)
+		}
+		pf.Path = newPath
+		pf.Operation = "rename"
+	}
+
+	newContent, notes, failures := applyHunks(oldContent, fp.Hunks)
+	if len(failures) > 0 {
+		for i := range failures {
+			failures[i] = fmt.Sprintf("%s: %s", oldPath, failures[i])
+		}
+		return nil, failures, nil
+	}
+	if _, err := EncodeText(newContent, format); err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", oldPath, err)
+	}
+	pf.NewContent = newContent
+	pf.Notes = notes
+	return pf, nil, nil
+}
+
+// applyHunks 依次应用hunk（content已统一为LF），支持行号偏移、空白差异和上下文模糊匹配
+func applyHunks(content string, hunks []*PatchHunk) (string, []string, []string) {
+	trailingNewline := strings.HasSuffix(content, "\n")
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	if content == "" {
+		lines = nil
+	}
+
+	var notes, failures []string
+	delta := 0  // 已应用hunk造成的行数变化
+	minPos := 0 // 下一个hunk只能匹配在上一个hunk之后
+
+	for n, h := range hunks {
+		label := fmt.Sprintf("hunk #%d (%s)", n+1, h.Header)
+		expected := h.OldStart - 1 + delta
+		if h.OldCount == 0 {
+			// 纯插入hunk的起始行表示插入到该行之后
+			expected = h.OldStart + delta
+		}
+
+		pos, trimmed, mode := locateHunk(lines, h.Lines, expected, minPos)
+		if pos == hunkMatchNone {
+			failures = append(failures, describeHunkFailure(label, lines, h.Lines, expected, minPos))
+			continue
+		}
+
+		if offset := pos - expected; offset != 0 {
+			notes = append(notes, fmt.Sprintf("%s applied at line %d (offset %+d lines)", label, pos+1, offset))
+		}
+		if trimmed > 0 {
+			notes = append(notes, fmt.Sprintf("%s applied with fuzz %d (ignored %d context line(s) at each end)", label, trimmed, trimmed))
+		}
+		if mode != matchExact {
+			notes = append(notes, fmt.Sprintf("%s matched ignoring %s", label, mode))
+		}
+
+		body := h.Lines[trimmed : len(h.Lines)-trimmed]
+		var replacement []string
+		fileIdx := pos
+		for _, l := range body {
+			switch l.Op {
+			case ' ':
+				replacement = append(replacement, lines[fileIdx]) // 保留文件中的原始上下文
+				fileIdx++
+			case '-':
+				fileIdx++
+			case '+':
+				replacement = append(replacement, l.Text)
+			}
+		}
+
+		newLines := make([]string, 0, len(lines)+len(replacement))
+		newLines = append(newLines, lines[:pos]...)
+		newLines = append(newLines, replacement...)
+		newLines = append(newLines, lines[fileIdx:]...)
+		lines = newLines
+
+		delta += len(replacement) - (fileIdx - pos)
+		minPos = pos + len(replacement)
+
+		if h.NewNoEOL {
+			trailingNewline = false
+		} else if h.OldNoEOL {
+			trailingNewline = true
+		}
+	}
+
+	if len(failures) > 0 {
+		return "", notes, failures
+	}
+
+	result := strings.Join(lines, "\n")
+	if trailingNewline && len(lines) > 0 {
+		result += "\n"
+	}
+	return result, notes, nil
+}
+
+type hunkMatchMode string
+
+const (
+	matchExact      hunkMatchMode = ""
+	matchTrailingWS hunkMatchMode = "trailing whitespace"
+	matchAllWS      hunkMatchMode = "whitespace differences"
+)
+
+// hunkOldLines 返回hunk在修改前应有的行（上下文+删除行）
+func hunkOldLines(body []PatchLine) []string {
+	var old []string
+	for _, l := range body {
+		if l.Op != '+' {
+			old = append(old, l.Text)
+		}
+	}
+	return old
+}
+
+// leadingContext 统计hunk开头和结尾的上下文行数
+func leadingContext(body []PatchLine) (int, int) {
+	lead, trail := 0, 0
+	for lead < len(body) && body[lead].Op == ' ' {
+		lead++
+	}
+	for trail < len(body)-lead && body[len(body)-1-trail].Op == ' ' {
+		trail++
+	}
+	return lead, trail
+}
+
+// locateHunk 在文件中查找hunk位置：先精确匹配，再忽略空白，最后逐步丢弃首尾上下文。
+// 同等条件下选择离期望行号最近的位置。
+func locateHunk(lines []string, body []PatchLine, expected, minPos int) (int, int, hunkMatchMode) {
+	lead, trail := leadingContext(body)
+	for fuzz := 0; fuzz <= maxPatchFuzz; fuzz++ {
+		if fuzz > 0 && (fuzz > lead || fuzz > trail) {
+			break
+		}
+		old := hunkOldLines(body[fuzz : len(body)-fuzz])
+		for _, mode := range []hunkMatchMode{matchExact, matchTrailingWS, matchAllWS} {
+			if pos := searchLines(lines, old, expected+fuzz, minPos, mode); pos != hunkMatchNone {
+				return pos, fuzz, mode
+			}
+		}
+	}
+	return hunkMatchNone, 0, matchExact
+}
+
+// searchLines 从期望位置向两侧搜索匹配的行块
+func searchLines(lines, old []string, expected, minPos int, mode hunkMatchMode) int {
+	maxPos := len(lines) - len(old)
+	if maxPos < minPos {
+		return hunkMatchNone
+	}
+	if expected < minPos {
+		expected = minPos
+	}
+	if expected > maxPos {
+		expected = maxPos
+	}
+	for dist := 0; ; dist++ {
+		before, after := expected-dist, expected+dist
+		if before < minPos && after > maxPos {
+			return hunkMatchNone
+		}
+		if after <= maxPos && linesMatchAt(lines, old, after, mode) {
+			return after
+		}
+		if dist > 0 && before >= minPos && linesMatchAt(lines, old, before, mode) {
+			return before
+		}
+	}
+}
+
+func linesMatchAt(lines, old []string, pos int, mode hunkMatchMode) bool {
+	for i, want := range old {
+		if !lineEqual(lines[pos+i], want, mode) {
+			return false
+		}
+	}
+	return true
+}
+
+func lineEqual(got, want string, mode hunkMatchMode) bool {
+	switch mode {
+	case matchTrailingWS:
+		return strings.TrimRight(got, " \t\r") == strings.TrimRight(want, " \t\r")
+	case matchAllWS:
+		return strings.Join(strings.Fields(got), " ") == strings.Join(strings.Fields(want), " ")
+	default:
+		return got == want
+	}
+}
+
+// describeHunkFailure 给出失败原因：最接近的位置、匹配行数以及第一处不一致的行
+func describeHunkFailure(label string, lines []string, body []PatchLine, expected, minPos int) string {
+	old := hunkOldLines(body)
+	if len(old) == 0 {
+		return fmt.Sprintf("%s FAILED: insertion point line %d is outside the file (%d lines)", label, expected, len(lines))
+	}
+
+	bestPos, bestScore := hunkMatchNone, 0
+	for pos := minPos; pos+len(old) <= len(lines); pos++ {
+		score := 0
+		for i, want := range old {
+			if lineEqual(lines[pos+i], want, matchAllWS) {
+				score++
+			}
+		}
+		if score > bestScore || (score == bestScore && score > 0 && absInt(pos-expected) < absInt(bestPos-expected)) {
+			bestPos, bestScore = pos, score
+		}
+	}
+
+	if bestPos == hunkMatchNone {
+		return fmt.Sprintf("%s FAILED: none of the %d context/removed lines were found in the file (expected near line %d)", label, len(old), expected+1)
+	}
+	for i, want := range old {
+		if got := lines[bestPos+i]; !lineEqual(got, want, matchAllWS) {
+			return fmt.Sprintf("%s FAILED: context/removed lines not found. Closest match at line %d (%d/%d lines match); line %d differs: patch expects %q, file has %q",
+				label, bestPos+1, bestScore, len(old), bestPos+i+1, want, got)
+		}
+	}
+	return fmt.Sprintf("%s FAILED: lines match at line %d but conflict with a previous hunk", label, bestPos+1)
+}
+
+func absInt(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// CommitPatch 把预先计算好的修改写入磁盘，全部成功或全部不变：
+// 先把所有新内容写入目标目录中的临时文件，全部写好后再依次重命名到位；
+// 任何一步失败都会删除临时文件并恢复已经替换的文件。
+func CommitPatch(files []*PatchedFile) error {
+	// 1. 写入临时文件，此时磁盘上的原文件还未改动
+	staged := make(map[*PatchedFile]string)
+	cleanup := func() {
+		for _, tmp := range staged {
+			os.Remove(tmp)
+		}
+	}
+	for _, pf := range files {
+		if pf.Operation == "delete" {
+			continue
+		}
+		tmp, err := stageFile(pf)
+		if err != nil {
+			cleanup()
+			return fmt.Errorf("Error applying patch to %s: %v (no files were changed)", pf.Path, err)
+		}
+		staged[pf] = tmp
+	}
+
+	// 2. 重命名到位
+	var done []*PatchedFile
+	rollback := func() {
+		for i := len(done) - 1; i >= 0; i-- {
+			pf := done[i]
+			switch pf.Operation {
+			case "create":
+				os.Remove(pf.Path)
+			case "rename":
+				os.Remove(pf.Path)
+				os.WriteFile(pf.OldPath, pf.oldData, pf.Mode)
+			default:
+				os.WriteFile(pf.OldPath, pf.oldData, pf.Mode)
+			}
+		}
+	}
+	for _, pf := range files {
+		var err error
+		switch pf.Operation {
+		case "delete":
+			err = os.Remove(pf.Path)
+		case "rename":
+			if err = os.Rename(staged[pf], pf.Path); err == nil {
+				delete(staged, pf)
+				if err = os.Remove(pf.OldPath); err != nil {
+					os.Remove(pf.Path)
+				}
+			}
+		default:
+			if err = os.Rename(staged[pf], pf.Path); err == nil {
+				delete(staged, pf)
+			}
+		}
+		if err != nil {
+			cleanup()
+			rollback()
+			return fmt.Errorf("Error applying patch to %s: %v (all changes were rolled back)", pf.Path, err)
+		}
+		done = append(done, pf)
+	}
+
+	for _, pf := range files {
+		if pf.Operation == "delete" || pf.Operation == "rename" {
+			ForgetFileState(pf.OldPath)
+		}
+		if pf.Operation != "delete" {
+			RecordFileWrite(pf.Path)
+		}
+	}
+	return nil
+}
+
+// ChangedOnDisk 返回在计算修改之后（如等待确认期间）被外部修改的文件
+func ChangedOnDisk(files []*PatchedFile) []string {
+	var changed []string
+	for _, pf := range files {
+		original := pf.oldData
+		if pf.Operation == "create" {
+			original = nil
+		}
+		path := pf.OldPath
+		if pf.verifyPath != "" {
+			path = pf.verifyPath
+		}
+		if _, diff, err := ReverifyFile(path, original); diff || err != nil {
+			changed = append(changed, path)
+		}
+	}
+	return changed
+}
+
+// stageFile 把新内容写入目标目录下的临时文件，返回临时文件路径
+func stageFile(pf *PatchedFile) (string, error) {
+	data, err := EncodeText(pf.NewContent, pf.Format)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Dir(pf.Path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(pf.Path)+".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	mode := pf.Mode
+	if mode == 0 {
+		mode = 0644
+	}
+	if err := os.Chmod(tmp.Name(), mode.Perm()); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// PatchedPaths 补丁应用后仍然存在的文件
+func PatchedPaths(files []*PatchedFile) []string {
+	var paths []string
+	for _, pf := range files {
+		if pf.Operation != "delete" {
+			paths = append(paths, pf.Path)
+		}
+	}
+	return paths
+}
+
+// FormatPatchResult 生成返回给模型的结果摘要
+func FormatPatchResult(files []*PatchedFile) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Successfully applied patch to %d file(s):\n", len(files)))
+	for _, pf := range files {
+		switch pf.Operation {
+		case "create":
+			sb.WriteString(fmt.Sprintf("- created %s\n", pf.Path))
+		case "delete":
+			sb.WriteString(fmt.Sprintf("- deleted %s\n", pf.Path))
+		case "rename":
+			sb.WriteString(fmt.Sprintf("- renamed %s -> %s\n", pf.OldPath, pf.Path))
+		default:
+			sb.WriteString(fmt.Sprintf("- modified %s\n", pf.Path))
+		}
+		for _, note := range pf.Notes {
+			sb.WriteString(fmt.Sprintf("  note: %s\n", note))
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// ApplyPatch 应用unified diff补丁（无UI确认，供Task子代理使用）
+func ApplyPatch(patch string) string {
+	start := time.Now()
+
+	// 记录日志
+	logFile, err := os.OpenFile("./log/applypatch.txt", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	var logger *log.Logger
+	if err == nil {
+		defer logFile.Close()
+		logger = log.New(logFile, "", log.LstdFlags)
+		logger.Printf("ApplyPatch函数调用 - patch_length: %d", len(patch))
+		defer func() {
+			logger.Printf("ApplyPatch函数执行完成 - 耗时: %v", time.Since(start))
+		}()
+	}
+
+	if strings.TrimSpace(patch) == "" {
+		return "Error: patch cannot be empty"
+	}
+
+	files, err := PreparePatch(patch)
+	if err != nil {
+		if logger != nil {
+			logger.Printf("ApplyPatch函数返回 - 补丁校验失败: %v", err)
+		}
+		return err.Error()
+	}
+
+	if err := CommitPatch(files); err != nil {
+		if logger != nil {
+			logger.Printf("ApplyPatch函数返回 - 写入失败: %v", err)
+		}
+		return err.Error()
+	}
+
+	result := FormatPatchResult(files)
+	result += RunPostEditHooks(PatchedPaths(files)...)
+	if logger != nil {
+		logger.Printf("ApplyPatch函数返回 - 成功: %d个文件", len(files))
+	}
+	return result
+}
@@ -0,0 +1,107 @@
+package function
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// patchSummary 每个文件一项：路径和各hunk的行（操作符加内容）
+type patchSummary struct {
+	OldPath, NewPath string
+	Hunks            [][]string
+}
+
+func summarizePatch(files []*FilePatch) []patchSummary {
+	var out []patchSummary
+	for _, fp := range files {
+		s := patchSummary{OldPath: fp.OldPath, NewPath: fp.NewPath}
+		for _, h := range fp.Hunks {
+			var lines []string
+			for _, l := range h.Lines {
+				lines = append(lines, string(l.Op)+l.Text)
+			}
+			s.Hunks = append(s.Hunks, lines)
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+func TestParsePatchFileHeaders(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch []string
+		want  []patchSummary
+	}{
+		{
+			name: "exact counts",
+			patch: []string{
+				"--- a.txt", "+++ a.txt", "@@ -1,2 +1,2 @@", " one", "-two", "+TWO",
+				"--- b.txt", "+++ b.txt", "@@ -1 +1 @@", "-x", "+y",
+			},
+			want: []patchSummary{
+				{"a.txt", "a.txt", [][]string{{" one", "-two", "+TWO"}}},
+				{"b.txt", "b.txt", [][]string{{"-x", "+y"}}},
+			},
+		},
+		{
+			// 行数多算：a.txt的hunk声明5行，实际只有4行
+			name: "over-counted hunk",
+			patch: []string{
+				"--- a.txt", "+++ a.txt", "@@ -1,5 +1,5 @@", " one", " two", "-three", "+THREE", " four",
+				"--- b.txt", "+++ b.txt", "@@ -1,2 +1,2 @@", " x", "-y", "+Y",
+			},
+			want: []patchSummary{
+				{"a.txt", "a.txt", [][]string{{" one", " two", "-three", "+THREE", " four"}}},
+				{"b.txt", "b.txt", [][]string{{" x", "-y", "+Y"}}},
+			},
+		},
+		{
+			// 行数少算：声明1行，实际多出的行仍属于该hunk
+			name: "under-counted hunk",
+			patch: []string{
+				"--- a.txt", "+++ a.txt", "@@ -1 +1 @@", " one", "-two", "+TWO", " three",
+				"--- b.txt", "+++ b.txt", "@@ -1 +1 @@", "-x", "+y",
+			},
+			want: []patchSummary{
+				{"a.txt", "a.txt", [][]string{{" one", "-two", "+TWO", " three"}}},
+				{"b.txt", "b.txt", [][]string{{"-x", "+y"}}},
+			},
+		},
+		{
+			// 删除 "-- x"、新增 "++ y" 的内容行不是文件头
+			name: "body lines starting with -- and ++",
+			patch: []string{
+				"--- a.sql", "+++ a.sql", "@@ -1,3 +1,3 @@", " select 1;", "--- old comment", "+++ new comment", " select 2;",
+				"--- b.txt", "+++ b.txt", "@@ -1 +1 @@", "-x", "+y",
+			},
+			want: []patchSummary{
+				{"a.sql", "a.sql", [][]string{{" select 1;", "--- old comment", "+++ new comment", " select 2;"}}},
+				{"b.txt", "b.txt", [][]string{{"-x", "+y"}}},
+			},
+		},
+		{
+			name: "git style with multiple hunks",
+			patch: []string{
+				"diff --git a/a.txt b/a.txt", "--- a/a.txt", "+++ b/a.txt",
+				"@@ -1,2 +1,2 @@", "-one", "+ONE", " two",
+				"@@ -10,2 +10,2 @@", " ten", "-eleven", "+ELEVEN",
+			},
+			want: []patchSummary{
+				{"a.txt", "a.txt", [][]string{{"-one", "+ONE", " two"}, {" ten", "-eleven", "+ELEVEN"}}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := ParsePatch(strings.Join(tt.patch, "\n") + "\n")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := summarizePatch(files); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParsePatch:\n got %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package function
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ChangeHistoryFile 文件移动/复制/删除等操作的变更历史，每行一条JSON记录
+const ChangeHistoryFile = "./log/changes.jsonl"
+
+// ChangeTrashDir 删除的文件先移动到这里，可从变更历史中找到并恢复
+const ChangeTrashDir = "./log/trash"
+
+// ChangeRecord 变更历史中的一条记录
+type ChangeRecord struct {
+	Time        time.Time `json:"time"`
+	Operation   string    `json:"operation"`
+	Source      string    `json:"source"`
+	Destination string    `json:"destination,omitempty"`
+	Backup      string    `json:"backup,omitempty"`  // 删除前的备份位置
+	Updated     []string  `json:"updated,omitempty"` // 随操作一起修改的文件（如更新import）
+}
+
+var changeHistoryMu sync.Mutex
+
+// RecordChange 追加一条变更记录
+func RecordChange(record ChangeRecord) error {
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	changeHistoryMu.Lock()
+	defer changeHistoryMu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(ChangeHistoryFile), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(ChangeHistoryFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
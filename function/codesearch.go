@@ -0,0 +1,188 @@
+package function
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"lukatincode/index"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 代码检索：在启动时后台构建的BM25索引上查询自然语言或关键词，返回按相关度排序的代码片段
+
+const (
+	codeSearchDefaultLimit = 10
+	codeSearchMaxLimit     = 50
+	snippetLines           = 5   // 每个结果显示的行数
+	snippetMaxLineLen      = 200 // 片段中单行的最大长度
+)
+
+// CodeSearchParams CodeSearch函数签名中的参数顺序
+var CodeSearchParams = []string{"query", "path", "limit"}
+
+var (
+	codeIndexMu sync.Mutex
+	codeIndex   *index.Index
+)
+
+// StartCodeIndex 为root（项目根目录）在后台加载、构建并监听代码索引，只生效一次
+func StartCodeIndex(root string, logger *log.Logger) {
+	codeIndexMu.Lock()
+	defer codeIndexMu.Unlock()
+	if codeIndex != nil {
+		return
+	}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		abs = root
+	}
+	if logger == nil {
+		logger = log.New(io.Discard, "", 0)
+	}
+	codeIndex = index.New(abs)
+	codeIndex.Start(logger)
+}
+
+func currentCodeIndex() *index.Index {
+	codeIndexMu.Lock()
+	idx := codeIndex
+	codeIndexMu.Unlock()
+	if idx == nil {
+		// 未在启动时创建（如单独调用工具）：以当前目录为根目录启动
+		StartCodeIndex(".", nil)
+		codeIndexMu.Lock()
+		idx = codeIndex
+		codeIndexMu.Unlock()
+	}
+	return idx
+}
+
+// CodeSearch 在代码索引中检索query，path限定目录或文件，limit为返回的结果数
+func CodeSearch(query string, path string, limit int) string {
+	start := time.Now()
+	logFile, _ := os.OpenFile("./log/codesearch.txt", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	var logger *log.Logger
+	if logFile != nil {
+		defer logFile.Close()
+		logger = log.New(logFile, "", log.LstdFlags)
+		logger.Printf("CodeSearch函数调用 - query: %q, path: %q, limit: %d", query, path, limit)
+	}
+
+	if strings.TrimSpace(query) == "" {
+		return "Error: query is required"
+	}
+	if limit <= 0 {
+		limit = codeSearchDefaultLimit
+	}
+	if limit > codeSearchMaxLimit {
+		limit = codeSearchMaxLimit
+	}
+
+	idx := currentCodeIndex()
+	prefix := ""
+	if strings.TrimSpace(path) != "" {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return fmt.Sprintf("Error: invalid path: %v", err)
+		}
+		rel, err := filepath.Rel(idx.Root, abs)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return fmt.Sprintf("Error: path %s is outside the indexed workspace %s", path, idx.Root)
+		}
+		prefix = filepath.ToSlash(rel)
+	}
+
+	hits, terms := idx.Search(query, prefix, limit)
+	if logger != nil {
+		logger.Printf("CodeSearch函数返回 - 查询词: %v, %d个结果, 耗时: %v", terms, len(hits), time.Since(start))
+	}
+
+	var sb strings.Builder
+	ready, building, scanned := idx.Status()
+	if !ready || building {
+		files, _ := idx.Stats()
+		sb.WriteString(fmt.Sprintf("[The search index is still being built (%d files indexed, %d scanned in this pass); results may be incomplete. Use Grep for an exhaustive search.]\n", files, scanned))
+	}
+	if len(terms) == 0 {
+		sb.WriteString(fmt.Sprintf("No searchable words in query %q. Use identifiers or descriptive words.", query))
+		return sb.String()
+	}
+	if len(hits) == 0 {
+		sb.WriteString(fmt.Sprintf("No results for %q (terms: %s). Try other words, or Grep for an exact pattern.", query, strings.Join(terms, ", ")))
+		return sb.String()
+	}
+
+	sb.WriteString(fmt.Sprintf("Found %d results for %q (terms: %s), most relevant first:\n", len(hits), query, strings.Join(terms, ", ")))
+	for _, hit := range hits {
+		sb.WriteString(fmt.Sprintf("\n%s:%d-%d (score %.2f, matched: %s)\n", hit.File, hit.Start, hit.End, hit.Score, strings.Join(hit.Terms, ", ")))
+		sb.WriteString(codeSnippet(idx, hit))
+	}
+	return sb.String()
+}
+
+// codeSnippet 从块中选出包含查询词最多（按IDF加权）的连续snippetLines行
+func codeSnippet(idx *index.Index, hit index.Hit) string {
+	content, err := os.ReadFile(filepath.Join(idx.Root, filepath.FromSlash(hit.File)))
+	if err != nil {
+		return fmt.Sprintf("  (unable to read file: %v)\n", err)
+	}
+	lines := strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n")
+	if hit.Start > len(lines) {
+		return "  (file changed since it was indexed)\n"
+	}
+	end := hit.End
+	if end > len(lines) {
+		end = len(lines)
+	}
+	block := lines[hit.Start-1 : end]
+
+	weights := make(map[string]float64, len(hit.Terms))
+	for _, term := range hit.Terms {
+		weights[term] = idx.IDF(term)
+	}
+	lineScores := make([]float64, len(block))
+	for i, line := range block {
+		seen := make(map[string]bool)
+		for _, term := range index.Tokenize(line) {
+			if w, ok := weights[term]; ok && !seen[term] {
+				seen[term] = true
+				lineScores[i] += w
+			}
+		}
+	}
+
+	// 从包含查询词的行开始取得分最高的窗口；都不含查询词（只有路径命中）时从块首第一个非空行开始
+	best, bestScore := -1, 0.0
+	for i := range block {
+		if lineScores[i] == 0 {
+			continue
+		}
+		score := 0.0
+		for j := i; j < i+snippetLines && j < len(block); j++ {
+			score += lineScores[j]
+		}
+		if score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	if best < 0 {
+		best = 0
+		for best < len(block)-1 && strings.TrimSpace(block[best]) == "" {
+			best++
+		}
+	}
+
+	var sb strings.Builder
+	for j := best; j < best+snippetLines && j < len(block); j++ {
+		line := strings.TrimRight(block[j], " \t")
+		if runes := []rune(line); len(runes) > snippetMaxLineLen {
+			line = string(runes[:snippetMaxLineLen]) + "..."
+		}
+		sb.WriteString(fmt.Sprintf("%6d\t%s\n", hit.Start+j, line))
+	}
+	return sb.String()
+}
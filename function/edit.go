@@ -10,9 +10,6 @@ import (
 	"time"
 )
 
-// 用于跟踪Read工具的使用情况
-var readHistory = make(map[string]time.Time)
-
 func Edit(file_path string, old_string string, new_string string, replace_all bool, expected_replacements int) string {
 	start := time.Now()
 
@@ -51,17 +48,12 @@ func Edit(file_path string, old_string string, new_string string, replace_all bo
 		return fmt.Sprintf("Error checking file: %v", err)
 	}
 
-	// 检查是否在最近5分钟内使用过Read工具读取此文件
-	if lastRead, exists := readHistory[file_path]; exists {
-		if time.Since(lastRead) > 5*time.Minute {
-			if logger != nil {
-				logger.Printf("警告: 文件 %s 上次读取时间超过5分钟，建议先使用Read工具", file_path)
-			}
-		}
-	} else {
+	// 检查文件已被Read且之后未被修改
+	if err := CheckFileFresh(file_path); err != nil {
 		if logger != nil {
-			logger.Printf("警告: 未检测到对文件 %s 的Read操作历史，建议先使用Read工具", file_path)
+			logger.Printf("Edit函数返回 - 读取状态校验失败: %v", err)
 		}
+		return err.Error()
 	}
 
 	// 3. 处理来自Read工具的行号前缀
@@ -72,46 +64,31 @@ func Edit(file_path string, old_string string, new_string string, replace_all bo
 		}
 		old_string = cleanedOldString
 	}
+	// 统一为LF，与解码后的文件内容对齐
+	old_string = NormalizeLineEndings(old_string)
+	new_string = NormalizeLineEndings(new_string)
 
-	// 4. 读取文件内容
+	// 4. 读取文件内容（检测编码、BOM与换行符，写回时保持不变）
 	content, err := os.ReadFile(file_path)
 	if err != nil {
 		return fmt.Sprintf("Error reading file: %v", err)
 	}
 
 	originalSize := len(content)
-	contentStr := string(content)
+	contentStr, format := DecodeText(content)
 
-	// 5. 计算匹配次数
-	count := strings.Count(contentStr, old_string)
-	if count == 0 {
-		return fmt.Sprintf("Error: old_string not found in file. Searched for: %q", old_string)
-	}
-
-	// 6. 验证期望的替换次数
-	if expected_replacements <= 0 {
-		if replace_all {
-			expected_replacements = count // replace_all时，期望替换所有匹配
-		} else {
-			expected_replacements = 1 // 默认期望替换1次
+	// 5-7. 匹配并执行替换：精确匹配失败时依次尝试空白归一化和缩进相对匹配
+	match, err := ApplyEditMatch(contentStr, old_string, new_string, replace_all, expected_replacements)
+	if err != nil {
+		if logger != nil {
+			logger.Printf("Edit函数返回 - 匹配失败: %v", err)
 		}
+		return err.Error()
 	}
-
-	if !replace_all && count != expected_replacements {
-		return fmt.Sprintf("Error: Expected %d replacements but found %d occurrences. Use replace_all=true to replace all %d occurrences", expected_replacements, count, count)
-	}
-
-	// 7. 执行替换
-	var newContent string
-	var actualReplacements int
-
-	if replace_all {
-		newContent = strings.ReplaceAll(contentStr, old_string, new_string)
-		actualReplacements = count
-	} else {
-		// 只替换第一个匹配
-		newContent = strings.Replace(contentStr, old_string, new_string, 1)
-		actualReplacements = 1
+	newContent := match.NewContent
+	actualReplacements := match.Replaced
+	if match.Fuzzy() && logger != nil {
+		logger.Printf("使用模糊匹配: %s", match.Describe())
 	}
 
 	// 8. 创建备份（如果文件较大或替换较多）
@@ -124,17 +101,28 @@ func Edit(file_path string, old_string string, new_string string, replace_all bo
 		}
 	}
 
-	// 9. 写入新内容
-	err = os.WriteFile(file_path, []byte(newContent), fileInfo.Mode())
+	// 9. 按原格式编码并写入新内容
+	newData, err := EncodeText(newContent, format)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	err = os.WriteFile(file_path, newData, fileInfo.Mode())
 	if err != nil {
 		return fmt.Sprintf("Error writing file: %v", err)
 	}
+	RecordFileWrite(file_path)
 
 	// 10. 返回结果
-	newSize := len(newContent)
+	newSize := len(newData)
 	sizeDelta := newSize - originalSize
 	result := fmt.Sprintf("Successfully made %d replacement(s) in %s. Size changed by %+d bytes (%d -> %d)",
 		actualReplacements, filepath.Base(file_path), sizeDelta, originalSize, newSize)
+	if match.Fuzzy() {
+		result += fmt.Sprintf("\nNote: old_string did not match exactly; used %s. Read the file again if you need its exact current text.", match.Describe())
+	}
+
+	// 执行项目设置中的格式化/检查hook，诊断信息附加到结果中
+	result += RunPostEditHooks(file_path)
 
 	if logger != nil {
 		logger.Printf("Edit函数返回 - 成功编辑: %s", result)
@@ -156,8 +144,3 @@ func cleanLineNumberPrefix(text string) string {
 
 	return strings.Join(cleanedLines, "\n")
 }
-
-// 供Read函数调用，记录文件读取历史
-func RecordReadHistory(file_path string) {
-	readHistory[file_path] = time.Now()
-}
@@ -0,0 +1,392 @@
+package function
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EditMatchStrategy old_string匹配时使用的策略
+type EditMatchStrategy string
+
+const (
+	MatchExactString     EditMatchStrategy = "exact"
+	MatchNormalizedSpace EditMatchStrategy = "normalized-whitespace"
+	MatchIndentRelative  EditMatchStrategy = "indentation-relative"
+)
+
+// EditMatchResult 一次替换的结果
+type EditMatchResult struct {
+	NewContent string
+	Count      int               // 找到的匹配次数
+	Replaced   int               // 实际替换次数
+	Strategy   EditMatchStrategy // 使用的匹配策略
+	Lines      []int             // 模糊匹配时每处匹配的起始行（从1开始）
+}
+
+// Fuzzy 是否使用了非精确匹配
+func (r *EditMatchResult) Fuzzy() bool {
+	return r.Strategy != MatchExactString
+}
+
+// Describe 描述模糊匹配的位置，精确匹配时返回空字符串
+func (r *EditMatchResult) Describe() string {
+	if !r.Fuzzy() {
+		return ""
+	}
+	var lines []string
+	for _, l := range r.Lines {
+		lines = append(lines, fmt.Sprint(l))
+	}
+	return fmt.Sprintf("%s match at line %s", r.Strategy, strings.Join(lines, ", "))
+}
+
+// lineMatch 以整行为单位的匹配区间 [start, end)
+type lineMatch struct {
+	start, end int
+	indentMap  map[string]string // 缩进相对匹配时 old_string缩进 -> 文件缩进
+	oldBase    string            // old_string中最浅的缩进
+	fileBase   string            // 文件中与之对应的缩进
+	oldUnit    int               // old_string一级缩进的宽度
+	fileUnit   string            // 文件中一级缩进的写法
+}
+
+// ApplyEditMatch 依次尝试精确匹配、空白归一化匹配和缩进相对匹配，并执行替换。
+// expected<=0 时默认1；replaceAll时替换所有匹配。
+func ApplyEditMatch(content, oldString, newString string, replaceAll bool, expected int) (*EditMatchResult, error) {
+	if expected <= 0 {
+		expected = 1
+	}
+
+	// 1. 精确匹配
+	if count := strings.Count(content, oldString); count > 0 {
+		if err := checkMatchCount(count, expected, replaceAll); err != nil {
+			return nil, err
+		}
+		n := expected
+		if replaceAll {
+			n = count
+		}
+		return &EditMatchResult{
+			NewContent: strings.Replace(content, oldString, newString, n),
+			Count:      count,
+			Replaced:   n,
+			Strategy:   MatchExactString,
+		}, nil
+	}
+
+	// 2/3. 以整行为单位的模糊匹配
+	fileLines := strings.Split(content, "\n")
+	oldLines, oldTrailing := splitEditLines(oldString)
+	if len(oldLines) == 0 {
+		return nil, fmt.Errorf("Error: old_string not found in file. Searched for: %q", oldString)
+	}
+
+	for _, strategy := range []EditMatchStrategy{MatchNormalizedSpace, MatchIndentRelative} {
+		matches := findLineMatches(fileLines, oldLines, strategy)
+		if len(matches) == 0 {
+			continue
+		}
+		if err := checkMatchCount(len(matches), expected, replaceAll); err != nil {
+			return nil, err
+		}
+		n := expected
+		if replaceAll {
+			n = len(matches)
+		}
+		newContent, lines := replaceLineMatches(fileLines, matches[:n], newString, oldTrailing, strategy)
+		return &EditMatchResult{
+			NewContent: newContent,
+			Count:      len(matches),
+			Replaced:   n,
+			Strategy:   strategy,
+			Lines:      lines,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("Error: old_string not found in file (tried exact, whitespace-normalized and indentation-relative matching). Searched for: %q%s",
+		oldString, closestCandidate(fileLines, oldLines))
+}
+
+// MatchCountError old_string的匹配次数与期望的替换次数不一致
+type MatchCountError struct {
+	Expected, Found int
+}
+
+func (e *MatchCountError) Error() string {
+	return e.Message(true)
+}
+
+// Message 错误信息；调用方没有replace_all参数（如MultiEdit）时改为提示expected_replacements
+func (e *MatchCountError) Message(replaceAll bool) string {
+	msg := fmt.Sprintf("Error: Expected %d replacements but found %d occurrences.", e.Expected, e.Found)
+	if replaceAll {
+		return msg + fmt.Sprintf(" Use replace_all=true to replace all %d occurrences", e.Found)
+	}
+	return msg + fmt.Sprintf(" Set expected_replacements to %d to replace all of them, or add surrounding context to old_string to match a single occurrence", e.Found)
+}
+
+func checkMatchCount(count, expected int, replaceAll bool) error {
+	if !replaceAll && count != expected {
+		return &MatchCountError{Expected: expected, Found: count}
+	}
+	return nil
+}
+
+// splitEditLines 把old_string拆成行，去掉末尾换行（记录下来，替换时同样处理new_string）
+func splitEditLines(s string) ([]string, bool) {
+	trailing := strings.HasSuffix(s, "\n")
+	s = strings.TrimSuffix(s, "\n")
+	if strings.TrimSpace(s) == "" {
+		return nil, trailing
+	}
+	return strings.Split(s, "\n"), trailing
+}
+
+// normalizeSpace 去掉行尾空白并把行内连续空白合并为一个空格，保留行首缩进
+func normalizeSpace(line string) string {
+	trimmed := strings.TrimLeft(line, " \t")
+	indent := line[:len(line)-len(trimmed)]
+	return indent + strings.Join(strings.Fields(trimmed), " ")
+}
+
+// leadingIndent 返回行首的空白
+func leadingIndent(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+// indentWidth 缩进宽度，tab按4列计算
+func indentWidth(indent string) int {
+	width := 0
+	for _, c := range indent {
+		if c == '\t' {
+			width += 4
+		} else {
+			width++
+		}
+	}
+	return width
+}
+
+// indentUnit 非空行缩进宽度之间的最小正差值，即一级缩进的宽度
+func indentUnit(lines []string) int {
+	unit := 0
+	var widths []int
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			widths = append(widths, indentWidth(leadingIndent(line)))
+		}
+	}
+	for i := range widths {
+		for j := range widths {
+			if d := widths[i] - widths[j]; d > 0 && (unit == 0 || d < unit) {
+				unit = d
+			}
+		}
+	}
+	return unit
+}
+
+// findLineMatches 查找所有不重叠的整行匹配
+func findLineMatches(fileLines, oldLines []string, strategy EditMatchStrategy) []lineMatch {
+	n := len(oldLines)
+	normalizedOld := make([]string, n)
+	for i, line := range oldLines {
+		if strategy == MatchIndentRelative {
+			normalizedOld[i] = strings.Join(strings.Fields(line), " ")
+		} else {
+			normalizedOld[i] = normalizeSpace(line)
+		}
+	}
+
+	var matches []lineMatch
+	for start := 0; start+n <= len(fileLines); start++ {
+		window := fileLines[start : start+n]
+		ok := true
+		for i, line := range window {
+			if strings.TrimSpace(line) == "" && strings.TrimSpace(oldLines[i]) == "" {
+				continue
+			}
+			got := normalizeSpace(line)
+			if strategy == MatchIndentRelative {
+				got = strings.Join(strings.Fields(line), " ")
+			}
+			if got != normalizedOld[i] {
+				ok = false
+				break
+			}
+		}
+		if !ok {
+			continue
+		}
+
+		m := lineMatch{start: start, end: start + n}
+		if strategy == MatchIndentRelative {
+			var matched bool
+			if m, matched = relativeIndentMatch(window, oldLines, m); !matched {
+				continue
+			}
+		}
+		matches = append(matches, m)
+		start += n - 1
+	}
+	return matches
+}
+
+// relativeIndentMatch 校验文件与old_string的缩进层次一致（同一缩进始终对应同一缩进，且深浅顺序相同），
+// 并记录用于重新缩进new_string的映射
+func relativeIndentMatch(window, oldLines []string, m lineMatch) (lineMatch, bool) {
+	m.indentMap = make(map[string]string)
+	reverse := make(map[string]string)
+	baseWidth := -1
+	for i, line := range window {
+		if strings.TrimSpace(line) == "" || strings.TrimSpace(oldLines[i]) == "" {
+			continue
+		}
+		oldIndent, fileIndent := leadingIndent(oldLines[i]), leadingIndent(line)
+		if mapped, ok := m.indentMap[oldIndent]; ok && mapped != fileIndent {
+			return m, false
+		}
+		if mapped, ok := reverse[fileIndent]; ok && mapped != oldIndent {
+			return m, false
+		}
+		m.indentMap[oldIndent] = fileIndent
+		reverse[fileIndent] = oldIndent
+		if baseWidth < 0 || indentWidth(oldIndent) < baseWidth {
+			baseWidth = indentWidth(oldIndent)
+			m.oldBase, m.fileBase = oldIndent, fileIndent
+		}
+	}
+	// 缩进深浅顺序必须一致
+	for oldA, fileA := range m.indentMap {
+		for oldB, fileB := range m.indentMap {
+			if (indentWidth(oldA) < indentWidth(oldB)) != (indentWidth(fileA) < indentWidth(fileB)) {
+				return m, false
+			}
+		}
+	}
+
+	m.oldUnit = indentUnit(oldLines)
+	if m.oldUnit == 0 {
+		m.oldUnit = 4
+	}
+	m.fileUnit = "    "
+	if strings.Contains(strings.Join(window, "\n"), "\n\t") || strings.HasPrefix(window[0], "\t") {
+		m.fileUnit = "\t"
+	} else if unit := indentUnit(window); unit > 0 {
+		m.fileUnit = strings.Repeat(" ", unit)
+	}
+	return m, true
+}
+
+// replaceLineMatches 用new_string替换匹配的行，缩进相对匹配时按文件中的缩进重新缩进new_string
+func replaceLineMatches(fileLines []string, matches []lineMatch, newString string, oldTrailing bool, strategy EditMatchStrategy) (string, []int) {
+	if oldTrailing {
+		newString = strings.TrimSuffix(newString, "\n")
+	}
+
+	var result []string
+	var lines []int
+	prev := 0
+	for _, m := range matches {
+		result = append(result, fileLines[prev:m.start]...)
+		replacement := newString
+		if strategy == MatchIndentRelative {
+			replacement = reindent(newString, m)
+		}
+		if replacement != "" || !oldTrailing {
+			result = append(result, strings.Split(replacement, "\n")...)
+		}
+		lines = append(lines, m.start+1)
+		prev = m.end
+	}
+	result = append(result, fileLines[prev:]...)
+	return strings.Join(result, "\n"), lines
+}
+
+// reindent 按匹配时建立的缩进映射重新缩进new_string；old_string中没有出现过的缩进按层级换算
+func reindent(s string, m lineMatch) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := leadingIndent(line)
+		mapped, ok := m.indentMap[indent]
+		if !ok {
+			level := (indentWidth(indent) - indentWidth(m.oldBase) + m.oldUnit/2) / m.oldUnit
+			mapped = m.fileBase
+			if level > 0 {
+				mapped += strings.Repeat(m.fileUnit, level)
+			} else if level < 0 {
+				mapped = ""
+			}
+		}
+		lines[i] = mapped + line[len(indent):]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// closestCandidate 找出与old_string最相似的行块，带行号返回，方便模型重试
+func closestCandidate(fileLines, oldLines []string) string {
+	n := len(oldLines)
+	if n > len(fileLines) {
+		n = len(fileLines)
+	}
+	if n == 0 {
+		return ""
+	}
+
+	normalizedOld := make([]string, len(oldLines))
+	for i, line := range oldLines {
+		normalizedOld[i] = strings.Join(strings.Fields(line), " ")
+	}
+
+	bestStart, bestScore := -1, 0.0
+	for start := 0; start+n <= len(fileLines); start++ {
+		score := 0.0
+		for i := 0; i < n; i++ {
+			score += lineSimilarity(strings.Join(strings.Fields(fileLines[start+i]), " "), normalizedOld[i])
+		}
+		score /= float64(len(oldLines))
+		if score > bestScore {
+			bestStart, bestScore = start, score
+		}
+	}
+	if bestStart < 0 || bestScore < 0.3 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("\nThe closest candidate is at lines %d-%d (%.0f%% similar). Copy the exact text from here, including indentation:\n",
+		bestStart+1, bestStart+n, bestScore*100))
+	for i := bestStart; i < bestStart+n; i++ {
+		sb.WriteString(fmt.Sprintf("%6d\t%s\n", i+1, fileLines[i]))
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// lineSimilarity 粗略的行相似度：相同为1，包含关系0.9，否则按公共前后缀长度估算
+func lineSimilarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	if a == "" || b == "" {
+		return 0
+	}
+	if strings.Contains(a, b) || strings.Contains(b, a) {
+		return 0.9
+	}
+	maxLen := len(a)
+	if len(b) > maxLen {
+		maxLen = len(b)
+	}
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	return float64(prefix+suffix) / float64(maxLen)
+}
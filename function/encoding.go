@@ -0,0 +1,203 @@
+package function
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// TextFormat 文本文件在磁盘上的格式，编辑后按原格式写回
+type TextFormat struct {
+	Encoding   string // "utf-8"、"gbk" 或 "iso-8859-1"
+	BOM        bool   // 是否带UTF-8 BOM
+	LineEnding string // "\n" 或 "\r\n"；混合换行时为多数行的换行符，用于新增的行
+
+	mixed *mixedLineEndings // CRLF与LF混用时记录每行原来的换行符
+}
+
+// mixedLineEndings 原文件每一行（LF文本）及其换行符，最后一行没有换行时为空
+type mixedLineEndings struct {
+	lines   []string
+	endings []string
+}
+
+const (
+	EncodingUTF8   = "utf-8"
+	EncodingGBK    = "gbk"
+	EncodingLatin1 = "iso-8859-1" // 无法识别的编码按单字节原样保留
+)
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// DefaultTextFormat 新建文件使用的格式
+func DefaultTextFormat() TextFormat {
+	return TextFormat{Encoding: EncodingUTF8, LineEnding: "\n"}
+}
+
+// IsDefault 是否为普通的UTF-8 + LF
+func (f TextFormat) IsDefault() bool {
+	return f.Encoding == EncodingUTF8 && !f.BOM && f.LineEnding == "\n" && f.mixed == nil
+}
+
+// Describe 返回可读的格式描述，如 "GBK, CRLF"
+func (f TextFormat) Describe() string {
+	parts := []string{strings.ToUpper(f.Encoding)}
+	if f.BOM {
+		parts = append(parts, "BOM")
+	}
+	if f.mixed != nil {
+		parts = append(parts, "mixed CRLF/LF")
+	} else if f.LineEnding == "\r\n" {
+		parts = append(parts, "CRLF")
+	} else {
+		parts = append(parts, "LF")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// DecodeText 检测编码、BOM和换行符，返回UTF-8且换行统一为LF的文本
+func DecodeText(data []byte) (string, TextFormat) {
+	format := DefaultTextFormat()
+
+	if bytes.HasPrefix(data, utf8BOM) {
+		format.BOM = true
+		data = data[len(utf8BOM):]
+	}
+
+	text := string(data)
+	if !utf8.Valid(data) {
+		// 不是合法UTF-8时尝试GBK（国内代码库中常见）。GBK解码器把非法字节替换为U+FFFD而不报错，
+		// 出现替换字符说明不是GBK，此时按ISO-8859-1逐字节解码，写回时字节保持不变
+		if decoded, err := simplifiedchinese.GBK.NewDecoder().Bytes(data); err == nil && !bytes.ContainsRune(decoded, utf8.RuneError) {
+			format.Encoding = EncodingGBK
+			text = string(decoded)
+		} else if decoded, err := charmap.ISO8859_1.NewDecoder().Bytes(data); err == nil {
+			format.Encoding = EncodingLatin1
+			text = string(decoded)
+		}
+	}
+
+	// 以多数行的换行符为准；混用时记录每行的换行符，写回时未修改的行保持原样
+	crlf := strings.Count(text, "\r\n")
+	lf := strings.Count(text, "\n") - crlf
+	if crlf > 0 && crlf >= lf {
+		format.LineEnding = "\r\n"
+	}
+	if crlf > 0 && lf > 0 {
+		format.mixed = &mixedLineEndings{}
+		for _, line := range strings.SplitAfter(text, "\n") {
+			if line == "" {
+				continue
+			}
+			ending := ""
+			switch {
+			case strings.HasSuffix(line, "\r\n"):
+				ending = "\r\n"
+			case strings.HasSuffix(line, "\n"):
+				ending = "\n"
+			}
+			format.mixed.lines = append(format.mixed.lines, strings.TrimSuffix(line, ending))
+			format.mixed.endings = append(format.mixed.endings, ending)
+		}
+	}
+	if crlf > 0 {
+		text = strings.ReplaceAll(text, "\r\n", "\n")
+	}
+	return text, format
+}
+
+// applyMixedLineEndings 与原文件逐行比较，未修改的行使用原来的换行符，新增和修改的行使用多数行的换行符
+func applyMixedLineEndings(text string, mixed *mixedLineEndings, lineEnding string) string {
+	var lines []string
+	var hasNewline []bool
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if line == "" {
+			continue
+		}
+		lines = append(lines, strings.TrimSuffix(line, "\n"))
+		hasNewline = append(hasNewline, strings.HasSuffix(line, "\n"))
+	}
+
+	var sb strings.Builder
+	i, j := 0, 0 // 原文件和新文本中的行号
+	for _, op := range diffLines(mixed.lines, lines) {
+		switch op.op {
+		case '-':
+			i++
+			continue
+		case ' ':
+			i++
+		}
+		sb.WriteString(lines[j])
+		if hasNewline[j] {
+			ending := lineEnding
+			if op.op == ' ' && mixed.endings[i-1] != "" {
+				ending = mixed.endings[i-1]
+			}
+			sb.WriteString(ending)
+		}
+		j++
+	}
+	return sb.String()
+}
+
+// EncodeText 把LF文本按指定格式编码回字节
+func EncodeText(text string, format TextFormat) ([]byte, error) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if format.mixed != nil {
+		text = applyMixedLineEndings(text, format.mixed, format.LineEnding)
+	} else if format.LineEnding == "\r\n" {
+		text = strings.ReplaceAll(text, "\n", "\r\n")
+	}
+
+	data := []byte(text)
+	var encoder encoding.Encoding
+	switch format.Encoding {
+	case EncodingGBK:
+		encoder = simplifiedchinese.GBK
+	case EncodingLatin1:
+		encoder = charmap.ISO8859_1
+	}
+	if encoder != nil {
+		encoded, err := encoder.NewEncoder().Bytes(data)
+		if err != nil {
+			return nil, fmt.Errorf("the new content contains characters that cannot be encoded in %s, which is the encoding of this file: %v", strings.ToUpper(format.Encoding), err)
+		}
+		data = encoded
+	}
+
+	if format.BOM {
+		data = append(append([]byte{}, utf8BOM...), data...)
+	}
+	return data, nil
+}
+
+// NormalizeLineEndings 把模型传入的字符串统一为LF，与DecodeText的结果对齐
+func NormalizeLineEndings(s string) string {
+	return strings.ReplaceAll(s, "\r\n", "\n")
+}
+
+// ReadTextFile 读取文件并解码为LF文本
+func ReadTextFile(file_path string) (string, TextFormat, error) {
+	data, err := os.ReadFile(file_path)
+	if err != nil {
+		return "", DefaultTextFormat(), err
+	}
+	text, format := DecodeText(data)
+	return text, format, nil
+}
+
+// WriteTextFile 按原格式编码并写入文件
+func WriteTextFile(file_path string, text string, format TextFormat, perm os.FileMode) error {
+	data, err := EncodeText(text, format)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file_path, data, perm)
+}
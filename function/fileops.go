@@ -0,0 +1,426 @@
+package function
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Move/Copy/Delete函数签名中的参数顺序
+var (
+	MoveParams   = []string{"source", "destination", "update_imports"}
+	CopyParams   = []string{"source", "destination", "overwrite"}
+	DeleteParams = []string{"path", "recursive"}
+)
+
+// FileOperation 预先校验好的移动/复制/删除操作，确认后再执行
+type FileOperation struct {
+	Operation   string // "move", "copy", "delete"
+	Source      string
+	Destination string // 删除时为空
+	IsDir       bool
+	Overwrite   bool           // 复制时覆盖已存在的目标文件
+	Files       []string       // 涉及的源文件
+	Size        int64          // 涉及文件的总大小
+	Updates     []*PatchedFile // 随移动一起修改的文件（Go import路径、package声明）
+	Notes       []string
+	Backup      string // 删除时的备份位置，执行后填充
+
+	sourceState pathState // 校验时源和目标的状态，确认后用于检查外部修改
+	destState   pathState
+}
+
+// pathState 路径的简要状态，目录只比较自身（增删直接子项会改变修改时间）
+type pathState struct {
+	exists  bool
+	mode    fs.FileMode
+	size    int64
+	modTime time.Time
+}
+
+func lstatState(path string) pathState {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return pathState{}
+	}
+	state := pathState{exists: true, mode: info.Mode(), modTime: info.ModTime()}
+	if !info.IsDir() {
+		state.size = info.Size()
+	}
+	return state
+}
+
+// PrepareMove 校验移动操作；destination为已存在的目录时移动到该目录下。
+// updateImports为true且移动的是Go代码时，同时计算需要更新的import路径。
+func PrepareMove(source, destination string, updateImports bool) (*FileOperation, error) {
+	op, err := prepareFileOperation("move", source, destination)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Lstat(op.Destination); err == nil {
+		return nil, fmt.Errorf("Error: destination already exists: %s", op.Destination)
+	}
+	if updateImports {
+		updates, notes, err := prepareGoMoveUpdates(op.Source, op.Destination, op.IsDir)
+		if err != nil {
+			return nil, fmt.Errorf("Error updating Go imports: %v", err)
+		}
+		op.Updates = updates
+		op.Notes = append(op.Notes, notes...)
+	}
+	return op, nil
+}
+
+// PrepareCopy 校验复制操作；destination为已存在的目录时复制到该目录下
+func PrepareCopy(source, destination string, overwrite bool) (*FileOperation, error) {
+	op, err := prepareFileOperation("copy", source, destination)
+	if err != nil {
+		return nil, err
+	}
+	op.Overwrite = overwrite
+	if info, err := os.Lstat(op.Destination); err == nil {
+		if !overwrite {
+			return nil, fmt.Errorf("Error: destination already exists: %s. Use overwrite=true to replace it", op.Destination)
+		}
+		if info.IsDir() != op.IsDir {
+			return nil, fmt.Errorf("Error: cannot overwrite %s, it is not the same kind (file or directory) as the source", op.Destination)
+		}
+		op.Notes = append(op.Notes, "existing files at the destination will be overwritten")
+	}
+	return op, nil
+}
+
+// PrepareDelete 校验删除操作，目录需要recursive=true
+func PrepareDelete(path string, recursive bool) (*FileOperation, error) {
+	op, err := prepareFileOperation("delete", path, "")
+	if err != nil {
+		return nil, err
+	}
+	if op.IsDir && !recursive {
+		return nil, fmt.Errorf("Error: %s is a directory. Use recursive=true to delete it and its %d file(s)", op.Source, len(op.Files))
+	}
+	if root := ProjectRoot(); root != "" && isSubPath(root, op.Source) {
+		return nil, fmt.Errorf("Error: refusing to delete %s because it contains the project directory", op.Source)
+	}
+	return op, nil
+}
+
+// prepareFileOperation 通用校验：绝对路径、敏感路径、源存在、目标不在源内部
+func prepareFileOperation(operation, source, destination string) (*FileOperation, error) {
+	if !filepath.IsAbs(source) {
+		return nil, fmt.Errorf("Error: source path must be absolute: %s", source)
+	}
+	source = filepath.Clean(source)
+	if isSensitiveTree(source) || filepath.Dir(source) == source {
+		return nil, fmt.Errorf("Error: %s of sensitive system paths is not allowed: %s", operation, source)
+	}
+	info, err := os.Lstat(source)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("Error: path does not exist: %s", source)
+		}
+		return nil, fmt.Errorf("Error accessing %s: %v", source, err)
+	}
+
+	op := &FileOperation{Operation: operation, Source: source, IsDir: info.IsDir()}
+	if operation != "delete" {
+		if !filepath.IsAbs(destination) {
+			return nil, fmt.Errorf("Error: destination path must be absolute: %s", destination)
+		}
+		destination = filepath.Clean(destination)
+		// 与mv/cp一致：目标是已存在的目录时放到该目录下
+		if destInfo, err := os.Stat(destination); err == nil && destInfo.IsDir() {
+			destination = filepath.Join(destination, filepath.Base(source))
+		}
+		if isSensitiveTree(destination) {
+			return nil, fmt.Errorf("Error: %s to sensitive system paths is not allowed: %s", operation, destination)
+		}
+		if destination == source {
+			return nil, fmt.Errorf("Error: source and destination are the same: %s", source)
+		}
+		if op.IsDir && isSubPath(destination, source) {
+			return nil, fmt.Errorf("Error: cannot %s a directory into itself: %s -> %s", operation, source, destination)
+		}
+		op.Destination = destination
+		op.destState = lstatState(destination)
+	}
+	op.sourceState = lstatState(source)
+
+	err = filepath.WalkDir(source, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		op.Files = append(op.Files, path)
+		if info, err := d.Info(); err == nil {
+			op.Size += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error scanning %s: %v", source, err)
+	}
+	return op, nil
+}
+
+// isSensitiveTree 路径本身是敏感系统目录、位于其中，或包含敏感系统目录（如 /usr 包含 /usr/bin）。
+// 路径经过filepath.Clean后没有结尾的分隔符，补上后再按isSensitivePath的前缀比较
+func isSensitiveTree(path string) bool {
+	if isSensitivePath(path) || isSensitivePath(path+string(filepath.Separator)) {
+		return true
+	}
+	for _, dir := range append(append([]string{}, unixSensitivePaths...), winSensitivePaths...) {
+		dir = strings.TrimRight(dir, `/\`)
+		if filepath.IsAbs(dir) && isSubPath(dir, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSubPath path是否等于parent或位于parent之内
+func isSubPath(path, parent string) bool {
+	rel, err := filepath.Rel(parent, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// ChangedOnDisk 返回在校验之后（如等待确认期间）被外部修改的源、目标和附带修改的文件
+func (op *FileOperation) ChangedOnDisk() []string {
+	var changed []string
+	if lstatState(op.Source) != op.sourceState {
+		changed = append(changed, op.Source)
+	}
+	if op.Destination != "" && lstatState(op.Destination) != op.destState {
+		changed = append(changed, op.Destination)
+	}
+	return append(changed, ChangedOnDisk(op.Updates)...)
+}
+
+// Summary 用于确认界面的操作摘要
+func (op *FileOperation) Summary() string {
+	kind := "file"
+	if op.IsDir {
+		kind = fmt.Sprintf("directory, %d file(s)", len(op.Files))
+	}
+	var lines []string
+	if op.Operation == "delete" {
+		lines = append(lines, fmt.Sprintf("delete %s (%s, %d bytes)", op.Source, kind, op.Size))
+	} else {
+		lines = append(lines, fmt.Sprintf("%s %s -> %s (%s, %d bytes)", op.Operation, op.Source, op.Destination, kind, op.Size))
+	}
+	for _, note := range op.Notes {
+		lines = append(lines, "  "+note)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Diff 摘要加上随操作修改的文件的diff
+func (op *FileOperation) Diff() string {
+	parts := []string{op.Summary()}
+	for _, pf := range op.Updates {
+		if diff := UnifiedDiff(pf.Path, pf.Path, pf.OldContent, pf.NewContent); diff != "" {
+			parts = append(parts, diff)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// Paths 操作涉及的所有路径，用于权限判断
+func (op *FileOperation) Paths() []string {
+	paths := []string{op.Source}
+	if op.Destination != "" {
+		paths = append(paths, op.Destination)
+	}
+	for _, pf := range op.Updates {
+		paths = append(paths, pf.Path)
+	}
+	return paths
+}
+
+// Commit 执行操作并写入变更历史，移动时的附带修改失败会整体回滚
+func (op *FileOperation) Commit() error {
+	switch op.Operation {
+	case "move":
+		if err := os.MkdirAll(filepath.Dir(op.Destination), 0755); err != nil {
+			return fmt.Errorf("Error creating directory: %v", err)
+		}
+		if err := movePath(op.Source, op.Destination); err != nil {
+			return fmt.Errorf("Error moving %s: %v", op.Source, err)
+		}
+		if err := CommitPatch(op.Updates); err != nil {
+			movePath(op.Destination, op.Source)
+			return fmt.Errorf("Error updating files after move: %v (the move was rolled back)", err)
+		}
+		for _, file := range op.Files {
+			moved := filepath.Join(op.Destination, strings.TrimPrefix(file, op.Source))
+			if _, tracked := globalFileStates.Get(file); tracked {
+				RecordFileWrite(moved)
+			}
+			ForgetFileState(file)
+		}
+
+	case "copy":
+		if err := os.MkdirAll(filepath.Dir(op.Destination), 0755); err != nil {
+			return fmt.Errorf("Error creating directory: %v", err)
+		}
+		if err := copyPath(op.Source, op.Destination); err != nil {
+			return fmt.Errorf("Error copying %s: %v", op.Source, err)
+		}
+
+	case "delete":
+		// 先移入回收目录作为备份，而不是直接删除
+		trash, err := filepath.Abs(filepath.Join(ChangeTrashDir, time.Now().Format("20060102_150405.000000000")))
+		if err != nil {
+			return fmt.Errorf("Error preparing backup: %v", err)
+		}
+		if err := os.MkdirAll(trash, 0755); err != nil {
+			return fmt.Errorf("Error preparing backup: %v", err)
+		}
+		op.Backup = filepath.Join(trash, filepath.Base(op.Source))
+		if err := movePath(op.Source, op.Backup); err != nil {
+			return fmt.Errorf("Error deleting %s: %v", op.Source, err)
+		}
+		for _, file := range op.Files {
+			ForgetFileState(file)
+		}
+
+	default:
+		return fmt.Errorf("Error: unknown operation %q", op.Operation)
+	}
+
+	RecordChange(ChangeRecord{
+		Operation:   op.Operation,
+		Source:      op.Source,
+		Destination: op.Destination,
+		Backup:      op.Backup,
+		Updated:     PatchedPaths(op.Updates),
+	})
+	return nil
+}
+
+// Result 返回给模型的结果
+func (op *FileOperation) Result() string {
+	var sb strings.Builder
+	switch op.Operation {
+	case "move":
+		sb.WriteString(fmt.Sprintf("Successfully moved %s to %s", op.Source, op.Destination))
+	case "copy":
+		sb.WriteString(fmt.Sprintf("Successfully copied %s to %s", op.Source, op.Destination))
+	case "delete":
+		sb.WriteString(fmt.Sprintf("Successfully deleted %s (backup kept at %s)", op.Source, op.Backup))
+	}
+	if op.IsDir {
+		sb.WriteString(fmt.Sprintf(" (%d file(s), %d bytes)", len(op.Files), op.Size))
+	}
+	for _, note := range op.Notes {
+		sb.WriteString("\n- " + note)
+	}
+	for _, pf := range op.Updates {
+		sb.WriteString("\n- updated " + pf.Path)
+	}
+	return sb.String()
+}
+
+// movePath 重命名，跨设备时退化为复制后删除
+func movePath(source, destination string) error {
+	err := os.Rename(source, destination)
+	if err == nil {
+		return nil
+	}
+	var linkErr *os.LinkError
+	if !errors.As(err, &linkErr) {
+		return err
+	}
+	if err := copyPath(source, destination); err != nil {
+		os.RemoveAll(destination)
+		return err
+	}
+	return os.RemoveAll(source)
+}
+
+// copyPath 复制文件或目录，保留权限位，符号链接按链接复制
+func copyPath(source, destination string) error {
+	return filepath.WalkDir(source, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(destination, strings.TrimPrefix(path, source))
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			os.Remove(target)
+			return os.Symlink(link, target)
+		default:
+			return copyFile(path, target, info.Mode().Perm())
+		}
+	})
+}
+
+func copyFile(source, destination string, perm os.FileMode) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(destination, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// Move 移动文件或目录（Task子代理使用，无UI确认）
+func Move(source string, destination string, update_imports bool) string {
+	op, err := PrepareMove(source, destination, update_imports)
+	if err != nil {
+		return err.Error()
+	}
+	if err := op.Commit(); err != nil {
+		return err.Error()
+	}
+	return op.Result() + RunPostEditHooks(PatchedPaths(op.Updates)...)
+}
+
+// Copy 复制文件或目录（Task子代理使用，无UI确认）
+func Copy(source string, destination string, overwrite bool) string {
+	op, err := PrepareCopy(source, destination, overwrite)
+	if err != nil {
+		return err.Error()
+	}
+	if err := op.Commit(); err != nil {
+		return err.Error()
+	}
+	return op.Result()
+}
+
+// Delete 删除文件或目录（Task子代理使用，无UI确认），删除前备份到回收目录
+func Delete(path string, recursive bool) string {
+	op, err := PrepareDelete(path, recursive)
+	if err != nil {
+		return err.Error()
+	}
+	if err := op.Commit(); err != nil {
+		return err.Error()
+	}
+	return op.Result()
+}
@@ -0,0 +1,91 @@
+package function
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// 移动Go代码并同步更新import路径或package声明：期间没有外部修改时，确认后的检查不能误报
+func TestMoveWithImportUpdatesUnchangedOnDisk(t *testing.T) {
+	tests := []struct {
+		name        string
+		source      string
+		destination string
+		check       string // 移动后应包含want的文件
+		want        string
+	}{
+		{"package directory", "util", "pkg/util", "pkg/util/util.go", `"example.com/m/pkg/util/internal"`},
+		{"single file package clause", "util/extra.go", "app/extra.go", "app/extra.go", "package app"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			t.Chdir(root)
+			writeTestFiles(t, root, map[string]string{
+				"go.mod":                    "module example.com/m\n\ngo 1.24\n",
+				"main.go":                   "package main\n\nimport \"example.com/m/util\"\n\nfunc main() { util.Hello() }\n",
+				"util/util.go":              "package util\n\nimport \"example.com/m/util/internal\"\n\nfunc Hello() { internal.Run() }\n",
+				"util/internal/internal.go": "package internal\n\nfunc Run() {}\n",
+				"util/extra.go":             "package util\n\nfunc Extra() {}\n",
+				"app/app.go":                "package app\n",
+			})
+
+			op, err := PrepareMove(filepath.Join(root, tt.source), filepath.Join(root, tt.destination), true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(op.Updates) == 0 {
+				t.Fatal("expected files to update with the move")
+			}
+			if changed := op.ChangedOnDisk(); len(changed) > 0 {
+				t.Fatalf("ChangedOnDisk reported %v although nothing was touched", changed)
+			}
+			if err := op.Commit(); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(filepath.Join(root, tt.check))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(data), tt.want) {
+				t.Errorf("%s after move:\n%s\nwant it to contain %s", tt.check, data, tt.want)
+			}
+		})
+	}
+}
+
+// 确认期间被外部修改的源文件应被检出
+func TestMoveWithImportUpdatesChangedOnDisk(t *testing.T) {
+	root := t.TempDir()
+	t.Chdir(root)
+	writeTestFiles(t, root, map[string]string{
+		"go.mod":        "module example.com/m\n\ngo 1.24\n",
+		"util/extra.go": "package util\n\nfunc Extra() {}\n",
+		"app/app.go":    "package app\n",
+	})
+	source := filepath.Join(root, "util", "extra.go")
+	op, err := PrepareMove(source, filepath.Join(root, "app", "extra.go"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFiles(t, root, map[string]string{"util/extra.go": "package util\n\nfunc Extra() { println() }\n"})
+	changed := op.ChangedOnDisk()
+	if len(changed) == 0 || changed[len(changed)-1] != source {
+		t.Fatalf("ChangedOnDisk = %v, want it to report %s", changed, source)
+	}
+}
@@ -0,0 +1,162 @@
+package function
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileState 记录一次Read时文件在磁盘上的状态
+type FileState struct {
+	Hash    string    // 文件内容的sha256
+	ModTime time.Time // 读取时的修改时间
+	Size    int64     // 读取时的文件大小
+	ReadAt  time.Time // 读取发生的时间
+}
+
+// FileStateTracker 线程安全的文件状态跟踪器，主代理与Task子代理共用
+type FileStateTracker struct {
+	mu     sync.RWMutex
+	states map[string]FileState
+}
+
+// NewFileStateTracker 创建文件状态跟踪器
+func NewFileStateTracker() *FileStateTracker {
+	return &FileStateTracker{
+		states: make(map[string]FileState),
+	}
+}
+
+var globalFileStates = NewFileStateTracker()
+
+// HashContent 计算内容的sha256
+func HashContent(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeStatePath 统一路径形式，避免同一文件被记录成多个key
+func normalizeStatePath(file_path string) string {
+	if abs, err := filepath.Abs(file_path); err == nil {
+		return filepath.Clean(abs)
+	}
+	return filepath.Clean(file_path)
+}
+
+// Record 根据磁盘上的当前内容记录文件状态
+func (t *FileStateTracker) Record(file_path string) error {
+	file_path = normalizeStatePath(file_path)
+	info, err := os.Stat(file_path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(file_path)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.states[file_path] = FileState{
+		Hash:    HashContent(data),
+		ModTime: info.ModTime(),
+		Size:    info.Size(),
+		ReadAt:  time.Now(),
+	}
+	return nil
+}
+
+// Get 获取已记录的文件状态
+func (t *FileStateTracker) Get(file_path string) (FileState, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	state, ok := t.states[normalizeStatePath(file_path)]
+	return state, ok
+}
+
+// Forget 删除文件的记录（文件被删除或移动后调用）
+func (t *FileStateTracker) Forget(file_path string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.states, normalizeStatePath(file_path))
+}
+
+// Check 校验文件在上次Read之后没有被修改。
+// 文件不存在时不做限制（新建文件无需先读）。
+func (t *FileStateTracker) Check(file_path string) error {
+	file_path = normalizeStatePath(file_path)
+	info, err := os.Stat(file_path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("Error checking file: %v", err)
+	}
+	if info.IsDir() {
+		return nil
+	}
+
+	state, ok := t.Get(file_path)
+	if !ok {
+		return fmt.Errorf("Error: File has not been read yet: %s. Use the Read tool to read the file before editing or overwriting it.", file_path)
+	}
+
+	// 修改时间和大小都没变，视为未修改
+	if info.ModTime().Equal(state.ModTime) && info.Size() == state.Size {
+		return nil
+	}
+
+	// mtime变化但内容可能没变（例如touch），以hash为准
+	data, err := os.ReadFile(file_path)
+	if err != nil {
+		return fmt.Errorf("Error reading file: %v", err)
+	}
+	if HashContent(data) == state.Hash {
+		t.mu.Lock()
+		state.ModTime = info.ModTime()
+		state.Size = info.Size()
+		t.states[file_path] = state
+		t.mu.Unlock()
+		return nil
+	}
+
+	return fmt.Errorf("Error: File has been modified since it was last read (at %s), either by the user or by another tool: %s. Use the Read tool to read the file again before editing it.",
+		state.ReadAt.Format("15:04:05"), file_path)
+}
+
+// RecordFileRead 供Read函数调用，记录文件读取时的内容hash与修改时间
+func RecordFileRead(file_path string) {
+	globalFileStates.Record(file_path)
+}
+
+// RecordFileWrite 在工具自身成功写入文件后调用，使后续编辑无需重新读取
+func RecordFileWrite(file_path string) {
+	globalFileStates.Record(file_path)
+}
+
+// ForgetFileState 文件被删除或移走后清除其记录
+func ForgetFileState(file_path string) {
+	globalFileStates.Forget(file_path)
+}
+
+// CheckFileFresh 校验现有文件已被读取且之后未被修改，失败时返回可直接反馈给模型的错误
+func CheckFileFresh(file_path string) error {
+	return globalFileStates.Check(file_path)
+}
+
+// ReverifyFile 在等待用户确认之后调用：重新读取磁盘内容并与确认前读到的original比较hash。
+// 文件不存在时返回nil；changed表示期间被外部修改（新建、删除或内容变化）。
+func ReverifyFile(file_path string, original []byte) (current []byte, changed bool, err error) {
+	current, err = os.ReadFile(file_path)
+	if os.IsNotExist(err) {
+		return nil, original != nil, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return current, original == nil || HashContent(current) != HashContent(original), nil
+}
@@ -2,21 +2,31 @@ package function
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/fs"
 	"log"
+	"lukatincode/walker"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
 )
 
-func Glob(pattern string, path string) string {
+// GlobParams Glob函数签名中的参数顺序
+var GlobParams = []string{"pattern", "path", "include_hidden"}
+
+// GlobMaxResults Glob最多返回的文件数，超出时附带截断提示
+const GlobMaxResults = 100
+
+// Glob 按glob模式查找文件，按修改时间降序返回相对path的路径；遍历遵循忽略规则，include_hidden 包含隐藏文件。
+// pattern 可包含多个以空白分隔的模式，! 开头的模式用于排除（如 "**/*.go !**/*_test.go"）。
+func Glob(pattern string, path string, include_hidden bool) string {
 	// 记录日志
 	logFile, err := os.OpenFile("./log/glob.txt", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
 	if err == nil {
 		defer logFile.Close()
 		logger := log.New(logFile, "", log.LstdFlags)
-		logger.Printf("Glob函数调用 - pattern: %s, path: %s", pattern, path)
+		logger.Printf("Glob函数调用 - pattern: %s, path: %s, include_hidden: %v", pattern, path, include_hidden)
 	}
 
 	if strings.TrimSpace(pattern) == "" {
@@ -27,48 +37,25 @@ func Glob(pattern string, path string) string {
 		return "[]"
 	}
 
+	globs, err := walker.CompileGlobs(strings.Fields(pattern))
+	if err != nil {
+		if logFile != nil {
+			logger := log.New(logFile, "", log.LstdFlags)
+			logger.Printf("Glob函数返回 - 模式错误: %v", err)
+		}
+		return fmt.Sprintf("Error: %v", err)
+	}
+
 	start := strings.TrimSpace(path)
 	if start == "" {
 		start = "."
 	}
 
-	var matches []string
-	err = filepath.WalkDir(start, func(p string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return nil
-		}
-
-		if d.IsDir() {
-			if d.Name() == ".git" || d.Name() == "node_modules" || strings.HasPrefix(d.Name(), ".") {
-				return filepath.SkipDir
-			}
-			return nil
-		}
-
-		relPath, err := filepath.Rel(start, p)
-		if err != nil {
-			relPath = p
-		}
-		relPath = filepath.ToSlash(relPath)
-
-		matched, err := filepath.Match(pattern, filepath.Base(p))
-		if err != nil {
-			return nil
-		}
-
-		if matched {
-			matches = append(matches, relPath)
-		}
-
-		if strings.Contains(pattern, "**/") || strings.Contains(pattern, "/") {
-			fullMatched, err := doubleStarMatch(pattern, relPath)
-			if err == nil && fullMatched && !matched {
-				matches = append(matches, relPath)
-			}
-		}
-
-		return nil
-	})
+	// 优先使用ripgrep列出文件，不可用时回退到Go遍历；匹配统一由walker的glob实现完成
+	files, ok := ripgrepFiles(start, include_hidden)
+	if !ok {
+		files, err = globWalk(start, include_hidden)
+	}
 
 	if err != nil {
 		if logFile != nil {
@@ -78,6 +65,15 @@ func Glob(pattern string, path string) string {
 		return "[]"
 	}
 
+	var matches []string
+	seen := make(map[string]bool)
+	for _, rel := range files {
+		if !seen[rel] && globs.Match(rel) {
+			seen[rel] = true
+			matches = append(matches, rel)
+		}
+	}
+
 	if len(matches) == 0 {
 		if logFile != nil {
 			logger := log.New(logFile, "", log.LstdFlags)
@@ -106,6 +102,12 @@ func Glob(pattern string, path string) string {
 		return fileInfos[i].time > fileInfos[j].time
 	})
 
+	notice := ""
+	if len(fileInfos) > GlobMaxResults {
+		notice = fmt.Sprintf("\n\n[Showing the %d most recently modified of %d matching files. Use a more specific path or pattern to narrow the results.]", GlobMaxResults, len(fileInfos))
+		fileInfos = fileInfos[:GlobMaxResults]
+	}
+
 	sortedPaths := make([]string, len(fileInfos))
 	for i, fi := range fileInfos {
 		sortedPaths[i] = fi.path
@@ -114,39 +116,24 @@ func Glob(pattern string, path string) string {
 	result, _ := json.Marshal(sortedPaths)
 	if logFile != nil {
 		logger := log.New(logFile, "", log.LstdFlags)
-		logger.Printf("Glob函数返回 - 匹配文件数: %d, 结果: %s", len(sortedPaths), string(result))
+		logger.Printf("Glob函数返回 - 匹配文件数: %d, 结果: %s%s", len(sortedPaths), string(result), notice)
 	}
-	return string(result)
+	return string(result) + notice
 }
 
-func doubleStarMatch(pattern, path string) (bool, error) {
-	if !strings.Contains(pattern, "**/") {
-		return filepath.Match(pattern, path)
-	}
-
-	parts := strings.Split(pattern, "**/")
-	if len(parts) == 2 {
-		prefix := parts[0]
-		suffix := parts[1]
-
-		if prefix != "" && !strings.HasPrefix(path, prefix) {
-			return false, nil
-		}
-
-		if suffix == "" {
-			return true, nil
+// globWalk Go实现的文件列表，返回相对start的斜杠路径
+func globWalk(start string, includeHidden bool) ([]string, error) {
+	var files []string
+	err := walker.Walk(start, walker.Options{IncludeHidden: includeHidden}, func(p string, d fs.DirEntry) error {
+		if d.IsDir() {
+			return nil
 		}
-
-		if suffix != "" {
-			pathParts := strings.Split(path, "/")
-			for i := 0; i < len(pathParts); i++ {
-				remainingPath := strings.Join(pathParts[i:], "/")
-				if matched, err := filepath.Match(suffix, remainingPath); err == nil && matched {
-					return true, nil
-				}
-			}
+		relPath, err := filepath.Rel(start, p)
+		if err != nil {
+			relPath = p
 		}
-	}
-
-	return false, nil
+		files = append(files, filepath.ToSlash(relPath))
+		return nil
+	})
+	return files, err
 }
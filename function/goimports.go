@@ -0,0 +1,271 @@
+package function
+
+import (
+	"bufio"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// goModule 包含某个路径的Go模块
+type goModule struct {
+	Root string // go.mod所在目录
+	Path string // module声明的路径
+}
+
+// findGoModule 从dir向上查找go.mod
+func findGoModule(dir string) (*goModule, bool) {
+	for {
+		if file, err := os.Open(filepath.Join(dir, "go.mod")); err == nil {
+			scanner := bufio.NewScanner(file)
+			for scanner.Scan() {
+				line := strings.TrimSpace(scanner.Text())
+				if strings.HasPrefix(line, "module ") || strings.HasPrefix(line, "module\t") {
+					path := strings.TrimSpace(strings.TrimPrefix(line, "module"))
+					if i := strings.Index(path, "//"); i >= 0 {
+						path = strings.TrimSpace(path[:i])
+					}
+					if unquoted, err := strconv.Unquote(path); err == nil {
+						path = unquoted
+					}
+					file.Close()
+					return &goModule{Root: dir, Path: path}, true
+				}
+			}
+			file.Close()
+			return nil, false
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, false
+		}
+		dir = parent
+	}
+}
+
+// importPath 目录在模块中的import路径
+func (m *goModule) importPath(dir string) (string, bool) {
+	rel, err := filepath.Rel(m.Root, dir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	if rel == "." {
+		return m.Path, true
+	}
+	return m.Path + "/" + filepath.ToSlash(rel), true
+}
+
+// prepareGoMoveUpdates 计算移动Go代码后需要同步修改的文件：
+// 移动目录时更新整个模块中引用该包（及其子包）的import路径，移动单个文件时更新package声明。
+// 返回的PatchedFile路径为移动后的位置，需在移动完成后提交。
+func prepareGoMoveUpdates(source, destination string, isDir bool) ([]*PatchedFile, []string, error) {
+	if !isDir {
+		if !strings.HasSuffix(source, ".go") {
+			return nil, nil, nil
+		}
+		return prepareGoPackageClause(source, destination)
+	}
+
+	mod, ok := findGoModule(source)
+	if !ok {
+		return nil, []string{"not inside a Go module, import paths were not updated"}, nil
+	}
+	oldImport, ok := mod.importPath(source)
+	if !ok {
+		return nil, nil, nil
+	}
+	newImport, ok := mod.importPath(destination)
+	if !ok {
+		return nil, []string{fmt.Sprintf("destination is outside module %s, import paths were not updated", mod.Path)}, nil
+	}
+	if oldImport == mod.Path {
+		return nil, []string{"moving the module root does not change import paths"}, nil
+	}
+
+	var files []*PatchedFile
+	var notes []string
+	err := filepath.WalkDir(mod.Root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if path != mod.Root && (name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".")) {
+				return filepath.SkipDir
+			}
+			// 嵌套模块有自己的import路径空间
+			if path != mod.Root {
+				if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
+					return filepath.SkipDir
+				}
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		content, format := DecodeText(data)
+		newContent, changed, err := rewriteGoImports(path, content, oldImport, newImport)
+		if err != nil {
+			notes = append(notes, fmt.Sprintf("skipped %s: %v", path, err))
+			return nil
+		}
+		if !changed {
+			return nil
+		}
+
+		target := path
+		if rel, err := filepath.Rel(source, path); err == nil && !strings.HasPrefix(rel, "..") {
+			target = filepath.Join(destination, rel)
+		}
+		info, _ := d.Info()
+		mode := os.FileMode(0644)
+		if info != nil {
+			mode = info.Mode()
+		}
+		files = append(files, &PatchedFile{
+			Path:       target,
+			OldPath:    target,
+			Operation:  "modify",
+			OldContent: content,
+			NewContent: newContent,
+			Mode:       mode,
+			Format:     format,
+			oldData:    data,
+			verifyPath: path,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	if len(files) > 0 {
+		notes = append(notes, fmt.Sprintf("import path %s -> %s updated in %d file(s)", oldImport, newImport, len(files)))
+	}
+	return files, notes, nil
+}
+
+// rewriteGoImports 把import路径oldImport（及其子包）替换为newImport，只改动import字面量，保留原有格式
+func rewriteGoImports(path, content, oldImport, newImport string) (string, bool, error) {
+	if !strings.Contains(content, oldImport) {
+		return content, false, nil
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, content, parser.ImportsOnly)
+	if err != nil {
+		return content, false, err
+	}
+
+	type replacement struct {
+		start, end int
+		text       string
+	}
+	var replacements []replacement
+	for _, spec := range file.Imports {
+		value, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		if value != oldImport && !strings.HasPrefix(value, oldImport+"/") {
+			continue
+		}
+		replacements = append(replacements, replacement{
+			start: fset.Position(spec.Path.Pos()).Offset,
+			end:   fset.Position(spec.Path.End()).Offset,
+			text:  strconv.Quote(newImport + strings.TrimPrefix(value, oldImport)),
+		})
+	}
+	if len(replacements) == 0 {
+		return content, false, nil
+	}
+
+	for i := len(replacements) - 1; i >= 0; i-- {
+		r := replacements[i]
+		content = content[:r.start] + r.text + content[r.end:]
+	}
+	return content, true, nil
+}
+
+// prepareGoPackageClause 单个Go文件移动到其他包目录时，把package声明改为目标目录的包名
+func prepareGoPackageClause(source, destination string) ([]*PatchedFile, []string, error) {
+	destDir := filepath.Dir(destination)
+	if filepath.Dir(source) == destDir {
+		return nil, nil, nil
+	}
+
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return nil, nil, err
+	}
+	content, format := DecodeText(data)
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, source, content, parser.PackageClauseOnly)
+	if err != nil {
+		return nil, []string{fmt.Sprintf("could not parse package clause: %v", err)}, nil
+	}
+	current := file.Name.Name
+
+	target := goPackageName(destDir)
+	if target == "" {
+		return nil, []string{fmt.Sprintf("destination directory has no Go files, package clause %q was kept", current)}, nil
+	}
+	if strings.HasSuffix(current, "_test") && strings.HasSuffix(destination, "_test.go") {
+		target += "_test"
+	}
+
+	notes := []string{"references to identifiers declared in the moved file were not updated"}
+	if target == current {
+		return nil, notes, nil
+	}
+	start := fset.Position(file.Name.Pos()).Offset
+	end := fset.Position(file.Name.End()).Offset
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, nil, err
+	}
+	pf := &PatchedFile{
+		Path:       destination,
+		OldPath:    destination,
+		Operation:  "modify",
+		OldContent: content,
+		NewContent: content[:start] + target + content[end:],
+		Mode:       info.Mode(),
+		Format:     format,
+		oldData:    data,
+		verifyPath: source,
+	}
+	notes = append([]string{fmt.Sprintf("package clause %s -> %s", current, target)}, notes...)
+	return []*PatchedFile{pf}, notes, nil
+}
+
+// goPackageName 目录中非测试Go文件声明的包名，没有Go文件时返回空字符串
+func goPackageName(dir string) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(token.NewFileSet(), filepath.Join(dir, name), nil, parser.PackageClauseOnly)
+		if err == nil {
+			return file.Name.Name
+		}
+	}
+	return ""
+}
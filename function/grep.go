@@ -3,7 +3,7 @@ package function
 import (
 	"encoding/json"
 	"fmt"
-	"io/fs"
+	"lukatincode/walker"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -12,28 +12,104 @@ import (
 	"time"
 )
 
+// GrepParams Grep函数签名中的参数顺序
+var GrepParams = []string{"pattern", "path", "include", "output_mode", "before_context", "after_context", "context", "case_insensitive", "multiline", "head_limit", "offset", "include_hidden"}
+
+const (
+	GrepFilesWithMatches = "files_with_matches"
+	GrepContent          = "content"
+	GrepCount            = "count"
+
+	grepMaxLineLength = 500 // content模式下单行最多显示的字符数
+)
+
+// GrepOptions 匹配与输出选项
+type GrepOptions struct {
+	OutputMode      string
+	Before, After   int // 匹配行前后的上下文行数
+	CaseInsensitive bool
+	Multiline       bool // 允许匹配跨行，. 可匹配换行
+	HeadLimit       int  // 最多输出的条目（行或文件）数，0表示不限制
+	Offset          int  // 跳过前面的条目，用于分页
+	IncludeHidden   bool // 搜索以 . 开头的文件和目录
+}
+
+// grepFileResult 单个文件的匹配结果，ripgrep与Go实现产生相同的结构
+type grepFileResult struct {
+	path    string
+	modTime int64
+	text    map[int]string // 需要输出的行（匹配行与上下文行），行号从1开始
+	matched map[int]bool   // 匹配的行号
+	count   int            // 匹配次数
+}
+
 // Grep 在给定 path（文件或目录）中按正则 pattern 搜索文件内容，
-// 可用 include 进行文件名模式过滤（支持简单的 * ? 以及一层 {a,b} 展开）。
-// 返回：按修改时间降序排序的匹配文件相对路径 JSON 数组字符串。
-func Grep(pattern string, include string, path string) string {
+// 可用 include 进行文件名模式过滤（doublestar语义：** 跨目录、嵌套 {a,b}、字符类，空白分隔多个模式，! 开头表示排除）。
+// output_mode:
+//   - files_with_matches（默认）：按修改时间降序排序的匹配文件相对路径 JSON 数组字符串
+//   - content：带行号的匹配行，可附带前后上下文（类似 rg -n -A -B）
+//   - count：每个文件的匹配次数
+//
+// 目录遍历遵循 .gitignore / .ignore / .lukatinignore，include_hidden 为 true 时包含隐藏文件。
+func Grep(pattern string, path string, include string, output_mode string, before_context int, after_context int, context int, case_insensitive bool, multiline bool, head_limit int, offset int, include_hidden bool) string {
 	writeDebug := func(msg string) {
 		if f, err := os.OpenFile("./log/grep.txt", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err == nil {
 			f.WriteString(fmt.Sprintf("[%s] %s\n", time.Now().Format("15:04:05"), msg))
 			f.Close()
 		}
 	}
-	
-	writeDebug(fmt.Sprintf("输入参数 - pattern: '%s', include: '%s', path: '%s'", pattern, include, path))
-	
+
+	writeDebug(fmt.Sprintf("输入参数 - pattern: '%s', include: '%s', path: '%s', output_mode: '%s', -B %d -A %d -C %d, -i %v, multiline %v, head_limit %d, offset %d, hidden %v",
+		pattern, include, path, output_mode, before_context, after_context, context, case_insensitive, multiline, head_limit, offset, include_hidden))
+
+	opts := GrepOptions{
+		OutputMode:      strings.TrimSpace(output_mode),
+		Before:          before_context,
+		After:           after_context,
+		CaseInsensitive: case_insensitive,
+		Multiline:       multiline,
+		HeadLimit:       head_limit,
+		Offset:          offset,
+		IncludeHidden:   include_hidden,
+	}
+	if opts.OutputMode == "" {
+		opts.OutputMode = GrepFilesWithMatches
+	}
+	switch opts.OutputMode {
+	case GrepFilesWithMatches, GrepContent, GrepCount:
+	default:
+		return fmt.Sprintf("Error: invalid output_mode %q, expected one of files_with_matches, content, count", output_mode)
+	}
+	// -C 同时设置前后上下文，单独给出的 -A/-B 优先
+	if context > 0 {
+		if opts.Before <= 0 {
+			opts.Before = context
+		}
+		if opts.After <= 0 {
+			opts.After = context
+		}
+	}
+
 	if strings.TrimSpace(pattern) == "" {
-		writeDebug("空模式，返回空数组")
-		return "[]"
+		writeDebug("空模式，返回空结果")
+		return formatGrepResults(nil, opts, false)
 	}
 
-	compiled, err := regexp.Compile(pattern)
+	flags := ""
+	if opts.CaseInsensitive {
+		flags += "i"
+	}
+	if opts.Multiline {
+		flags += "s"
+	}
+	expr := pattern
+	if flags != "" {
+		expr = "(?" + flags + ")" + pattern
+	}
+	compiled, err := regexp.Compile(expr)
 	if err != nil {
 		writeDebug(fmt.Sprintf("正则编译失败: %v", err))
-		return "[]"
+		return fmt.Sprintf("Error: invalid regular expression %q: %v", pattern, err)
 	}
 
 	start := strings.TrimSpace(path)
@@ -43,140 +119,183 @@ func Grep(pattern string, include string, path string) string {
 	writeDebug(fmt.Sprintf("搜索起始路径: '%s'", start))
 
 	// 准备 include 模式集合
-	includePatterns := expandBracePattern(strings.TrimSpace(include))
+	includePatterns := strings.Fields(include)
+	includeGlobs, err := walker.CompileGlobs(includePatterns)
+	if err != nil {
+		writeDebug(fmt.Sprintf("include模式错误: %v", err))
+		return fmt.Sprintf("Error: invalid include pattern: %v", err)
+	}
 	writeDebug(fmt.Sprintf("include模式: %v", includePatterns))
 
-	matchedFiles := make(map[string]fs.FileInfo)
-
-	// 处理单文件
-	info, statErr := os.Stat(start)
-	if statErr == nil && !info.IsDir() {
-		if shouldInclude(start, includePatterns) && fileMatches(start, compiled) {
-			matchedFiles[start] = info
+	// 优先使用ripgrep，不可用或执行失败时回退到Go实现
+	results, ok := ripgrepGrep(pattern, start, includePatterns, includeGlobs, opts)
+	stopped := false
+	if ok {
+		writeDebug(fmt.Sprintf("ripgrep搜索完成 - 匹配文件数: %d", len(results)))
+	} else {
+		results, stopped = grepFiles(start, includeGlobs, compiled, opts)
+		for _, res := range results {
+			writeDebug(fmt.Sprintf("匹配文件: %s (%d处)", res.path, res.count))
+		}
+		if stopped {
+			writeDebug("已凑够head_limit条结果，提前结束搜索")
 		}
-		return toSortedJSON(matchedFiles)
 	}
+	sortGrepResults(results)
 
-	// 处理目录递归
-	fileCount := 0
-	checkedCount := 0
-	_ = filepath.WalkDir(start, func(p string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return nil
+	result := formatGrepResults(results, opts, stopped)
+	writeDebug(fmt.Sprintf("最终结果: 匹配文件数 %d, 输出长度 %d", len(results), len(result)))
+	return result
+}
+
+// sortGrepResults 按修改时间从新到旧排序；并行搜索的结果顺序不固定，修改时间相同的按路径排序
+func sortGrepResults(results []*grepFileResult) {
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].modTime != results[j].modTime {
+			return results[i].modTime > results[j].modTime
 		}
-		name := d.Name()
-		// 跳过常见大目录与隐藏目录
-		if d.IsDir() {
-			if name == ".git" || name == "node_modules" || strings.HasPrefix(name, ".") {
-				return filepath.SkipDir
-			}
-			return nil
+		return results[i].path < results[j].path
+	})
+}
+
+// formatGrepResults 按输出模式生成结果，并应用offset/head_limit分页；
+// stopped表示搜索因head_limit提前结束，总数只是已找到的部分
+func formatGrepResults(results []*grepFileResult, opts GrepOptions, stopped bool) string {
+	switch opts.OutputMode {
+	case GrepContent:
+		var entries []string
+		for _, res := range results {
+			entries = append(entries, res.contentLines(opts.Before > 0 || opts.After > 0)...)
 		}
-		fileCount++
-		if !shouldInclude(p, includePatterns) {
-			return nil
+		if len(entries) == 0 {
+			return "No matches found"
 		}
-		checkedCount++
-		if fileMatches(p, compiled) {
-			if fi, e := os.Stat(p); e == nil {
-				matchedFiles[p] = fi
-				writeDebug(fmt.Sprintf("匹配文件: %s", p))
-			}
+		page, notice := paginateGrep(entries, opts, "lines", stopped)
+		return strings.Join(page, "\n") + notice
+
+	case GrepCount:
+		var entries []string
+		total := 0
+		for _, res := range results {
+			entries = append(entries, fmt.Sprintf("%s:%d", normalizeRel(res.path), res.count))
+			total += res.count
 		}
-		return nil
-	})
-	writeDebug(fmt.Sprintf("遍历完成 - 总文件数: %d, 检查文件数: %d, 匹配文件数: %d", fileCount, checkedCount, len(matchedFiles)))
+		if len(entries) == 0 {
+			return "No matches found"
+		}
+		page, notice := paginateGrep(entries, opts, "files", stopped)
+		summary := fmt.Sprintf("\n\nFound %d total occurrence(s) across %d file(s).", total, len(entries))
+		if stopped {
+			summary = fmt.Sprintf("\n\nFound %d occurrence(s) across the first %d matching file(s).", total, len(entries))
+		}
+		return strings.Join(page, "\n") + summary + notice
 
-	result := toSortedJSON(matchedFiles)
-	writeDebug(fmt.Sprintf("最终结果: %s (匹配文件数: %d)", result, len(matchedFiles)))
-	return result
+	default:
+		var entries []string
+		for _, res := range results {
+			entries = append(entries, normalizeRel(res.path))
+		}
+		page, notice := paginateGrep(entries, opts, "files", stopped)
+		if page == nil {
+			page = []string{}
+		}
+		js, _ := json.Marshal(page)
+		return string(js) + notice
+	}
 }
 
-// shouldInclude 根据 include 模式判断文件是否应被检查
-func shouldInclude(path string, patterns []string) bool {
-	if len(patterns) == 0 {
-		return true
+// contentLines 生成 rg 风格的输出：匹配行 "path:行号:内容"，上下文行 "path-行号-内容"，
+// 有上下文时不连续的片段之间用 "--" 分隔
+func (res *grepFileResult) contentLines(withContext bool) []string {
+	numbers := make([]int, 0, len(res.text))
+	for n := range res.text {
+		numbers = append(numbers, n)
 	}
-	unix := filepath.ToSlash(path)
-	for _, pat := range patterns {
-		if pat == "" {
-			continue
+	sort.Ints(numbers)
+
+	var out []string
+	display := normalizeRel(res.path)
+	prev := -1
+	for _, n := range numbers {
+		if withContext && prev >= 0 && n != prev+1 {
+			out = append(out, "--")
 		}
-		// 同时对全路径与基名尝试匹配
-		if ok, _ := filepath.Match(pat, unix); ok {
-			return true
+		line := res.text[n]
+		if len(line) > grepMaxLineLength {
+			line = line[:grepMaxLineLength] + "... [line truncated]"
 		}
-		if ok, _ := filepath.Match(pat, filepath.Base(unix)); ok {
-			return true
+		sep := "-"
+		if res.matched[n] {
+			sep = ":"
 		}
+		out = append(out, fmt.Sprintf("%s%s%d%s%s", display, sep, n, sep, line))
+		prev = n
 	}
-	return false
+	if withContext && len(out) > 0 {
+		// 不同文件之间同样用 "--" 分隔
+		out = append([]string{"--"}, out...)
+	}
+	return out
 }
 
-// fileMatches 判断文件内容是否匹配正则
-func fileMatches(path string, re *regexp.Regexp) bool {
-	b, err := os.ReadFile(path)
-	if err != nil {
-		return false
+// paginateGrep 应用offset与head_limit，返回当前页及截断提示
+func paginateGrep(entries []string, opts GrepOptions, unit string, stopped bool) ([]string, string) {
+	// context模式下每个文件前的分隔符只在文件之间需要
+	if len(entries) > 0 && entries[0] == "--" {
+		entries = entries[1:]
 	}
-	// 简单跳过可能的二进制文件（包含 NUL）
-	if idx := strings.IndexByte(string(b), 0); idx >= 0 {
-		return false
+	total := len(entries)
+	offset := opts.Offset
+	if offset < 0 {
+		offset = 0
 	}
-	return re.Match(b)
-}
-
-// toSortedJSON 将文件集合按修改时间降序排序并返回 JSON 数组字符串
-func toSortedJSON(m map[string]fs.FileInfo) string {
-	if len(m) == 0 {
-		return "[]"
+	if offset >= total {
+		if total > 0 && offset > 0 {
+			return nil, fmt.Sprintf("\n[offset %d is past the end of the results (%d %s)]", offset, total, unit)
+		}
+		return nil, ""
+	}
+	end := total
+	if opts.HeadLimit > 0 && offset+opts.HeadLimit < total {
+		end = offset + opts.HeadLimit
+	}
+	page := entries[offset:end]
+	if stopped {
+		// 提前结束时总数未知，结果也不是全局按修改时间排序的，offset翻页无法与这一页衔接
+		return page, fmt.Sprintf("\n\n[Showing %s %d-%d; the search stopped once head_limit was reached, so there may be more matches. Narrow path or include, or raise head_limit, to see more.]", unit, offset+1, end)
 	}
-	type item struct {
-		path string
-		mod  int64
+	if offset == 0 && end == total {
+		return page, ""
 	}
-	arr := make([]item, 0, len(m))
-	for p, fi := range m {
-		arr = append(arr, item{path: normalizeRel(p), mod: fi.ModTime().UnixNano()})
+	notice := fmt.Sprintf("\n\n[Showing %s %d-%d of %d.", unit, offset+1, end, total)
+	if end < total {
+		notice += fmt.Sprintf(" Use offset=%d to see more.", end)
 	}
-	sort.Slice(arr, func(i, j int) bool { return arr[i].mod > arr[j].mod })
-	out := make([]string, len(arr))
-	for i, it := range arr {
-		out[i] = it.path
+	return page, notice + "]"
+}
+
+// includeMatch 判断文件是否符合 include 模式（doublestar语义），路径相对搜索起点匹配
+func includeMatch(globs *walker.GlobSet, start, path string) bool {
+	if globs.Empty() {
+		return true
+	}
+	rel, err := filepath.Rel(start, path)
+	if err != nil || rel == "." {
+		rel = filepath.Base(path)
 	}
-	js, _ := json.Marshal(out)
-	return string(js)
+	return globs.Match(filepath.ToSlash(rel))
 }
 
-// normalizeRel 将路径转换为相对工作目录的形式（若可能），并统一为正斜杠
+// normalizeRel 将路径转换为相对工作目录的形式（若可能，工作目录之外的路径保持绝对路径），并统一为正斜杠
 func normalizeRel(p string) string {
 	wd, err := os.Getwd()
 	if err == nil {
-		if rel, e := filepath.Rel(wd, p); e == nil {
-			return filepath.ToSlash(rel)
+		if abs, e := filepath.Abs(p); e == nil {
+			if rel, e := filepath.Rel(wd, abs); e == nil && !strings.HasPrefix(rel, "..") {
+				return filepath.ToSlash(rel)
+			}
+			return filepath.ToSlash(abs)
 		}
 	}
 	return filepath.ToSlash(p)
 }
-
-// expandBracePattern 将如 "*.{ts,tsx}" 展开为 ["*.ts","*.tsx"]；若无大括号则返回原样数组
-func expandBracePattern(p string) []string {
-	p = strings.TrimSpace(p)
-	if p == "" {
-		return nil
-	}
-	start := strings.IndexByte(p, '{')
-	end := strings.IndexByte(p, '}')
-	if start >= 0 && end > start {
-		prefix := p[:start]
-		suffix := p[end+1:]
-		inner := p[start+1 : end]
-		parts := strings.Split(inner, ",")
-		res := make([]string, 0, len(parts))
-		for _, part := range parts {
-			res = append(res, prefix+strings.TrimSpace(part)+suffix)
-		}
-		return res
-	}
-	return []string{p}
-}
@@ -0,0 +1,241 @@
+package function
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"lukatincode/walker"
+	"os"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Grep的Go实现（ripgrep不可用时）：遍历与搜索并行进行，GOMAXPROCS个worker从通道中取文件，
+// 逐行流式读取；第一页（offset为0）凑够 head_limit 条结果后停止遍历和剩余的搜索。
+// 提前结束时哪些文件被搜索到取决于调度时机，之后的页无法与之衔接，所以offset大于0时总是完整搜索。
+
+const (
+	grepMaxFileSize   = 16 << 20 // 超过该大小的文件不搜索
+	grepSniffLen      = 8 << 10  // 检查前多少字节判断二进制文件
+	grepReadBufSize   = 64 << 10
+	grepMaxLineBuffer = grepMaxFileSize // 单行最大长度（超过时该文件剩余部分不再搜索）
+)
+
+// errGrepStop 凑够结果后终止遍历
+var errGrepStop = errors.New("grep: enough results")
+
+// grepFiles 并行搜索start下符合include的文件；stopped为true表示因head_limit提前结束，结果不完整
+func grepFiles(start string, includeGlobs *walker.GlobSet, re *regexp.Regexp, opts GrepOptions) (results []*grepFileResult, stopped bool) {
+	// 需要的条目数：files/count模式是文件数，content模式是输出行数；只有第一页提前结束
+	need := int64(0)
+	if opts.HeadLimit > 0 && opts.Offset <= 0 {
+		need = int64(opts.HeadLimit)
+	}
+	var found int64
+	var done atomic.Bool
+
+	paths := make(chan string, 256)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.GOMAXPROCS(0); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s := newGrepScanner()
+			for path := range paths {
+				if done.Load() {
+					continue
+				}
+				res := s.grepFile(path, re, opts, &done)
+				if res == nil {
+					continue
+				}
+				mu.Lock()
+				results = append(results, res)
+				mu.Unlock()
+				if need > 0 && atomic.AddInt64(&found, res.entries(opts)) >= need {
+					done.Store(true)
+				}
+			}
+		}()
+	}
+
+	_ = walker.Walk(start, walker.Options{IncludeHidden: opts.IncludeHidden}, func(p string, d fs.DirEntry) error {
+		if done.Load() {
+			return errGrepStop
+		}
+		if !d.IsDir() && includeMatch(includeGlobs, start, p) {
+			paths <- p
+		}
+		return nil
+	})
+	close(paths)
+	wg.Wait()
+	return results, done.Load()
+}
+
+// entries 该文件在输出中占用的条目数，用于判断是否已凑够head_limit
+func (res *grepFileResult) entries(opts GrepOptions) int64 {
+	if opts.OutputMode == GrepContent {
+		return int64(len(res.text))
+	}
+	return 1
+}
+
+// grepScanner 每个worker复用的读缓冲
+type grepScanner struct {
+	reader *bufio.Reader
+	buf    []byte
+}
+
+func newGrepScanner() *grepScanner {
+	return &grepScanner{
+		reader: bufio.NewReaderSize(nil, grepReadBufSize),
+		buf:    make([]byte, grepReadBufSize),
+	}
+}
+
+// grepFile 在单个文件中查找匹配，无匹配、是二进制文件或超过大小限制时返回nil。
+// done被置位时放弃当前文件（结果已经足够）。
+func (s *grepScanner) grepFile(path string, re *regexp.Regexp, opts GrepOptions, done *atomic.Bool) *grepFileResult {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() || info.Size() > grepMaxFileSize {
+		return nil
+	}
+
+	s.reader.Reset(f)
+	// 只检查第一块是否包含NUL判断二进制文件
+	head, _ := s.reader.Peek(grepSniffLen)
+	if bytes.IndexByte(head, 0) >= 0 {
+		return nil
+	}
+
+	res := &grepFileResult{
+		path:    path,
+		modTime: info.ModTime().UnixNano(),
+		text:    make(map[int]string),
+		matched: make(map[int]bool),
+	}
+	if opts.Multiline {
+		b, err := io.ReadAll(s.reader)
+		if err != nil {
+			return nil
+		}
+		grepMultiline(res, string(b), re, opts)
+	} else if !s.grepLines(res, re, opts, done) {
+		return nil
+	}
+
+	if res.count == 0 {
+		return nil
+	}
+	return res
+}
+
+// grepLines 逐行匹配，content模式下用环形缓冲保留前文上下文；返回false表示中途放弃
+func (s *grepScanner) grepLines(res *grepFileResult, re *regexp.Regexp, opts GrepOptions, done *atomic.Bool) bool {
+	scanner := bufio.NewScanner(s.reader)
+	scanner.Buffer(s.buf, grepMaxLineBuffer)
+
+	content := opts.OutputMode == GrepContent
+	// files_with_matches模式只需知道是否匹配，找到第一处即可结束
+	firstOnly := opts.OutputMode == GrepFilesWithMatches
+
+	before := make([]string, opts.Before) // 环形缓冲：最近的Before行
+	afterLeft := 0
+	n := 0
+	for scanner.Scan() {
+		n++
+		if n%4096 == 0 && done.Load() {
+			return false
+		}
+		line := scanner.Bytes()
+		hits := 0
+		if opts.OutputMode == GrepCount {
+			hits = len(re.FindAllIndex(line, -1))
+		} else if re.Match(line) {
+			hits = 1
+			if content {
+				hits = len(re.FindAllIndex(line, -1))
+			}
+		}
+
+		if hits > 0 {
+			res.matched[n] = true
+			res.count += hits
+			if firstOnly {
+				return true
+			}
+			if content {
+				for i := opts.Before; i >= 1; i-- {
+					if l := n - i; l >= 1 {
+						if _, ok := res.text[l]; !ok {
+							res.text[l] = before[l%len(before)]
+						}
+					}
+				}
+				res.text[n] = string(line)
+				afterLeft = opts.After
+			}
+		} else if content && afterLeft > 0 {
+			res.text[n] = string(line)
+			afterLeft--
+		}
+		if content && len(before) > 0 {
+			before[n%len(before)] = string(line)
+		}
+	}
+	// 超长行等读取错误：保留已找到的匹配
+	return true
+}
+
+// grepMultiline 整个文件作为输入，匹配覆盖的每一行都算匹配行
+func grepMultiline(res *grepFileResult, content string, re *regexp.Regexp, opts GrepOptions) {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	lines := strings.Split(content, "\n")
+	// 文件末尾换行产生的空行不算一行
+	if len(lines) > 1 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	lineStarts := []int{0}
+	for i := 0; i < len(content); i++ {
+		if content[i] == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	lineOf := func(offset int) int {
+		return sort.Search(len(lineStarts), func(i int) bool { return lineStarts[i] > offset }) - 1
+	}
+	for _, loc := range re.FindAllStringIndex(content, -1) {
+		end := loc[1]
+		if end > loc[0] {
+			end--
+		}
+		for l := lineOf(loc[0]); l <= lineOf(end) && l < len(lines); l++ {
+			res.matched[l+1] = true
+		}
+		res.count++
+	}
+
+	if opts.OutputMode == GrepContent {
+		for l := range res.matched {
+			for i := l - opts.Before; i <= l+opts.After; i++ {
+				if i >= 1 && i <= len(lines) {
+					res.text[i] = lines[i-1]
+				}
+			}
+		}
+	}
+}
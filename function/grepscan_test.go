@@ -0,0 +1,137 @@
+package function
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"lukatincode/walker"
+)
+
+// makeGrepTree 生成dirs*filesPerDir个文件的目录树，每个文件lines行，每隔matchEvery行有一行包含TODO；
+// 修改时间各不相同，使排序结果确定
+func makeGrepTree(tb testing.TB, dirs, filesPerDir, lines, matchEvery int) string {
+	tb.Helper()
+	root := tb.TempDir()
+	base := time.Now().Add(-time.Hour)
+	var sb strings.Builder
+	for i := 1; i <= lines; i++ {
+		if i%matchEvery == 0 {
+			fmt.Fprintf(&sb, "\t// TODO: handle case %d\n", i)
+		} else {
+			fmt.Fprintf(&sb, "\tvalue%d := compute(%d, \"some ordinary line of code\")\n", i, i)
+		}
+	}
+	content := []byte(sb.String())
+
+	n := 0
+	for d := 0; d < dirs; d++ {
+		dir := filepath.Join(root, fmt.Sprintf("pkg%03d", d), "internal")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			tb.Fatal(err)
+		}
+		for f := 0; f < filesPerDir; f++ {
+			path := filepath.Join(dir, fmt.Sprintf("file%03d.go", f))
+			if err := os.WriteFile(path, content, 0644); err != nil {
+				tb.Fatal(err)
+			}
+			mtime := base.Add(time.Duration(n) * time.Second)
+			if err := os.Chtimes(path, mtime, mtime); err != nil {
+				tb.Fatal(err)
+			}
+			n++
+		}
+	}
+	return root
+}
+
+func grepPaths(tb testing.TB, root string, opts GrepOptions) ([]string, bool) {
+	tb.Helper()
+	globs, err := walker.CompileGlobs(nil)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	results, stopped := grepFiles(root, globs, regexp.MustCompile(`TODO`), opts)
+	sortGrepResults(results)
+	paths := make([]string, len(results))
+	for i, res := range results {
+		paths[i] = res.path
+	}
+	return paths, stopped
+}
+
+// offset大于0的页总是完整搜索，与不分页的结果一致，页与页之间不重叠也不遗漏
+func TestGrepFilesOffsetPagesAreConsistent(t *testing.T) {
+	root := makeGrepTree(t, 20, 10, 50, 10)
+	all, stopped := grepPaths(t, root, GrepOptions{OutputMode: GrepFilesWithMatches})
+	if stopped || len(all) != 200 {
+		t.Fatalf("full search: %d files, stopped=%v; want 200 files, not stopped", len(all), stopped)
+	}
+
+	const limit = 30
+	for offset := limit; offset < len(all); offset += limit {
+		opts := GrepOptions{OutputMode: GrepFilesWithMatches, HeadLimit: limit, Offset: offset}
+		paths, stopped := grepPaths(t, root, opts)
+		if stopped {
+			t.Fatalf("offset %d: search stopped early", offset)
+		}
+		page, _ := paginateGrep(paths, opts, "files", false)
+		end := offset + limit
+		if end > len(all) {
+			end = len(all)
+		}
+		if got, want := strings.Join(page, "\n"), strings.Join(all[offset:end], "\n"); got != want {
+			t.Fatalf("offset %d: page does not match the full result", offset)
+		}
+	}
+}
+
+// 第一页凑够head_limit后提前结束，此时不提示用offset翻页
+func TestGrepFilesFirstPageStopsEarly(t *testing.T) {
+	root := makeGrepTree(t, 20, 10, 50, 10)
+	opts := GrepOptions{OutputMode: GrepFilesWithMatches, HeadLimit: 5}
+	paths, stopped := grepPaths(t, root, opts)
+	if !stopped {
+		t.Fatal("search with head_limit on the first page did not stop early")
+	}
+	// 每个文件都匹配，返回的文件数就是搜索过的文件数
+	if len(paths) < 5 || len(paths) >= 200 {
+		t.Fatalf("searched %d of 200 files, want at least 5 and fewer than all", len(paths))
+	}
+	_, notice := paginateGrep(paths, opts, "files", stopped)
+	if !strings.Contains(notice, "stopped") || strings.Contains(notice, "offset=") {
+		t.Errorf("notice for an early-stopped search = %q, want a stop notice without offset paging", notice)
+	}
+}
+
+// benchmarkGrepFiles 在5000个文件、共100万行的目录树中搜索
+func benchmarkGrepFiles(b *testing.B, opts GrepOptions) {
+	root := makeGrepTree(b, 200, 25, 200, 50)
+	globs, err := walker.CompileGlobs(nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	re := regexp.MustCompile(`TODO: handle case \d+`)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if results, _ := grepFiles(root, globs, re, opts); len(results) == 0 {
+			b.Fatal("no matches")
+		}
+	}
+}
+
+func BenchmarkGrepFilesFull(b *testing.B) {
+	benchmarkGrepFiles(b, GrepOptions{OutputMode: GrepFilesWithMatches})
+}
+
+func BenchmarkGrepFilesContent(b *testing.B) {
+	benchmarkGrepFiles(b, GrepOptions{OutputMode: GrepContent, Before: 2, After: 2})
+}
+
+func BenchmarkGrepFilesFirstPage(b *testing.B) {
+	benchmarkGrepFiles(b, GrepOptions{OutputMode: GrepFilesWithMatches, HeadLimit: 20})
+}
@@ -0,0 +1,196 @@
+package function
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	defaultHookTimeout = 30   // 秒
+	maxHookOutput      = 4000 // 单条命令输出的最大字符数
+)
+
+// hookCommand 展开占位符之后待执行的命令
+type hookCommand struct {
+	language string
+	command  string
+	timeout  time.Duration
+}
+
+// RunPostEditHooks 文件修改成功后执行项目设置中匹配的格式化/检查命令，
+// 返回需要附加到工具结果后面的诊断信息，没有匹配的hook时返回空字符串。
+func RunPostEditHooks(paths ...string) string {
+	settings, err := LoadProjectSettings()
+	if err != nil {
+		return fmt.Sprintf("\n\nPost-edit hooks were skipped: %v", err)
+	}
+	if len(settings.PostEditHooks) == 0 {
+		return ""
+	}
+
+	languages := make([]string, 0, len(settings.PostEditHooks))
+	for language := range settings.PostEditHooks {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+
+	root := ProjectRoot()
+	var commands []hookCommand
+	seen := make(map[string]bool)
+	hashes := make(map[string]string)
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue // 文件已被删除
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		for _, language := range languages {
+			hook := settings.PostEditHooks[language]
+			if !hookMatchesExtension(hook, ext) {
+				continue
+			}
+			hashes[path] = HashContent(data)
+			timeout := hook.Timeout
+			if timeout <= 0 {
+				timeout = defaultHookTimeout
+			}
+			for _, template := range hook.Commands {
+				command := expandHookCommand(template, path, root)
+				if seen[command] {
+					continue // 同一个包的多个文件只vet一次
+				}
+				seen[command] = true
+				commands = append(commands, hookCommand{language: language, command: command, timeout: time.Duration(timeout) * time.Second})
+			}
+		}
+	}
+	if len(commands) == 0 {
+		return ""
+	}
+
+	logFile, err := os.OpenFile("./log/hooks.txt", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	var logger *log.Logger
+	if err == nil {
+		defer logFile.Close()
+		logger = log.New(logFile, "", log.LstdFlags)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\n\nPost-edit hooks:")
+	for _, hc := range commands {
+		output, exitCode, err := runHookCommand(hc, root)
+		if logger != nil {
+			logger.Printf("执行hook [%s] %s - exit_code: %d, 输出长度: %d", hc.language, hc.command, exitCode, len(output))
+		}
+		sb.WriteString(fmt.Sprintf("\n$ %s", hc.command))
+		if output = strings.TrimSpace(output); output != "" {
+			if len(output) > maxHookOutput {
+				output = output[:maxHookOutput] + "\n... (output truncated)"
+			}
+			sb.WriteString("\n" + output)
+		}
+		switch {
+		case err != nil && exitCode < 0:
+			sb.WriteString(fmt.Sprintf("\n(failed to run: %v)", err))
+		case exitCode != 0:
+			sb.WriteString(fmt.Sprintf("\n(exit code %d)", exitCode))
+		case output == "":
+			sb.WriteString(" (ok)")
+		}
+	}
+
+	// 格式化工具可能改写了文件，刷新读取状态并提醒模型
+	var changed []string
+	for path, before := range hashes {
+		data, err := os.ReadFile(path)
+		if err != nil || HashContent(data) == before {
+			continue
+		}
+		RecordFileWrite(path)
+		changed = append(changed, path)
+	}
+	sort.Strings(changed)
+	for _, path := range changed {
+		sb.WriteString(fmt.Sprintf("\nNote: the hooks modified %s; Read it again before making further edits.", path))
+	}
+	return sb.String()
+}
+
+// hookMatchesExtension 判断hook是否适用于该扩展名
+func hookMatchesExtension(hook PostEditHook, ext string) bool {
+	for _, e := range hook.Extensions {
+		e = strings.ToLower(e)
+		if !strings.HasPrefix(e, ".") {
+			e = "." + e
+		}
+		if e == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// expandHookCommand 替换占位符：{file} 文件路径，{dir} 所在目录，{pkg} 相对项目根目录的包路径
+func expandHookCommand(template, path, root string) string {
+	file := path
+	dir := filepath.Dir(path)
+	pkg := dir
+	if rel, err := filepath.Rel(root, path); err == nil && !strings.HasPrefix(rel, "..") {
+		file = rel
+		dir = filepath.Dir(rel)
+		pkg = filepath.ToSlash(dir)
+	}
+
+	replacer := strings.NewReplacer(
+		"{file}", quoteHookArg(file),
+		"{dir}", quoteHookArg(dir),
+		"{pkg}", quoteHookArg(pkg),
+	)
+	return replacer.Replace(template)
+}
+
+// quoteHookArg 路径包含空格等特殊字符时加引号
+func quoteHookArg(s string) string {
+	if !strings.ContainsAny(s, " \t'\"$&;|()<>") {
+		return s
+	}
+	if runtime.GOOS == "windows" {
+		return `"` + s + `"`
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// runHookCommand 在项目根目录执行命令，返回合并输出和退出码（无法启动时为-1）
+func runHookCommand(hc hookCommand, root string) (string, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), hc.timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/c", hc.command)
+	} else {
+		cmd = exec.CommandContext(ctx, "/bin/bash", "-c", hc.command)
+	}
+	cmd.Dir = root
+
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return string(output), -1, fmt.Errorf("timed out after %v", hc.timeout)
+	}
+	if err != nil {
+		if exitError, ok := err.(*exec.ExitError); ok {
+			return string(output), exitError.ExitCode(), err
+		}
+		return string(output), -1, err
+	}
+	return string(output), 0, nil
+}
@@ -1,14 +1,40 @@
 package function
 
 import (
-	"encoding/json"
+	"fmt"
 	"log"
+	"lukatincode/walker"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 )
 
-func LS(path string, ignore []string) string {
+// LSParams LS函数签名中的参数顺序
+var LSParams = []string{"path", "ignore", "include_hidden", "depth"}
+
+const (
+	LSMaxEntries        = 500 // 树中最多列出的条目数
+	lsMaxDepth          = 10
+	lsCollapseThreshold = 100 // 子项超过该数量的目录（根目录除外）只显示数量，不展开
+)
+
+// lsNode 树中的一个条目
+type lsNode struct {
+	name     string
+	dir      *walker.Dir // 目录才有
+	size     int64
+	modTime  time.Time
+	children []*lsNode
+	count    int    // 目录的子项数量（已应用忽略规则），-1表示无法读取
+	note     string // 未展开的原因
+}
+
+// LS 以缩进树列出目录，depth 控制展开层数（默认1）；条目附带大小与修改时间，目录附带子项数量。
+// 遵循 .gitignore / .ignore / .lukatinignore，include_hidden 包含隐藏文件；ignore 为额外的glob模式。
+// 子项过多的目录自动折叠，总条目数超过 LSMaxEntries 时不再展开更深的目录。
+func LS(path string, ignore []string, include_hidden bool, depth int) string {
 	// 记录开始时间
 	start := time.Now()
 	
@@ -18,51 +44,189 @@ func LS(path string, ignore []string) string {
 	if err == nil {
 		defer logFile.Close()
 		logger = log.New(logFile, "", log.LstdFlags)
-		logger.Printf("LS函数调用 - path: %s, ignore: %v", path, ignore)
+		logger.Printf("LS函数调用 - path: %s, ignore: %v, include_hidden: %v, depth: %d", path, ignore, include_hidden, depth)
 	}
 
-	if !filepath.IsAbs(path) {
-		duration := time.Since(start)
-		if logger != nil {
-			logger.Printf("LS函数返回 - 错误: Path must be absolute, 耗时: %v", duration)
-		}
-		return `{"error": "Path must be absolute"}`
+	if strings.TrimSpace(path) == "" {
+		path = "."
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	if depth <= 0 {
+		depth = 1
+	}
+	if depth > lsMaxDepth {
+		depth = lsMaxDepth
+	}
+
+	ignoreGlobs, err := walker.CompileGlobs(ignore)
+	if err != nil {
+		return fmt.Sprintf("Error: invalid ignore pattern: %v", err)
 	}
 
-	entries, err := os.ReadDir(path)
+	info, err := os.Stat(absPath)
 	if err != nil {
 		duration := time.Since(start)
 		if logger != nil {
 			logger.Printf("LS函数返回 - 读取目录错误: %s, 耗时: %v", err.Error(), duration)
 		}
-		return `{"error": "` + err.Error() + `"}`
+		return fmt.Sprintf("Error: %v", err)
+	}
+	if !info.IsDir() {
+		return fmt.Sprintf("Error: %s is not a directory. Use Read to view files.", absPath)
 	}
 
-	var result []string
-	for _, entry := range entries {
-		name := entry.Name()
+	dir, err := walker.OpenDir(absPath, walker.Options{IncludeHidden: include_hidden})
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	root := &lsNode{name: absPath, dir: dir, modTime: info.ModTime()}
+	total, truncated := buildLSTree(root, depth, ignoreGlobs)
+	if root.count < 0 {
+		return fmt.Sprintf("Error: cannot read directory %s", absPath)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("- %s/ (%s)\n", strings.TrimSuffix(filepath.ToSlash(absPath), "/"), lsEntryCount(root.count)))
+	writeLSTree(&sb, root.children, 1)
+	result := strings.TrimRight(sb.String(), "\n")
+	if truncated {
+		if len(root.children) < root.count {
+			result += fmt.Sprintf("\n\n[Showing the first %d of %d entries. LS a subdirectory or use Glob to narrow the listing.]", len(root.children), root.count)
+		} else {
+			result += fmt.Sprintf("\n\n[Listing stopped at %d entries; directories marked \"not expanded\" were left out. LS a subdirectory or use a smaller depth to see more.]", total)
+		}
+	}
+
+	duration := time.Since(start)
+	if logger != nil {
+		logger.Printf("LS函数返回 - 条目数: %d, 截断: %v, 耗时: %v", total, truncated, duration)
+	}
+	return result
+}
 
-		shouldIgnore := false
-		for _, pattern := range ignore {
-			if matched, _ := filepath.Match(pattern, name); matched {
-				shouldIgnore = true
-				break
+// buildLSTree 逐层（广度优先）展开目录，保证条目数受限时优先显示较浅的层级
+func buildLSTree(root *lsNode, depth int, ignoreGlobs *walker.GlobSet) (int, bool) {
+	total := 0
+	truncated := false
+	readLSChildren(root, ignoreGlobs, root.dir.Path)
+	level := []*lsNode{root}
+	for d := 1; d <= depth && len(level) > 0; d++ {
+		var next []*lsNode
+		for _, node := range level {
+			if node.children == nil {
+				continue
+			}
+			if node == root {
+				// 根目录总是展开，子项过多时只显示前面的部分
+				if len(node.children) > LSMaxEntries {
+					node.children = node.children[:LSMaxEntries]
+					truncated = true
+				}
+			} else if len(node.children) > lsCollapseThreshold {
+				node.note = "collapsed"
+				node.children = nil
+				continue
+			}
+			if node != root && total+len(node.children) > LSMaxEntries {
+				node.note = "not expanded"
+				node.children = nil
+				truncated = true
+				continue
+			}
+			total += len(node.children)
+			for _, child := range node.children {
+				if child.dir == nil {
+					continue
+				}
+				// 读取子目录以得到子项数量；超出depth的目录只显示数量
+				readLSChildren(child, ignoreGlobs, root.dir.Path)
+				if d < depth {
+					next = append(next, child)
+				} else {
+					child.children = nil
+				}
 			}
 		}
+		level = next
+	}
+	return total, truncated
+}
 
-		if !shouldIgnore {
-			if entry.IsDir() {
-				result = append(result, name+"/")
-			} else {
-				result = append(result, name)
+// readLSChildren 读取目录的子项，目录在前、按名称排序
+func readLSChildren(node *lsNode, ignoreGlobs *walker.GlobSet, rootPath string) {
+	entries, err := node.dir.Entries()
+	if err != nil {
+		node.count = -1
+		return
+	}
+	children := []*lsNode{}
+	for _, entry := range entries {
+		if !ignoreGlobs.Empty() {
+			rel, _ := filepath.Rel(rootPath, filepath.Join(node.dir.Path, entry.Name()))
+			if ignoreGlobs.Match(filepath.ToSlash(rel)) {
+				continue
 			}
 		}
+		child := &lsNode{name: entry.Name()}
+		if info, err := entry.Info(); err == nil {
+			child.size = info.Size()
+			child.modTime = info.ModTime()
+		}
+		if entry.IsDir() {
+			child.dir = node.dir.Sub(entry.Name())
+		}
+		children = append(children, child)
+	}
+	sort.SliceStable(children, func(i, j int) bool {
+		return children[i].dir != nil && children[j].dir == nil
+	})
+	node.children = children
+	node.count = len(children)
+}
+
+// writeLSTree 输出缩进树
+func writeLSTree(sb *strings.Builder, nodes []*lsNode, indent int) {
+	pad := strings.Repeat("  ", indent)
+	for _, node := range nodes {
+		mtime := node.modTime.Format("2006-01-02 15:04")
+		if node.dir == nil {
+			sb.WriteString(fmt.Sprintf("%s- %s (%s, %s)\n", pad, node.name, formatLSSize(node.size), mtime))
+			continue
+		}
+		detail := lsEntryCount(node.count)
+		if node.count < 0 {
+			detail = "unreadable"
+		}
+		if node.note != "" {
+			detail += ", " + node.note
+		}
+		sb.WriteString(fmt.Sprintf("%s- %s/ (%s, %s)\n", pad, node.name, detail, mtime))
+		writeLSTree(sb, node.children, indent+1)
 	}
+}
 
-	jsonResult, _ := json.Marshal(result)
-	duration := time.Since(start)
-	if logger != nil {
-		logger.Printf("LS函数返回 - 文件数: %d, 耗时: %v, 结果: %s", len(result), duration, string(jsonResult))
+func lsEntryCount(n int) string {
+	if n == 1 {
+		return "1 entry"
+	}
+	return fmt.Sprintf("%d entries", n)
+}
+
+// formatLSSize 以易读的单位显示文件大小
+func formatLSSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	value := float64(size) / unit
+	for _, suffix := range []string{"KB", "MB", "GB"} {
+		if value < unit {
+			return fmt.Sprintf("%.1f %s", value, suffix)
+		}
+		value /= unit
 	}
-	return string(jsonResult)
+	return fmt.Sprintf("%.1f TB", value)
 }
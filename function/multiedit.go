@@ -1,6 +1,7 @@
 package function
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -35,43 +36,56 @@ func MultiEdit(file_path string, edits []EditOperation) string {
 		return "Error: no edits provided"
 	}
 
+	// 检查文件已被Read且之后未被修改
+	if err := CheckFileFresh(file_path); err != nil {
+		return err.Error()
+	}
+
 	content, err := os.ReadFile(file_path)
 	if err != nil {
 		return fmt.Sprintf("Error reading file: %v", err)
 	}
 
-	currentContent := string(content)
+	currentContent, format := DecodeText(content)
 	totalReplacements := 0
 
 	for i, edit := range edits {
+		edit.OldString = NormalizeLineEndings(edit.OldString)
+		edit.NewString = NormalizeLineEndings(edit.NewString)
 		if edit.OldString == edit.NewString {
 			return fmt.Sprintf("Error in edit %d: old_string and new_string must be different", i+1)
 		}
 
-		count := strings.Count(currentContent, edit.OldString)
-		expectedReplacements := edit.ExpectedReplacements
-		if expectedReplacements <= 0 {
-			expectedReplacements = 1
+		// 精确匹配失败时依次尝试空白归一化和缩进相对匹配
+		match, err := ApplyEditMatch(currentContent, edit.OldString, edit.NewString, false, edit.ExpectedReplacements)
+		if err != nil {
+			msg := err.Error()
+			// MultiEdit没有replace_all参数
+			var countErr *MatchCountError
+			if errors.As(err, &countErr) {
+				msg = countErr.Message(false)
+			}
+			return fmt.Sprintf("Error in edit %d: %s", i+1, strings.TrimPrefix(msg, "Error: "))
 		}
 
-		if count != expectedReplacements {
-			return fmt.Sprintf("Error in edit %d: expected %d replacements but found %d occurrences", i+1, expectedReplacements, count)
-		}
-
-		if count == 0 {
-			return fmt.Sprintf("Error in edit %d: old_string not found in file", i+1)
-		}
-
-		currentContent = strings.ReplaceAll(currentContent, edit.OldString, edit.NewString)
-		totalReplacements += count
+		currentContent = match.NewContent
+		totalReplacements += match.Replaced
 	}
 
-	err = os.WriteFile(file_path, []byte(currentContent), 0644)
+	newData, err := EncodeText(currentContent, format)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	err = os.WriteFile(file_path, newData, 0644)
 	if err != nil {
 		return fmt.Sprintf("Error writing file: %v", err)
 	}
+	RecordFileWrite(file_path)
 
 	result := fmt.Sprintf("Successfully made %d total replacement(s) across %d edit(s) in %s", totalReplacements, len(edits), filepath.Base(file_path))
+	// 执行项目设置中的格式化/检查hook，诊断信息附加到结果中
+	result += RunPostEditHooks(file_path)
+
 	if logFile != nil {
 		logger := log.New(logFile, "", log.LstdFlags)
 		logger.Printf("MultiEdit函数返回 - 成功编辑: %s", result)
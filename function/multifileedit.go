@@ -0,0 +1,233 @@
+package function
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileEditOperation 跨文件编辑中的一次替换
+type FileEditOperation struct {
+	FilePath             string `json:"file_path"`
+	OldString            string `json:"old_string"`
+	NewString            string `json:"new_string"`
+	ReplaceAll           bool   `json:"replace_all"`
+	ExpectedReplacements int    `json:"expected_replacements"`
+}
+
+// ParseFileEdits 解析edits参数：JSON数组，或 {"edits": [...]} 包装
+func ParseFileEdits(editsJSON string) ([]FileEditOperation, error) {
+	editsJSON = strings.TrimSpace(editsJSON)
+	if editsJSON == "" {
+		return nil, fmt.Errorf("Error: no edits provided")
+	}
+
+	var edits []FileEditOperation
+	if err := json.Unmarshal([]byte(editsJSON), &edits); err != nil {
+		var wrapped struct {
+			Edits []FileEditOperation `json:"edits"`
+		}
+		if err2 := json.Unmarshal([]byte(editsJSON), &wrapped); err2 != nil {
+			return nil, fmt.Errorf("Error: edits must be a JSON array of {file_path, old_string, new_string, replace_all, expected_replacements}: %v", err)
+		}
+		edits = wrapped.Edits
+	}
+	if len(edits) == 0 {
+		return nil, fmt.Errorf("Error: no edits provided")
+	}
+	return edits, nil
+}
+
+// PrepareMultiFileEdit 在内存中校验并应用所有文件的所有编辑，任何一处失败都不会修改磁盘。
+// 同一文件的编辑按给出的顺序依次作用于上一次的结果；所有失败会一起报告。
+func PrepareMultiFileEdit(edits []FileEditOperation) ([]*PatchedFile, error) {
+	var files []*PatchedFile
+	byPath := make(map[string]*PatchedFile)
+	editCount := make(map[*PatchedFile]int)
+	failed := make(map[string]bool)
+	var failures []string
+
+	for i, edit := range edits {
+		label := fmt.Sprintf("edit %d (%s)", i+1, edit.FilePath)
+		if !filepath.IsAbs(edit.FilePath) {
+			failures = append(failures, fmt.Sprintf("%s: file_path must be absolute", label))
+			continue
+		}
+		path := filepath.Clean(edit.FilePath)
+		if failed[path] {
+			continue
+		}
+		oldString := NormalizeLineEndings(edit.OldString)
+		newString := NormalizeLineEndings(edit.NewString)
+		if oldString == newString {
+			failures = append(failures, fmt.Sprintf("%s: old_string and new_string must be different", label))
+			failed[path] = true
+			continue
+		}
+
+		pf, ok := byPath[path]
+		if !ok {
+			var err error
+			pf, err = loadEditTarget(path, oldString)
+			if err != nil {
+				failures = append(failures, fmt.Sprintf("%s: %s", label, strings.TrimPrefix(err.Error(), "Error: ")))
+				failed[path] = true
+				continue
+			}
+			byPath[path] = pf
+			files = append(files, pf)
+			if pf.Operation == "create" {
+				// 新建文件：第一处编辑的new_string即文件内容
+				pf.NewContent = newString
+				editCount[pf]++
+				continue
+			}
+		}
+
+		match, err := ApplyEditMatch(pf.NewContent, oldString, newString, edit.ReplaceAll, edit.ExpectedReplacements)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", label, strings.TrimPrefix(err.Error(), "Error: ")))
+			failed[path] = true
+			continue
+		}
+		pf.NewContent = match.NewContent
+		editCount[pf]++
+		if match.Fuzzy() {
+			pf.Notes = append(pf.Notes, fmt.Sprintf("edit %d: %s", i+1, match.Describe()))
+		}
+	}
+
+	for _, pf := range files {
+		if failed[pf.Path] {
+			continue
+		}
+		if _, err := EncodeText(pf.NewContent, pf.Format); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", pf.Path, err))
+		}
+		pf.Notes = append([]string{fmt.Sprintf("%d edit(s)", editCount[pf])}, pf.Notes...)
+	}
+
+	if len(failures) > 0 {
+		return nil, fmt.Errorf("Error: %d of %d edit(s) failed validation, no files were changed:\n- %s",
+			len(failures), len(edits), strings.Join(failures, "\n- "))
+	}
+	return files, nil
+}
+
+// loadEditTarget 读取要编辑的文件；文件不存在且old_string为空时视为新建
+func loadEditTarget(path, oldString string) (*PatchedFile, error) {
+	if isSensitivePath(path) {
+		return nil, fmt.Errorf("Error: Writing to sensitive system paths is not allowed: %s", path)
+	}
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		if oldString != "" {
+			return nil, fmt.Errorf("Error: file does not exist. Use an empty old_string to create it")
+		}
+		return &PatchedFile{Path: path, OldPath: path, Operation: "create", Mode: 0644, Format: DefaultTextFormat()}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error: %v", err)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("Error: path is a directory")
+	}
+	if err := CheckFileFresh(path); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading file: %v", err)
+	}
+	content, format := DecodeText(data)
+	return &PatchedFile{
+		Path:       path,
+		OldPath:    path,
+		Operation:  "modify",
+		OldContent: content,
+		NewContent: content,
+		Mode:       info.Mode(),
+		Format:     format,
+		oldData:    data,
+	}, nil
+}
+
+// CombinedDiff 所有文件的diff合并为一份，用于一次性确认
+func CombinedDiff(files []*PatchedFile) string {
+	var parts []string
+	for _, pf := range files {
+		oldPath := pf.OldPath
+		if pf.Operation == "create" {
+			oldPath = devNull
+		}
+		header := fmt.Sprintf("%s %s", pf.Operation, pf.Path)
+		if len(pf.Notes) > 0 {
+			header += " (" + strings.Join(pf.Notes, "; ") + ")"
+		}
+		parts = append(parts, header)
+		if diff := UnifiedDiff(oldPath, pf.Path, pf.OldContent, pf.NewContent); diff != "" {
+			parts = append(parts, diff)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// MultiFileEdit 跨多个文件的事务性编辑（无UI确认，供Task子代理使用）
+func MultiFileEdit(edits string) string {
+	start := time.Now()
+
+	// 记录日志
+	logFile, err := os.OpenFile("./log/multifileedit.txt", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	var logger *log.Logger
+	if err == nil {
+		defer logFile.Close()
+		logger = log.New(logFile, "", log.LstdFlags)
+		logger.Printf("MultiFileEdit函数调用 - edits_length: %d", len(edits))
+		defer func() {
+			logger.Printf("MultiFileEdit函数执行完成 - 耗时: %v", time.Since(start))
+		}()
+	}
+
+	operations, err := ParseFileEdits(edits)
+	if err != nil {
+		return err.Error()
+	}
+	files, err := PrepareMultiFileEdit(operations)
+	if err != nil {
+		if logger != nil {
+			logger.Printf("MultiFileEdit函数返回 - 校验失败: %v", err)
+		}
+		return err.Error()
+	}
+	if err := CommitPatch(files); err != nil {
+		if logger != nil {
+			logger.Printf("MultiFileEdit函数返回 - 写入失败: %v", err)
+		}
+		return err.Error()
+	}
+
+	result := FormatMultiFileEditResult(files, len(operations))
+	result += RunPostEditHooks(PatchedPaths(files)...)
+	if logger != nil {
+		logger.Printf("MultiFileEdit函数返回 - 成功: %d个文件", len(files))
+	}
+	return result
+}
+
+// FormatMultiFileEditResult 生成返回给模型的结果摘要
+func FormatMultiFileEditResult(files []*PatchedFile, editCount int) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Successfully applied %d edit(s) across %d file(s):\n", editCount, len(files)))
+	for _, pf := range files {
+		verb := "modified"
+		if pf.Operation == "create" {
+			verb = "created"
+		}
+		sb.WriteString(fmt.Sprintf("- %s %s (%s)\n", verb, pf.Path, strings.Join(pf.Notes, "; ")))
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
@@ -0,0 +1,465 @@
+package function
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// NotebookEditParams NotebookEdit函数签名中的参数顺序
+var NotebookEditParams = []string{"notebook_path", "cell_number", "new_source", "cell_id", "cell_type", "edit_mode"}
+
+const maxNotebookOutput = 4000 // 单个cell输出的最大字符数
+
+var ansiEscapeRegex = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+// Notebook 以通用map形式保存的.ipynb，修改cell时保留其余所有字段和元数据
+type Notebook struct {
+	Path  string
+	raw   map[string]interface{}
+	cells []interface{}
+	mode  os.FileMode
+}
+
+// NotebookChange 一次cell修改的前后内容，用于确认与结果展示
+type NotebookChange struct {
+	Mode      string // "replace", "insert", "delete"
+	Index     int
+	CellID    string
+	CellType  string
+	OldSource string
+	NewSource string
+}
+
+// LoadNotebook 读取并解析.ipynb文件
+func LoadNotebook(notebook_path string) (*Notebook, error) {
+	if !filepath.IsAbs(notebook_path) {
+		return nil, fmt.Errorf("notebook_path must be absolute")
+	}
+	if strings.ToLower(filepath.Ext(notebook_path)) != ".ipynb" {
+		return nil, fmt.Errorf("file must be a Jupyter notebook (.ipynb): %s", notebook_path)
+	}
+	info, err := os.Stat(notebook_path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("notebook does not exist: %s", notebook_path)
+		}
+		return nil, fmt.Errorf("checking notebook: %v", err)
+	}
+	data, err := os.ReadFile(notebook_path)
+	if err != nil {
+		return nil, fmt.Errorf("reading notebook: %v", err)
+	}
+
+	// UseNumber保证execution_count、metadata中的数字原样写回
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var raw map[string]interface{}
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("notebook is not valid JSON: %v", err)
+	}
+	cells, _ := raw["cells"].([]interface{})
+	return &Notebook{Path: notebook_path, raw: raw, cells: cells, mode: info.Mode()}, nil
+}
+
+// Language 返回notebook的语言（来自kernelspec/language_info）
+func (nb *Notebook) Language() string {
+	metadata, _ := nb.raw["metadata"].(map[string]interface{})
+	if info, ok := metadata["language_info"].(map[string]interface{}); ok {
+		if name, ok := info["name"].(string); ok && name != "" {
+			return name
+		}
+	}
+	if spec, ok := metadata["kernelspec"].(map[string]interface{}); ok {
+		if lang, ok := spec["language"].(string); ok && lang != "" {
+			return lang
+		}
+	}
+	return "python"
+}
+
+// supportsCellIDs nbformat 4.5 及以上的cell必须有id
+func (nb *Notebook) supportsCellIDs() bool {
+	major := fmt.Sprint(nb.raw["nbformat"])
+	minor := fmt.Sprint(nb.raw["nbformat_minor"])
+	if major != "4" {
+		return major > "4"
+	}
+	var m int
+	fmt.Sscanf(minor, "%d", &m)
+	return m >= 5
+}
+
+// Render 渲染所有cell（id、类型、源码与文本输出），图片输出只给出摘要
+func (nb *Notebook) Render() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Notebook: %s | Cells: %d | Language: %s\n", filepath.Base(nb.Path), len(nb.cells), nb.Language()))
+
+	for i := range nb.cells {
+		cell, _ := nb.cells[i].(map[string]interface{})
+		if cell == nil {
+			continue
+		}
+		cellType, _ := cell["cell_type"].(string)
+		header := fmt.Sprintf("<cell index=%d", i)
+		if id, ok := cell["id"].(string); ok && id != "" {
+			header += fmt.Sprintf(" id=%q", id)
+		}
+		header += fmt.Sprintf(" type=%q", cellType)
+		if count, ok := cell["execution_count"]; ok && count != nil {
+			header += fmt.Sprintf(" execution_count=%v", count)
+		}
+		sb.WriteString("\n" + header + ">\n")
+		sb.WriteString(cellSource(cell))
+		sb.WriteString("\n</cell>\n")
+
+		if outputs := renderCellOutputs(cell); outputs != "" {
+			sb.WriteString(fmt.Sprintf("<outputs index=%d>\n%s\n</outputs>\n", i, outputs))
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// cellSource 把source（字符串或字符串数组）合并为一个字符串
+func cellSource(cell map[string]interface{}) string {
+	return joinNotebookText(cell["source"])
+}
+
+func joinNotebookText(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []interface{}:
+		var sb strings.Builder
+		for _, part := range v {
+			if s, ok := part.(string); ok {
+				sb.WriteString(s)
+			}
+		}
+		return sb.String()
+	}
+	return ""
+}
+
+// splitNotebookSource 按Jupyter的习惯把源码拆成带换行的行数组
+func splitNotebookSource(source string) []interface{} {
+	lines := strings.SplitAfter(NormalizeLineEndings(source), "\n")
+	result := make([]interface{}, 0, len(lines))
+	for _, line := range lines {
+		if line != "" {
+			result = append(result, line)
+		}
+	}
+	return result
+}
+
+// renderCellOutputs 渲染cell的输出，文本原样（截断），图片等二进制只给出类型和大小
+func renderCellOutputs(cell map[string]interface{}) string {
+	outputs, _ := cell["outputs"].([]interface{})
+	var parts []string
+	for _, o := range outputs {
+		output, _ := o.(map[string]interface{})
+		if output == nil {
+			continue
+		}
+		switch output["output_type"] {
+		case "stream":
+			parts = append(parts, joinNotebookText(output["text"]))
+		case "error":
+			ename, _ := output["ename"].(string)
+			evalue, _ := output["evalue"].(string)
+			text := fmt.Sprintf("%s: %s", ename, evalue)
+			if traceback, ok := output["traceback"].([]interface{}); ok {
+				var lines []string
+				for _, t := range traceback {
+					if s, ok := t.(string); ok {
+						lines = append(lines, s)
+					}
+				}
+				text = strings.Join(lines, "\n")
+			}
+			parts = append(parts, ansiEscapeRegex.ReplaceAllString(text, ""))
+		case "execute_result", "display_data":
+			data, _ := output["data"].(map[string]interface{})
+			parts = append(parts, renderOutputData(data)...)
+		}
+	}
+
+	for i := range parts {
+		parts[i] = strings.TrimRight(parts[i], "\n")
+	}
+	text := strings.Join(parts, "\n")
+	if len(text) > maxNotebookOutput {
+		text = text[:maxNotebookOutput] + fmt.Sprintf("\n... [output truncated, %d characters total]", len(text))
+	}
+	return text
+}
+
+// renderOutputData 处理mime bundle：优先text/plain，图片给出摘要
+func renderOutputData(data map[string]interface{}) []string {
+	mimeTypes := make([]string, 0, len(data))
+	for mime := range data {
+		mimeTypes = append(mimeTypes, mime)
+	}
+	sort.Strings(mimeTypes)
+
+	var parts []string
+	for _, mime := range mimeTypes {
+		if strings.HasPrefix(mime, "image/") {
+			size := len(joinNotebookText(data[mime]))
+			parts = append(parts, fmt.Sprintf("[%s output omitted, %.1f KB base64]", mime, float64(size)/1024))
+		}
+	}
+	if text, ok := data["text/plain"]; ok {
+		parts = append(parts, joinNotebookText(text))
+	} else if html, ok := data["text/html"]; ok {
+		parts = append(parts, "[text/html output]\n"+joinNotebookText(html))
+	} else if len(parts) == 0 && len(mimeTypes) > 0 {
+		parts = append(parts, fmt.Sprintf("[output of type %s omitted]", strings.Join(mimeTypes, ", ")))
+	}
+	return parts
+}
+
+// findCell 按id或序号定位cell。cell_id优先，其次为序号
+func (nb *Notebook) findCell(cellNumber int, cellID string) (int, error) {
+	if cellID != "" {
+		for i, c := range nb.cells {
+			cell, _ := c.(map[string]interface{})
+			if id, _ := cell["id"].(string); id == cellID {
+				return i, nil
+			}
+		}
+		// 允许模型把序号当作id传入
+		var n int
+		if _, err := fmt.Sscanf(cellID, "%d", &n); err == nil && fmt.Sprint(n) == cellID {
+			return n, nil
+		}
+		return -1, fmt.Errorf("cell with id %q not found", cellID)
+	}
+	return cellNumber, nil
+}
+
+// ApplyEdit 在内存中修改cell，返回修改前后的内容
+func (nb *Notebook) ApplyEdit(cellNumber int, cellID, newSource, cellType, editMode string) (*NotebookChange, error) {
+	if editMode == "" {
+		editMode = "replace"
+	}
+	if cellType != "" && cellType != "code" && cellType != "markdown" && cellType != "raw" {
+		return nil, fmt.Errorf("cell_type must be code, markdown or raw")
+	}
+
+	index, err := nb.findCell(cellNumber, cellID)
+	if err != nil {
+		return nil, err
+	}
+
+	change := &NotebookChange{Mode: editMode, Index: index}
+	switch editMode {
+	case "insert":
+		// 指定cell_id时插入到该cell之后，否则插入到cell_number位置
+		if cellID != "" {
+			index++
+			change.Index = index
+		}
+		if index < 0 || index > len(nb.cells) {
+			return nil, fmt.Errorf("cell_number %d is out of range for insert (notebook has %d cells)", index, len(nb.cells))
+		}
+		if cellType == "" {
+			return nil, fmt.Errorf("cell_type is required when using edit_mode=insert")
+		}
+		cell := newNotebookCell(cellType, newSource)
+		if nb.supportsCellIDs() {
+			cell["id"] = generateCellID()
+			change.CellID = cell["id"].(string)
+		}
+		nb.cells = append(nb.cells[:index], append([]interface{}{cell}, nb.cells[index:]...)...)
+		change.CellType = cellType
+		change.NewSource = newSource
+
+	case "delete", "replace":
+		if index < 0 || index >= len(nb.cells) {
+			return nil, fmt.Errorf("cell_number %d is out of range (notebook has %d cells)", index, len(nb.cells))
+		}
+		cell, _ := nb.cells[index].(map[string]interface{})
+		if cell == nil {
+			return nil, fmt.Errorf("cell %d is malformed", index)
+		}
+		change.CellID, _ = cell["id"].(string)
+		change.CellType, _ = cell["cell_type"].(string)
+		change.OldSource = cellSource(cell)
+
+		if editMode == "delete" {
+			nb.cells = append(nb.cells[:index], nb.cells[index+1:]...)
+			break
+		}
+		if cellType != "" && cellType != change.CellType {
+			setCellType(cell, cellType)
+			change.CellType = cellType
+		}
+		cell["source"] = splitNotebookSource(newSource)
+		if change.CellType == "code" {
+			// 源码变化后旧的输出已失效
+			cell["execution_count"] = nil
+			cell["outputs"] = []interface{}{}
+		}
+		change.NewSource = newSource
+
+	default:
+		return nil, fmt.Errorf("edit_mode must be replace, insert or delete")
+	}
+
+	nb.raw["cells"] = nb.cells
+	return change, nil
+}
+
+// newNotebookCell 创建符合nbformat 4的新cell
+func newNotebookCell(cellType, source string) map[string]interface{} {
+	cell := map[string]interface{}{
+		"cell_type": cellType,
+		"metadata":  map[string]interface{}{},
+		"source":    splitNotebookSource(source),
+	}
+	if cellType == "code" {
+		cell["execution_count"] = nil
+		cell["outputs"] = []interface{}{}
+	}
+	return cell
+}
+
+// setCellType 切换cell类型并补齐/移除code cell特有的字段
+func setCellType(cell map[string]interface{}, cellType string) {
+	cell["cell_type"] = cellType
+	if cellType == "code" {
+		cell["execution_count"] = nil
+		cell["outputs"] = []interface{}{}
+	} else {
+		delete(cell, "execution_count")
+		delete(cell, "outputs")
+	}
+}
+
+func generateCellID() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%08x", time.Now().UnixNano()&0xffffffff)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Marshal 按Jupyter的格式（1空格缩进、key排序、不转义非ASCII）序列化
+func (nb *Notebook) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", " ")
+	if err := encoder.Encode(nb.raw); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Save 写回磁盘并更新读取状态
+func (nb *Notebook) Save() error {
+	data, err := nb.Marshal()
+	if err != nil {
+		return fmt.Errorf("serializing notebook: %v", err)
+	}
+	if err := os.WriteFile(nb.Path, data, nb.mode); err != nil {
+		return fmt.Errorf("writing notebook: %v", err)
+	}
+	RecordFileWrite(nb.Path)
+	return nil
+}
+
+// Describe 返回给模型的修改结果描述
+func (c *NotebookChange) Describe(notebook_path string) string {
+	target := fmt.Sprintf("cell %d", c.Index)
+	if c.CellID != "" {
+		target += fmt.Sprintf(" (id %s)", c.CellID)
+	}
+	switch c.Mode {
+	case "insert":
+		return fmt.Sprintf("Inserted new %s %s into %s", c.CellType, target, filepath.Base(notebook_path))
+	case "delete":
+		return fmt.Sprintf("Deleted %s from %s", target, filepath.Base(notebook_path))
+	default:
+		return fmt.Sprintf("Updated %s %s in %s", c.CellType, target, filepath.Base(notebook_path))
+	}
+}
+
+// NotebookRead 读取Jupyter notebook并渲染所有cell及其输出
+func NotebookRead(notebook_path string) string {
+	start := time.Now()
+
+	// 记录日志
+	logFile, err := os.OpenFile("./log/notebook.txt", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	var logger *log.Logger
+	if err == nil {
+		defer logFile.Close()
+		logger = log.New(logFile, "", log.LstdFlags)
+		logger.Printf("NotebookRead函数调用 - notebook_path: %s", notebook_path)
+		defer func() {
+			logger.Printf("NotebookRead函数执行完成 - 耗时: %v", time.Since(start))
+		}()
+	}
+
+	nb, err := LoadNotebook(notebook_path)
+	if err != nil {
+		if logger != nil {
+			logger.Printf("NotebookRead函数返回 - 错误: %v", err)
+		}
+		return fmt.Sprintf("Error: %v", err)
+	}
+
+	// 记录读取时的文件状态（供NotebookEdit校验）
+	RecordFileRead(notebook_path)
+	return nb.Render()
+}
+
+// PrepareNotebookEdit 校验并在内存中完成修改，返回待保存的notebook
+func PrepareNotebookEdit(notebook_path string, cell_number int, new_source string, cell_id string, cell_type string, edit_mode string) (*Notebook, *NotebookChange, error) {
+	nb, err := LoadNotebook(notebook_path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error: %v", err)
+	}
+	if err := CheckFileFresh(notebook_path); err != nil {
+		return nil, nil, err
+	}
+	change, err := nb.ApplyEdit(cell_number, cell_id, new_source, cell_type, edit_mode)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error: %v", err)
+	}
+	return nb, change, nil
+}
+
+// NotebookEdit 修改notebook中的cell（无UI确认，供Task子代理使用）
+func NotebookEdit(notebook_path string, cell_number int, new_source string, cell_id string, cell_type string, edit_mode string) string {
+	logFile, err := os.OpenFile("./log/notebook.txt", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	var logger *log.Logger
+	if err == nil {
+		defer logFile.Close()
+		logger = log.New(logFile, "", log.LstdFlags)
+		logger.Printf("NotebookEdit函数调用 - notebook_path: %s, cell_number: %d, cell_id: %s, edit_mode: %s", notebook_path, cell_number, cell_id, edit_mode)
+	}
+
+	nb, change, err := PrepareNotebookEdit(notebook_path, cell_number, new_source, cell_id, cell_type, edit_mode)
+	if err != nil {
+		if logger != nil {
+			logger.Printf("NotebookEdit函数返回 - 错误: %v", err)
+		}
+		return err.Error()
+	}
+	if err := nb.Save(); err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	return change.Describe(notebook_path)
+}
@@ -0,0 +1,181 @@
+package function
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// 非Go文件的符号大纲：按扩展名用正则识别函数、类等声明，作为go/types分析之外的回退
+
+// OutlineSymbol 大纲中的一个符号
+type OutlineSymbol struct {
+	Name      string
+	Kind      string
+	Line      int    // 从1开始
+	Indent    int    // 声明行的缩进宽度，用于显示嵌套
+	Signature string // 声明所在行（去掉首尾空白）
+}
+
+type outlinePattern struct {
+	kind string
+	re   *regexp.Regexp // 第一个命名分组 name 为符号名
+}
+
+func outlineRule(kind, expr string) outlinePattern {
+	return outlinePattern{kind: kind, re: regexp.MustCompile(expr)}
+}
+
+var (
+	outlinePython = []outlinePattern{
+		outlineRule("class", `^\s*class\s+(?P<name>\w+)`),
+		outlineRule("function", `^\s*(?:async\s+)?def\s+(?P<name>\w+)`),
+	}
+	outlineJS = []outlinePattern{
+		outlineRule("class", `^\s*(?:export\s+)?(?:default\s+)?(?:abstract\s+)?class\s+(?P<name>[\w$]+)`),
+		outlineRule("interface", `^\s*(?:export\s+)?interface\s+(?P<name>[\w$]+)`),
+		outlineRule("type", `^\s*(?:export\s+)?type\s+(?P<name>[\w$]+)\s*(?:<[^=]*>)?\s*=`),
+		outlineRule("enum", `^\s*(?:export\s+)?(?:const\s+)?enum\s+(?P<name>[\w$]+)`),
+		outlineRule("function", `^\s*(?:export\s+)?(?:default\s+)?(?:async\s+)?function\s*\*?\s*(?P<name>[\w$]+)`),
+		outlineRule("function", `^\s*(?:export\s+)?(?:const|let|var)\s+(?P<name>[\w$]+)\s*(?::[^=]+)?=\s*(?:async\s+)?(?:function\b|\([^)]*\)\s*(?::[^=]+)?=>|[\w$]+\s*=>)`),
+		outlineRule("method", `^\s+(?:(?:public|private|protected|static|readonly|async|get|set|override)\s+)*(?P<name>[\w$]+)\s*(?:<[^>]*>)?\([^)]*\)\s*(?::\s*[^{]+)?\{\s*$`),
+	}
+	outlineJava = []outlinePattern{
+		outlineRule("class", `^\s*(?:(?:public|private|protected|static|final|abstract|sealed|partial|internal|data|open)\s+)*(?:class|record)\s+(?P<name>\w+)`),
+		outlineRule("interface", `^\s*(?:(?:public|private|protected|static|internal)\s+)*interface\s+(?P<name>\w+)`),
+		outlineRule("enum", `^\s*(?:(?:public|private|protected|static|internal)\s+)*enum\s+(?:class\s+)?(?P<name>\w+)`),
+		outlineRule("function", `^\s*(?:(?:public|private|protected|static|final|abstract|synchronized|override|async|virtual|internal|suspend|open)\s+)*fun\s+(?:<[^>]*>\s*)?(?:\w+\.)?(?P<name>\w+)\s*\(`),
+		outlineRule("method", `^\s*(?:(?:public|private|protected|static|final|abstract|synchronized|override|async|virtual|internal)\s+)+[\w<>\[\],.?\s]+\s+(?P<name>\w+)\s*\([^;]*$`),
+	}
+	outlineRust = []outlinePattern{
+		outlineRule("function", `^\s*(?:pub(?:\([^)]*\))?\s+)?(?:const\s+)?(?:async\s+)?(?:unsafe\s+)?(?:extern\s+"[^"]*"\s+)?fn\s+(?P<name>\w+)`),
+		outlineRule("struct", `^\s*(?:pub(?:\([^)]*\))?\s+)?struct\s+(?P<name>\w+)`),
+		outlineRule("enum", `^\s*(?:pub(?:\([^)]*\))?\s+)?enum\s+(?P<name>\w+)`),
+		outlineRule("trait", `^\s*(?:pub(?:\([^)]*\))?\s+)?(?:unsafe\s+)?trait\s+(?P<name>\w+)`),
+		outlineRule("impl", `^\s*impl(?:<[^>]*>)?\s+(?:[\w:<>, ]+\s+for\s+)?(?P<name>\w+)`),
+		outlineRule("module", `^\s*(?:pub(?:\([^)]*\))?\s+)?mod\s+(?P<name>\w+)`),
+	}
+	outlineC = []outlinePattern{
+		outlineRule("struct", `^\s*(?:typedef\s+)?(?:struct|union|enum)\s+(?P<name>\w+)\s*\{?\s*$`),
+		outlineRule("class", `^\s*(?:template\s*<[^>]*>\s*)?class\s+(?P<name>\w+)`),
+		outlineRule("namespace", `^\s*namespace\s+(?P<name>\w+)`),
+		outlineRule("function", `^[A-Za-z_][\w\s\*&:<>,]*?[\s\*&](?P<name>[A-Za-z_][\w:~]*)\s*\([^;]*$`),
+	}
+	outlineRuby = []outlinePattern{
+		outlineRule("class", `^\s*class\s+(?P<name>[\w:]+)`),
+		outlineRule("module", `^\s*module\s+(?P<name>[\w:]+)`),
+		outlineRule("method", `^\s*def\s+(?:self\.)?(?P<name>[\w?!=]+)`),
+	}
+	outlinePHP = []outlinePattern{
+		outlineRule("class", `^\s*(?:(?:abstract|final)\s+)?(?:class|interface|trait|enum)\s+(?P<name>\w+)`),
+		outlineRule("function", `^\s*(?:(?:public|private|protected|static|abstract|final)\s+)*function\s+&?(?P<name>\w+)`),
+	}
+	outlineShell = []outlinePattern{
+		outlineRule("function", `^\s*(?:function\s+)?(?P<name>[\w-]+)\s*\(\)\s*\{?`),
+		outlineRule("function", `^\s*function\s+(?P<name>[\w-]+)`),
+	}
+	outlineMarkdown = []outlinePattern{
+		outlineRule("heading", `^(?P<level>#{1,6})\s+(?P<name>.+?)\s*#*\s*$`),
+	}
+	outlineGeneric = []outlinePattern{
+		outlineRule("class", `^\s*(?:export\s+)?(?:class|struct|interface|trait|module)\s+(?P<name>\w+)`),
+		outlineRule("function", `^\s*(?:export\s+)?(?:async\s+)?(?:def|func|function|fn|sub|proc)\s+(?P<name>\w+)`),
+	}
+)
+
+// outlineByExt 扩展名到大纲规则的映射
+var outlineByExt = map[string][]outlinePattern{
+	".py": outlinePython, ".pyi": outlinePython,
+	".js": outlineJS, ".jsx": outlineJS, ".mjs": outlineJS, ".cjs": outlineJS, ".ts": outlineJS, ".tsx": outlineJS,
+	".java": outlineJava, ".kt": outlineJava, ".kts": outlineJava, ".cs": outlineJava, ".scala": outlineJava,
+	".rs": outlineRust,
+	".c":  outlineC, ".h": outlineC, ".cc": outlineC, ".cpp": outlineC, ".cxx": outlineC, ".hpp": outlineC, ".hh": outlineC,
+	".rb":  outlineRuby,
+	".php": outlinePHP,
+	".sh":  outlineShell, ".bash": outlineShell, ".zsh": outlineShell,
+	".md": outlineMarkdown, ".markdown": outlineMarkdown,
+	".swift": outlineGeneric, ".lua": outlineGeneric, ".pl": outlineGeneric, ".ex": outlineGeneric, ".exs": outlineGeneric,
+}
+
+// outlineKeywords 形如函数调用、但属于控制语句的名称
+var outlineKeywords = map[string]bool{
+	"if": true, "for": true, "while": true, "switch": true, "catch": true, "return": true, "function": true,
+	"else": true, "do": true, "try": true, "with": true, "new": true, "sizeof": true, "typeof": true,
+}
+
+// HasOutline 是否支持为该文件生成正则大纲
+func HasOutline(path string) bool {
+	_, ok := outlineByExt[strings.ToLower(filepath.Ext(path))]
+	return ok
+}
+
+// OutlineText 用正则为非Go文件生成符号大纲
+func OutlineText(path, content string) []OutlineSymbol {
+	patterns, ok := outlineByExt[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		patterns = outlineGeneric
+	}
+
+	var symbols []OutlineSymbol
+	inFence := false
+	for i, line := range strings.Split(NormalizeLineEndings(content), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			inFence = !inFence
+		}
+		if inFence || trimmed == "" || strings.HasPrefix(trimmed, "//") || strings.HasPrefix(trimmed, "*") || (strings.HasPrefix(trimmed, "#") && !isMarkdownFile(path)) {
+			continue
+		}
+		for _, p := range patterns {
+			m := p.re.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			name := m[p.re.SubexpIndex("name")]
+			if outlineKeywords[name] {
+				continue
+			}
+			indent := indentWidth(line[:len(line)-len(strings.TrimLeft(line, " \t"))])
+			if level := p.re.SubexpIndex("level"); level > 0 {
+				indent = (len(m[level]) - 1) * 2
+			}
+			symbols = append(symbols, OutlineSymbol{Name: name, Kind: p.kind, Line: i + 1, Indent: indent, Signature: truncateOutline(trimmed)})
+			break
+		}
+	}
+	return symbols
+}
+
+// isMarkdownFile markdown中的 # 是标题而不是注释
+func isMarkdownFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".md" || ext == ".markdown"
+}
+
+// FormatOutline 以缩进显示大纲
+func FormatOutline(path string, symbols []OutlineSymbol) string {
+	if len(symbols) == 0 {
+		return fmt.Sprintf("No symbols found in %s", path)
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%s (regex outline, %d symbol(s)):\n", path, len(symbols)))
+	// 缩进宽度映射为层级
+	var levels []int
+	for _, s := range symbols {
+		for len(levels) > 0 && levels[len(levels)-1] >= s.Indent {
+			levels = levels[:len(levels)-1]
+		}
+		sb.WriteString(fmt.Sprintf("%s%d: %s\n", strings.Repeat("  ", len(levels)+1), s.Line, s.Signature))
+		levels = append(levels, s.Indent)
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+func truncateOutline(s string) string {
+	s = strings.TrimSuffix(strings.TrimSpace(s), "{")
+	if len(s) > grepMaxLineLength {
+		s = s[:grepMaxLineLength] + "..."
+	}
+	return strings.TrimSpace(s)
+}
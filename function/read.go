@@ -1,7 +1,6 @@
 package function
 
 import (
-	"bufio"
 	"encoding/base64"
 	"fmt"
 	"log"
@@ -48,8 +47,8 @@ func Read(file_path string, offset int, limit int) string {
 	ext := strings.ToLower(filepath.Ext(file_path))
 	fileSize := fileInfo.Size()
 	
-	// 记录读取历史（供Edit函数使用）
-	RecordReadHistory(file_path)
+	// 记录读取时的文件状态（供Edit/Write校验）
+	RecordFileRead(file_path)
 	
 	// 4. 处理Jupyter Notebook文件
 	if ext == ".ipynb" {
@@ -84,12 +83,12 @@ func Read(file_path string, offset int, limit int) string {
 		}
 	}
 
-	// 8. 读取文本文件
-	file, err := os.Open(file_path)
+	// 8. 读取文本文件（检测编码、BOM与换行符，统一解码为UTF-8显示）
+	data, err := os.ReadFile(file_path)
 	if err != nil {
 		return fmt.Sprintf("Error opening file: %v", err)
 	}
-	defer file.Close()
+	text, format := DecodeText(data)
 
 	// 9. 设置默认参数
 	if offset <= 0 {
@@ -100,41 +99,26 @@ func Read(file_path string, offset int, limit int) string {
 	}
 
 	// 10. 逐行读取
-	scanner := bufio.NewScanner(file)
 	var lines []string
-	lineNum := 1
-	totalLines := 0
-
-	// 计算总行数（用于显示进度）
-	tempFile, _ := os.Open(file_path)
-	if tempFile != nil {
-		tempScanner := bufio.NewScanner(tempFile)
-		for tempScanner.Scan() {
-			totalLines++
-		}
-		tempFile.Close()
+	allLines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	if text == "" {
+		allLines = nil
 	}
+	totalLines := len(allLines)
 
-	for scanner.Scan() {
-		if lineNum >= offset {
-			line := scanner.Text()
-			
-			// 行长度截断
-			if len(line) > 2000 {
-				line = line[:2000] + "... [truncated - line too long]"
-			}
-			
-			lines = append(lines, fmt.Sprintf("%6d\t%s", lineNum, line))
-			
-			if len(lines) >= limit {
-				break
-			}
+	for lineNum := offset; lineNum <= totalLines; lineNum++ {
+		line := strings.TrimSuffix(allLines[lineNum-1], "\r")
+
+		// 行长度截断
+		if len(line) > 2000 {
+			line = line[:2000] + "... [truncated - line too long]"
 		}
-		lineNum++
-	}
 
-	if err := scanner.Err(); err != nil {
-		return fmt.Sprintf("Error reading file: %v", err)
+		lines = append(lines, fmt.Sprintf("%6d\t%s", lineNum, line))
+
+		if len(lines) >= limit {
+			break
+		}
 	}
 
 	// 11. 处理空文件情况
@@ -161,6 +145,10 @@ func Read(file_path string, offset int, limit int) string {
 	if len(lines) >= limit {
 		header += fmt.Sprintf(" | Truncated (use offset/limit for more)")
 	}
+	if !format.IsDefault() {
+		// 编辑时会自动保持原格式，old_string/new_string中使用普通的\n即可
+		header += fmt.Sprintf(" | Format: %s (preserved on edit)", format.Describe())
+	}
 	
 	result = header + "\n" + strings.Repeat("=", len(header)) + "\n" + result
 
@@ -283,4 +271,4 @@ func getMimeType(ext string) string {
 		return mime
 	}
 	return "application/octet-stream"
-}
\ No newline at end of file
+}
\ No newline at end of file
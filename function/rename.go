@@ -0,0 +1,720 @@
+package function
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// Go标识符重命名：用 go/types 找到定义在整个模块（含测试）中的所有引用，
+// 方法改名时连同必须保持一致的接口方法和其他实现一起改名；
+// 写入前做冲突检查，并用改名后的源码重新类型检查，出现新的编译错误时拒绝。
+
+// RenameParams RenameSymbol函数签名中的参数顺序
+var RenameParams = []string{"symbol", "new_name", "path"}
+
+// renameMaxConflicts 冲突与新增编译错误最多列出的条数
+const renameMaxConflicts = 10
+
+// renameSite 一处需要替换的标识符
+type renameSite struct {
+	pos   token.Position
+	pkg   *packages.Package
+	ident *ast.Ident
+}
+
+// renamePlan 重命名的目标与所有修改位置
+type renamePlan struct {
+	prog    *goProgram
+	obj     types.Object
+	oldName string
+	newName string
+	targets map[string]types.Object // objectKey → 需要一起改名的定义
+	sites   []renameSite
+	docs    []token.Position // 以旧名称开头的文档注释
+}
+
+// PrepareRename 计算把symbol重命名为newName需要的所有文件修改，有冲突时返回错误且不修改任何文件
+func PrepareRename(symbol, newName, path string) ([]*PatchedFile, string, error) {
+	symbol = strings.TrimSpace(symbol)
+	newName = strings.TrimSpace(newName)
+	if symbol == "" {
+		return nil, "", fmt.Errorf("Error: symbol is required")
+	}
+	if !token.IsIdentifier(newName) || newName == "_" {
+		return nil, "", fmt.Errorf("Error: %q is not a valid Go identifier", newName)
+	}
+	if strings.TrimSpace(path) == "" {
+		path = "."
+	}
+
+	prog, err := loadGoProgram(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("Error: %v", err)
+	}
+	obj, err := prog.renameTarget(symbol)
+	if err != nil {
+		return nil, "", err
+	}
+	if obj.Name() == newName {
+		return nil, "", fmt.Errorf("Error: %s is already named %s", symbol, newName)
+	}
+
+	plan := &renamePlan{prog: prog, obj: obj, oldName: obj.Name(), newName: newName, targets: make(map[string]types.Object)}
+	plan.targets[prog.objectKey(obj)] = obj
+
+	var conflicts []string
+	switch o := obj.(type) {
+	case *types.Func:
+		if sig, ok := o.Type().(*types.Signature); ok && sig.Recv() != nil {
+			conflicts = append(conflicts, plan.addRelatedMethods(o)...)
+		}
+	case *types.TypeName:
+		plan.addEmbeddedFields(o)
+	}
+	plan.collectSites()
+	conflicts = append(conflicts, plan.conflicts()...)
+	if len(conflicts) > renameMaxConflicts {
+		conflicts = append(conflicts[:renameMaxConflicts], fmt.Sprintf("... and %d more", len(conflicts)-renameMaxConflicts))
+	}
+	if len(conflicts) > 0 {
+		return nil, "", fmt.Errorf("Error: cannot rename %s to %s:\n- %s", symbol, newName, strings.Join(conflicts, "\n- "))
+	}
+
+	files, err := plan.patchedFiles()
+	if err != nil {
+		return nil, "", err
+	}
+	if errs := prog.verifyRename(files); len(errs) > 0 {
+		return nil, "", fmt.Errorf("Error: renaming %s to %s would introduce compile errors:\n- %s", symbol, newName, strings.Join(errs, "\n- "))
+	}
+	return files, plan.summary(files), nil
+}
+
+// renameTarget 解析symbol为唯一的定义
+func (prog *goProgram) renameTarget(symbol string) (types.Object, error) {
+	var unique []goSymbol
+	seen := make(map[string]bool)
+	for _, sym := range prog.findGoSymbols(symbol) {
+		if key := prog.objectKey(sym.obj); !seen[key] {
+			seen[key] = true
+			unique = append(unique, sym)
+		}
+	}
+	if len(unique) == 0 {
+		return nil, fmt.Errorf("Error: no Go definition found for %q in module %s", symbol, prog.module.Path)
+	}
+	if len(unique) > 1 {
+		var candidates []string
+		for _, sym := range unique {
+			pos := prog.fset.Position(sym.obj.Pos())
+			candidates = append(candidates, fmt.Sprintf("%s %s at %s:%d", goObjectKind(sym.obj), types.ObjectString(sym.obj, nil), normalizeRel(pos.Filename), pos.Line))
+		}
+		return nil, fmt.Errorf("Error: %q is ambiguous, qualify it (e.g. Type.Method or pkg.Name):\n- %s", symbol, strings.Join(candidates, "\n- "))
+	}
+
+	obj := unique[0].obj
+	switch o := obj.(type) {
+	case *types.Var:
+		if o.Embedded() {
+			return nil, fmt.Errorf("Error: %s is an embedded field; rename its type instead", symbol)
+		}
+	case *types.Func:
+		if o.Parent() == o.Pkg().Scope() && (o.Name() == "main" || o.Name() == "init") {
+			return nil, fmt.Errorf("Error: func %s has a special meaning in Go and cannot be renamed", o.Name())
+		}
+	case *types.PkgName:
+		return nil, fmt.Errorf("Error: renaming packages is not supported; use Move to move the package directory")
+	}
+	if !prog.inModule(obj) {
+		return nil, fmt.Errorf("Error: %s is not declared in module %s", symbol, prog.module.Path)
+	}
+	return obj, nil
+}
+
+// inModule 判断定义是否位于当前模块的源文件中
+func (prog *goProgram) inModule(obj types.Object) bool {
+	if obj == nil || !obj.Pos().IsValid() {
+		return false
+	}
+	file := prog.fset.Position(obj.Pos()).Filename
+	return strings.HasPrefix(file, prog.module.Root+string(filepath.Separator))
+}
+
+// moduleTypes 模块内包级别声明的具名类型，以及这些包（含依赖）中的具名接口
+func (prog *goProgram) moduleTypes() (concrete []*types.TypeName, ifaces []*types.TypeName) {
+	seen := make(map[*types.Package]bool)
+	var visit func(pkg *types.Package, own bool)
+	visit = func(pkg *types.Package, own bool) {
+		if pkg == nil || seen[pkg] {
+			return
+		}
+		seen[pkg] = true
+		scope := pkg.Scope()
+		for _, name := range scope.Names() {
+			tn, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || tn.IsAlias() {
+				continue
+			}
+			if _, isIface := tn.Type().Underlying().(*types.Interface); isIface {
+				ifaces = append(ifaces, tn)
+			} else if own {
+				if named, ok := tn.Type().(*types.Named); ok && named.TypeParams().Len() == 0 {
+					concrete = append(concrete, tn)
+				}
+			}
+		}
+		for _, imp := range pkg.Imports() {
+			visit(imp, false)
+		}
+	}
+	for _, pkg := range prog.pkgs {
+		if pkg.Types != nil {
+			visit(pkg.Types, true)
+		}
+	}
+	return concrete, ifaces
+}
+
+// addRelatedMethods 方法改名时，同名的接口方法与实现这些接口的其他方法必须一起改名，否则类型不再实现接口。
+// 涉及模块外的接口或方法时无法一起改名，作为冲突返回。
+func (plan *renamePlan) addRelatedMethods(method *types.Func) []string {
+	prog := plan.prog
+	concrete, ifaces := prog.moduleTypes()
+	name := method.Name()
+
+	// implementer 返回具名类型T（或*T）上名为name的方法，T或*T实现iface时ok为true
+	implementer := func(tn *types.TypeName, iface *types.Interface) (*types.Func, bool) {
+		t := tn.Type()
+		if !types.Implements(t, iface) && !types.Implements(types.NewPointer(t), iface) {
+			return nil, false
+		}
+		m, _, _ := types.LookupFieldOrMethod(t, true, tn.Pkg(), name)
+		fn, ok := m.(*types.Func)
+		return fn, ok
+	}
+
+	var conflicts []string
+	handled := make(map[*types.TypeName]bool)
+	for changed := true; changed; {
+		changed = false
+		for _, itn := range ifaces {
+			if handled[itn] {
+				continue
+			}
+			iface := itn.Type().Underlying().(*types.Interface)
+			m, _, _ := types.LookupFieldOrMethod(itn.Type(), false, itn.Pkg(), name)
+			im, ok := m.(*types.Func)
+			if !ok {
+				continue
+			}
+
+			// 接口方法本身或任意一个实现已在改名集合中时，整个接口都相关
+			related := plan.targets[prog.objectKey(im)] != nil
+			var implementers []*types.Func
+			for _, tn := range concrete {
+				fn, ok := implementer(tn, iface)
+				if !ok {
+					continue
+				}
+				implementers = append(implementers, fn)
+				if plan.targets[prog.objectKey(fn)] != nil {
+					related = true
+				}
+			}
+			if !related {
+				continue
+			}
+			handled[itn] = true
+			changed = true
+
+			if !prog.inModule(im) {
+				conflicts = append(conflicts, fmt.Sprintf("%s.%s is declared outside the module (%s); types in the module implement it, so %s cannot be renamed without breaking them",
+					itn.Name(), name, itn.Pkg().Path(), name))
+				continue
+			}
+			plan.targets[prog.objectKey(im)] = im
+			for _, fn := range implementers {
+				if !prog.inModule(fn) {
+					conflicts = append(conflicts, fmt.Sprintf("%s implements %s.%s through a method declared outside the module", types.ObjectString(fn, nil), itn.Name(), name))
+					continue
+				}
+				plan.targets[prog.objectKey(fn)] = fn
+			}
+		}
+	}
+	return conflicts
+}
+
+// addEmbeddedFields 类型改名时，以它为嵌入字段的字段名也随之改变，对这些字段的选择（x.T）需要一起改名
+func (plan *renamePlan) addEmbeddedFields(tn *types.TypeName) {
+	prog := plan.prog
+	key := prog.objectKey(tn)
+	for _, pkg := range prog.pkgs {
+		if pkg.TypesInfo == nil {
+			continue
+		}
+		for ident, obj := range pkg.TypesInfo.Defs {
+			v, ok := obj.(*types.Var)
+			if !ok || !v.Embedded() {
+				continue
+			}
+			if used := pkg.TypesInfo.Uses[ident]; used != nil && prog.objectKey(used) == key {
+				plan.targets[prog.objectKey(v)] = v
+			}
+		}
+	}
+}
+
+// collectSites 收集所有包（含测试变体）中定义和引用目标的标识符，按位置去重
+func (plan *renamePlan) collectSites() {
+	prog := plan.prog
+	seen := make(map[string]bool)
+	add := func(pkg *packages.Package, ident *ast.Ident, obj types.Object) {
+		if obj == nil || ident.Name != plan.oldName || plan.targets[prog.objectKey(obj)] == nil {
+			return
+		}
+		pos := prog.fset.Position(ident.Pos())
+		if key := pos.String(); !seen[key] {
+			seen[key] = true
+			plan.sites = append(plan.sites, renameSite{pos: pos, pkg: pkg, ident: ident})
+		}
+	}
+	for _, pkg := range prog.pkgs {
+		if pkg.TypesInfo == nil {
+			continue
+		}
+		for ident, obj := range pkg.TypesInfo.Defs {
+			add(pkg, ident, obj)
+		}
+		for ident, obj := range pkg.TypesInfo.Uses {
+			add(pkg, ident, obj)
+		}
+	}
+	sort.Slice(plan.sites, func(i, j int) bool {
+		a, b := plan.sites[i].pos, plan.sites[j].pos
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Offset < b.Offset
+	})
+
+	// 文档注释惯例以名称开头（// Foo 做什么），同步修改
+	docSeen := make(map[string]bool)
+	for _, target := range plan.targets {
+		for _, pkg := range prog.pkgs {
+			if pos, ok := prog.docNamePos(goSymbol{obj: target, pkg: pkg}, plan.oldName); ok && !docSeen[pos.String()] {
+				docSeen[pos.String()] = true
+				plan.docs = append(plan.docs, pos)
+			}
+		}
+	}
+}
+
+// docNamePos 定义的文档注释以旧名称开头时返回该名称的位置
+func (prog *goProgram) docNamePos(sym goSymbol, name string) (token.Position, bool) {
+	file := sym.fileFor(sym.obj.Pos())
+	if file == nil {
+		return token.Position{}, false
+	}
+	var doc *ast.CommentGroup
+	path, _ := astutil.PathEnclosingInterval(file, sym.obj.Pos(), sym.obj.Pos())
+	for i, node := range path {
+		var parentDoc *ast.CommentGroup
+		if i+1 < len(path) {
+			if gen, ok := path[i+1].(*ast.GenDecl); ok && len(gen.Specs) == 1 {
+				parentDoc = gen.Doc
+			}
+		}
+		switch n := node.(type) {
+		case *ast.FuncDecl:
+			doc = n.Doc
+		case *ast.TypeSpec:
+			doc = firstDoc(n.Doc, parentDoc)
+		case *ast.ValueSpec:
+			doc = firstDoc(n.Doc, parentDoc)
+		case *ast.Field:
+			doc = n.Doc
+		default:
+			continue
+		}
+		break
+	}
+	if doc == nil || len(doc.List) == 0 {
+		return token.Position{}, false
+	}
+	text := doc.List[0].Text
+	prefix := "// " + name
+	if !strings.HasPrefix(text, prefix) {
+		return token.Position{}, false
+	}
+	if rest := text[len(prefix):]; rest != "" && (isIdentByte(rest[0])) {
+		return token.Position{}, false
+	}
+	return prog.fset.Position(doc.List[0].Pos() + 3), true
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c >= 0x80 || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+// conflicts 检查新名称是否与已有定义冲突、是否会被局部定义遮蔽，以及导出性变化是否破坏其他包的引用
+func (plan *renamePlan) conflicts() []string {
+	prog := plan.prog
+	var conflicts []string
+	declPkg := plan.obj.Pkg()
+	where := func(pos token.Pos) string {
+		p := prog.fset.Position(pos)
+		return fmt.Sprintf("%s:%d", normalizeRel(p.Filename), p.Line)
+	}
+
+	// 导出改为未导出：其他包中的引用将无法访问
+	if ast.IsExported(plan.oldName) && !ast.IsExported(plan.newName) {
+		for _, site := range plan.sites {
+			if site.pkg.PkgPath != declPkg.Path() {
+				conflicts = append(conflicts, fmt.Sprintf("%s is unexported but %s is referenced from package %s (%s)",
+					plan.newName, plan.oldName, site.pkg.PkgPath, where(site.ident.Pos())))
+				break
+			}
+		}
+	}
+
+	if plan.obj.Parent() == declPkg.Scope() {
+		conflicts = append(conflicts, plan.packageLevelConflicts(where)...)
+	} else {
+		conflicts = append(conflicts, plan.memberConflicts(where)...)
+	}
+	return conflicts
+}
+
+// packageLevelConflicts 包级别定义：包内（含测试文件）不能已有同名定义或导入，引用处不能被局部定义遮蔽
+func (plan *renamePlan) packageLevelConflicts(where func(token.Pos) string) []string {
+	prog := plan.prog
+	var conflicts []string
+	seen := make(map[string]bool)
+	report := func(msg string) {
+		if !seen[msg] {
+			seen[msg] = true
+			conflicts = append(conflicts, msg)
+		}
+	}
+	declPath := plan.obj.Pkg().Path()
+	for _, pkg := range prog.pkgs {
+		if pkg.Types == nil || pkg.TypesInfo == nil || pkg.PkgPath != declPath {
+			continue
+		}
+		scope := pkg.Types.Scope()
+		if existing := scope.Lookup(plan.newName); existing != nil {
+			report(fmt.Sprintf("package %s already declares %s %s at %s", pkg.Name, goObjectKind(existing), plan.newName, where(existing.Pos())))
+		}
+		for _, file := range pkg.Syntax {
+			if fileScope := pkg.TypesInfo.Scopes[file]; fileScope != nil {
+				if imp := fileScope.Lookup(plan.newName); imp != nil {
+					report(fmt.Sprintf("%s imports a package named %s", normalizeRel(prog.fset.Position(file.Pos()).Filename), plan.newName))
+				}
+			}
+		}
+		// 包内对同名内置标识符（如len、error）的使用会被新定义遮蔽
+		for ident, used := range pkg.TypesInfo.Uses {
+			if used.Name() == plan.newName && used.Parent() == types.Universe {
+				report(fmt.Sprintf("package %s uses the predeclared %s at %s, which would be shadowed", pkg.Name, plan.newName, where(ident.Pos())))
+				break
+			}
+		}
+	}
+
+	// 包内的引用处如果已有同名的局部定义，改名后会指向局部定义
+	for _, site := range plan.sites {
+		if site.pkg.PkgPath != declPath || site.pkg.Types == nil {
+			continue
+		}
+		inner := site.pkg.Types.Scope().Innermost(site.ident.Pos())
+		if inner == nil {
+			continue
+		}
+		if _, shadow := inner.LookupParent(plan.newName, site.ident.Pos()); shadow != nil &&
+			shadow.Parent() != site.pkg.Types.Scope() && shadow.Parent() != types.Universe && plan.targets[prog.objectKey(shadow)] == nil {
+			report(fmt.Sprintf("the reference at %s would be shadowed by %s %s declared at %s",
+				where(site.ident.Pos()), goObjectKind(shadow), plan.newName, where(shadow.Pos())))
+		}
+	}
+	return conflicts
+}
+
+// memberConflicts 方法和字段：所属类型不能已有同名的字段或方法，已有的选择表达式改名后不能指向其他成员
+func (plan *renamePlan) memberConflicts(where func(token.Pos) string) []string {
+	prog := plan.prog
+	var conflicts []string
+	seen := make(map[string]bool)
+	report := func(msg string) {
+		if !seen[msg] {
+			seen[msg] = true
+			conflicts = append(conflicts, msg)
+		}
+	}
+	check := func(t types.Type, pkg *types.Package, context string) {
+		if existing, _, _ := types.LookupFieldOrMethod(t, true, pkg, plan.newName); existing != nil && plan.targets[prog.objectKey(existing)] == nil {
+			report(fmt.Sprintf("%s already has %s %s at %s", context, goObjectKind(existing), plan.newName, where(existing.Pos())))
+		}
+	}
+
+	for _, target := range plan.targets {
+		fn, ok := target.(*types.Func)
+		if !ok {
+			continue
+		}
+		if sig, ok := fn.Type().(*types.Signature); ok && sig.Recv() != nil {
+			recv := sig.Recv().Type()
+			if ptr, ok := recv.(*types.Pointer); ok {
+				recv = ptr.Elem()
+			}
+			check(recv, fn.Pkg(), types.TypeString(recv, types.RelativeTo(fn.Pkg())))
+		}
+	}
+
+	if field, ok := plan.obj.(*types.Var); ok && field.IsField() {
+		key := prog.objectKey(field)
+		containsField := func(st *types.Struct) bool {
+			for i := 0; i < st.NumFields(); i++ {
+				if prog.objectKey(st.Field(i)) == key {
+					return true
+				}
+			}
+			return false
+		}
+		for _, pkg := range prog.pkgs {
+			if pkg.TypesInfo == nil {
+				continue
+			}
+			for _, obj := range pkg.TypesInfo.Defs {
+				tn, ok := obj.(*types.TypeName)
+				if !ok || tn.IsAlias() {
+					continue
+				}
+				if st, ok := tn.Type().Underlying().(*types.Struct); ok && containsField(st) {
+					check(tn.Type(), field.Pkg(), tn.Name())
+				}
+			}
+		}
+		// 匿名结构体中的字段：只检查同一结构体的其他字段
+		if len(conflicts) == 0 {
+			for _, pkg := range prog.pkgs {
+				if pkg.TypesInfo == nil {
+					continue
+				}
+				for _, tv := range pkg.TypesInfo.Types {
+					if st, ok := tv.Type.(*types.Struct); ok && containsField(st) {
+						for i := 0; i < st.NumFields(); i++ {
+							if f := st.Field(i); f.Name() == plan.newName {
+								report(fmt.Sprintf("the struct already has a field %s at %s", plan.newName, where(f.Pos())))
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	if len(conflicts) > 0 {
+		return conflicts
+	}
+
+	// x.Old 改为 x.New 后必须仍然选择到改名后的成员
+	for _, pkg := range prog.pkgs {
+		if pkg.TypesInfo == nil {
+			continue
+		}
+		for expr, sel := range pkg.TypesInfo.Selections {
+			if plan.targets[prog.objectKey(sel.Obj())] == nil {
+				continue
+			}
+			if existing, _, _ := types.LookupFieldOrMethod(sel.Recv(), true, sel.Obj().Pkg(), plan.newName); existing != nil && plan.targets[prog.objectKey(existing)] == nil {
+				report(fmt.Sprintf("at %s, .%s would select %s %s declared at %s instead",
+					where(expr.Sel.Pos()), plan.newName, goObjectKind(existing), plan.newName, where(existing.Pos())))
+			}
+		}
+	}
+	return conflicts
+}
+
+// patchedFiles 按文件替换所有位置的旧名称
+func (plan *renamePlan) patchedFiles() ([]*PatchedFile, error) {
+	byFile := make(map[string][]token.Position)
+	for _, site := range plan.sites {
+		byFile[site.pos.Filename] = append(byFile[site.pos.Filename], site.pos)
+	}
+	for _, pos := range plan.docs {
+		byFile[pos.Filename] = append(byFile[pos.Filename], pos)
+	}
+
+	names := make([]string, 0, len(byFile))
+	for name := range byFile {
+		if !strings.HasPrefix(name, plan.prog.module.Root+string(filepath.Separator)) {
+			return nil, fmt.Errorf("Error: %s references %s but is outside the module; refusing to rename", name, plan.oldName)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var files []*PatchedFile
+	for _, name := range names {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading %s: %v", name, err)
+		}
+		info, err := os.Stat(name)
+		if err != nil {
+			return nil, fmt.Errorf("Error: %v", err)
+		}
+		content, format := DecodeText(data)
+		// 换行符已统一为LF，按行号和列号定位
+		lineStarts := []int{0}
+		for i := 0; i < len(content); i++ {
+			if content[i] == '\n' {
+				lineStarts = append(lineStarts, i+1)
+			}
+		}
+		positions := byFile[name]
+		sort.Slice(positions, func(i, j int) bool { return positions[i].Offset > positions[j].Offset })
+		newContent := content
+		for _, pos := range positions {
+			offset := -1
+			if pos.Line-1 < len(lineStarts) {
+				offset = lineStarts[pos.Line-1] + pos.Column - 1
+				if pos.Line == 1 && format.BOM {
+					offset -= len(utf8BOM)
+				}
+			}
+			if offset < 0 || offset+len(plan.oldName) > len(newContent) || newContent[offset:offset+len(plan.oldName)] != plan.oldName {
+				return nil, fmt.Errorf("Error: %s changed since it was analyzed (expected %s at line %d); retry the rename", normalizeRel(name), plan.oldName, pos.Line)
+			}
+			newContent = newContent[:offset] + plan.newName + newContent[offset+len(plan.oldName):]
+		}
+		files = append(files, &PatchedFile{
+			Path:       normalizeRel(name),
+			OldPath:    normalizeRel(name),
+			Operation:  "modify",
+			OldContent: content,
+			NewContent: newContent,
+			Mode:       info.Mode(),
+			Format:     format,
+			Notes:      []string{fmt.Sprintf("%d occurrence(s)", len(positions))},
+			oldData:    data,
+		})
+	}
+	return files, nil
+}
+
+// verifyRename 用改名后的内容覆盖源文件重新类型检查，返回改名前不存在的错误
+func (prog *goProgram) verifyRename(files []*PatchedFile) []string {
+	overlay := make(map[string][]byte)
+	for _, pf := range files {
+		abs, err := filepath.Abs(pf.Path)
+		if err != nil {
+			continue
+		}
+		data, err := EncodeText(pf.NewContent, pf.Format)
+		if err != nil {
+			continue
+		}
+		overlay[abs] = data
+	}
+	cfg := &packages.Config{
+		Mode:    goLoadMode,
+		Dir:     prog.module.Root,
+		Fset:    token.NewFileSet(),
+		Tests:   true,
+		Overlay: overlay,
+	}
+	pkgs, err := packages.Load(cfg, "./...")
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	// 按 文件:行 比较，改名前已有的错误不算
+	errorLines := func(pkgs []*packages.Package) map[string]string {
+		lines := make(map[string]string)
+		for _, pkg := range pkgs {
+			for _, e := range pkg.Errors {
+				pos := e.Pos
+				if i := strings.LastIndex(pos, ":"); i > 0 && strings.Count(pos, ":") >= 2 {
+					pos = pos[:i]
+				}
+				lines[pos] = e.Msg
+			}
+		}
+		return lines
+	}
+	before := errorLines(prog.pkgs)
+	var introduced []string
+	for pos, msg := range errorLines(pkgs) {
+		if _, ok := before[pos]; !ok {
+			introduced = append(introduced, fmt.Sprintf("%s: %s", normalizeRel(pos), msg))
+		}
+	}
+	sort.Strings(introduced)
+	if len(introduced) > renameMaxConflicts {
+		introduced = append(introduced[:renameMaxConflicts], fmt.Sprintf("... and %d more", len(introduced)-renameMaxConflicts))
+	}
+	return introduced
+}
+
+// summary 生成确认和结果中使用的摘要
+func (plan *renamePlan) summary(files []*PatchedFile) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%s %s -> %s: %d occurrence(s) in %d file(s)", goObjectKind(plan.obj), plan.oldName, plan.newName, len(plan.sites)+len(plan.docs), len(files)))
+	var related []string
+	for key, target := range plan.targets {
+		if key == plan.prog.objectKey(plan.obj) {
+			continue
+		}
+		pos := plan.prog.fset.Position(target.Pos())
+		related = append(related, fmt.Sprintf("%s (%s:%d)", types.ObjectString(target, types.RelativeTo(target.Pkg())), normalizeRel(pos.Filename), pos.Line))
+	}
+	sort.Strings(related)
+	if len(related) > 0 {
+		sb.WriteString("\nAlso renamed to keep the program consistent:\n- " + strings.Join(related, "\n- "))
+	}
+	return sb.String()
+}
+
+// FormatRenameResult 生成返回给模型的结果
+func FormatRenameResult(files []*PatchedFile, summary string) string {
+	var sb strings.Builder
+	sb.WriteString("Renamed " + summary + "\n")
+	for _, pf := range files {
+		sb.WriteString(fmt.Sprintf("- modified %s (%s)\n", pf.Path, strings.Join(pf.Notes, "; ")))
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// RenameSymbol 重命名Go标识符（无UI确认，供Task子代理使用）
+func RenameSymbol(symbol string, new_name string, path string) string {
+	logger, done := symbolLogger("RenameSymbol", symbol, new_name)
+	defer done()
+
+	files, summary, err := PrepareRename(symbol, new_name, path)
+	if err != nil {
+		if logger != nil {
+			logger.Printf("RenameSymbol函数返回 - 失败: %v", err)
+		}
+		return err.Error()
+	}
+	if err := CommitPatch(files); err != nil {
+		if logger != nil {
+			logger.Printf("RenameSymbol函数返回 - 写入失败: %v", err)
+		}
+		return err.Error()
+	}
+	if logger != nil {
+		logger.Printf("RenameSymbol函数返回 - 成功: %s", summary)
+	}
+	return FormatRenameResult(files, summary) + RunPostEditHooks(PatchedPaths(files)...)
+}
@@ -0,0 +1,111 @@
+package function
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestPrepareRename(t *testing.T) {
+	module := filepath.Join("testdata", "renamemod")
+	tests := []struct {
+		name    string
+		symbol  string
+		newName string
+		wantErr string              // 非空时应拒绝改名，错误中包含该内容
+		want    map[string][]string // 模块内相对路径 → 改名后应包含的内容
+	}{
+		{
+			name:   "function used from another package and a test",
+			symbol: "NewRect", newName: "MakeRect",
+			want: map[string][]string{
+				"geo/geo.go":      {"// MakeRect 创建矩形", "func MakeRect(w, h float64)"},
+				"geo/geo_test.go": {"r, err := MakeRect(2, 3)"},
+				"main.go":         {"geo.MakeRect(3, 4)"},
+			},
+		},
+		{
+			// 接口方法与调用处一起改名
+			name:   "method implementing a module interface",
+			symbol: "Rect.Area", newName: "Size",
+			want: map[string][]string{
+				"geo/geo.go":      {"\tSize() float64", "// Size 返回矩形的面积", "func (r *Rect) Size() float64", "shapes[i].Size()"},
+				"geo/geo_test.go": {"got != r.Size()"},
+			},
+		},
+		{
+			name:   "clash with an existing field",
+			symbol: "Rect.Area", newName: "W",
+			wantErr: "Rect already has field W",
+		},
+		{
+			name:   "clash with an existing method",
+			symbol: "(*Rect).Area", newName: "Perimeter",
+			wantErr: "Rect already has method Perimeter",
+		},
+		{
+			name:   "unexporting a name used from another package",
+			symbol: "geo.NewRect", newName: "newRect",
+			wantErr: "newRect is unexported but NewRect is referenced from package example.com/renamemod",
+		},
+		{
+			name:   "shadowing a predeclared identifier",
+			symbol: "Total", newName: "len",
+			wantErr: "package geo uses the predeclared len",
+		},
+		{
+			name:   "method implementing an interface outside the module",
+			symbol: "Rect.String", newName: "Name",
+			wantErr: "Stringer.String is declared outside the module (fmt)",
+		},
+		{
+			name:   "already named",
+			symbol: "Total", newName: "Total",
+			wantErr: "Total is already named Total",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, _, err := PrepareRename(tt.symbol, tt.newName, module)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("PrepareRename(%s, %s) error = %v, want it to contain %q", tt.symbol, tt.newName, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make(map[string]string)
+			for _, pf := range files {
+				rel := strings.TrimPrefix(pf.Path, filepath.ToSlash(module)+"/")
+				got[rel] = pf.NewContent
+				if strings.Contains(pf.NewContent, tt.symbol[strings.LastIndex(tt.symbol, ".")+1:]+"(") {
+					t.Errorf("%s still calls the old name:\n%s", rel, pf.NewContent)
+				}
+			}
+			var gotFiles, wantFiles []string
+			for rel := range got {
+				gotFiles = append(gotFiles, rel)
+			}
+			for rel := range tt.want {
+				wantFiles = append(wantFiles, rel)
+			}
+			sort.Strings(gotFiles)
+			sort.Strings(wantFiles)
+			if !reflect.DeepEqual(gotFiles, wantFiles) {
+				t.Fatalf("modified files = %q, want %q", gotFiles, wantFiles)
+			}
+			for rel, parts := range tt.want {
+				for _, part := range parts {
+					if !strings.Contains(got[rel], part) {
+						t.Errorf("%s after rename:\n%s\nwant it to contain %q", rel, got[rel], part)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package function
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"lukatincode/walker"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ripgrep作为Grep/Glob的后端：可用时直接调用 rg（--json / --files），
+// 结果转换为与Go实现相同的结构，失败时由调用方回退到Go实现。
+
+const ripgrepTimeout = 60 * time.Second
+
+var (
+	ripgrepOnce sync.Once
+	ripgrepBin  string
+)
+
+// ripgrepBinary 返回rg可执行文件路径；未安装或设置了环境变量 LUKATIN_NO_RIPGREP 时返回空字符串
+func ripgrepBinary() string {
+	ripgrepOnce.Do(func() {
+		if os.Getenv("LUKATIN_NO_RIPGREP") != "" {
+			return
+		}
+		if path, err := exec.LookPath("rg"); err == nil {
+			ripgrepBin = path
+		}
+	})
+	return ripgrepBin
+}
+
+// rgEvent rg --json 输出的一行事件（只解析用到的字段）
+type rgEvent struct {
+	Type string `json:"type"`
+	Data struct {
+		Path struct {
+			Text string `json:"text"`
+		} `json:"path"`
+		Lines struct {
+			Text string `json:"text"`
+		} `json:"lines"`
+		LineNumber int               `json:"line_number"`
+		Submatches []json.RawMessage `json:"submatches"`
+	} `json:"data"`
+}
+
+// ripgrepWalkArgs 让rg的遍历规则与walker包一致：
+// 不在git仓库中也读取.gitignore，跳过.git和node_modules，项目的.lukatinignore作为额外的忽略文件。
+// rg不支持自定义的逐目录忽略文件名，子目录中的.lukatinignore只由Go实现处理。
+func ripgrepWalkArgs(includeHidden bool) []string {
+	args := []string{"--no-messages", "--no-require-git", "--glob", "!.git/", "--glob", "!node_modules/"}
+	if includeHidden {
+		args = append(args, "--hidden")
+	}
+	if info, err := os.Stat(walker.LukatinIgnoreFile); err == nil && !info.IsDir() {
+		args = append(args, "--ignore-file", walker.LukatinIgnoreFile)
+	}
+	return args
+}
+
+// ripgrepGrep 用 rg --json 搜索；ok为false表示rg不可用或执行失败
+// include 过滤由 includeMatch 对结果统一执行，保证与Go实现语义一致；
+// 模式都不含 / 时同时作为 --glob 传给rg以减少搜索的文件。
+func ripgrepGrep(pattern, start string, includePatterns []string, globs *walker.GlobSet, opts GrepOptions) ([]*grepFileResult, bool) {
+	bin := ripgrepBinary()
+	if bin == "" {
+		return nil, false
+	}
+
+	args := append([]string{"--json"}, ripgrepWalkArgs(opts.IncludeHidden)...)
+	if opts.CaseInsensitive {
+		args = append(args, "-i")
+	}
+	if opts.Multiline {
+		args = append(args, "-U", "--multiline-dotall")
+	}
+	if opts.OutputMode == GrepContent {
+		if opts.Before > 0 {
+			args = append(args, "-B", strconv.Itoa(opts.Before))
+		}
+		if opts.After > 0 {
+			args = append(args, "-A", strconv.Itoa(opts.After))
+		}
+	}
+	if !strings.Contains(strings.Join(includePatterns, " "), "/") {
+		for _, p := range includePatterns {
+			for _, expanded := range walker.ExpandBraces(p) {
+				args = append(args, "--glob", expanded)
+			}
+		}
+	}
+	args = append(args, "-e", pattern, "--", start)
+
+	ctx, cancel := context.WithTimeout(context.Background(), ripgrepTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, bin, args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, false
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, false
+	}
+
+	byPath := make(map[string]*grepFileResult)
+	var results []*grepFileResult
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var ev rgEvent
+		if json.Unmarshal(scanner.Bytes(), &ev) != nil {
+			continue
+		}
+		if ev.Type != "match" && ev.Type != "context" {
+			continue
+		}
+		path := ev.Data.Path.Text
+		res, ok := byPath[path]
+		if !ok {
+			if !includeMatch(globs, start, path) {
+				continue
+			}
+			res = &grepFileResult{path: path, text: make(map[int]string), matched: make(map[int]bool)}
+			byPath[path] = res
+			results = append(results, res)
+		}
+		// 多行匹配时lines包含多行，逐行拆开
+		text := strings.TrimSuffix(strings.ReplaceAll(ev.Data.Lines.Text, "\r\n", "\n"), "\n")
+		for i, line := range strings.Split(text, "\n") {
+			n := ev.Data.LineNumber + i
+			if ev.Type == "match" {
+				res.matched[n] = true
+			}
+			if opts.OutputMode == GrepContent {
+				res.text[n] = line
+			}
+		}
+		if ev.Type == "match" {
+			res.count += len(ev.Data.Submatches)
+		}
+	}
+
+	// rg退出码：0有匹配，1无匹配，2出错（可能仍有部分结果）
+	if err := cmd.Wait(); err != nil {
+		exitErr, isExit := err.(*exec.ExitError)
+		if !isExit || (exitErr.ExitCode() != 1 && len(results) == 0) || ctx.Err() != nil {
+			return nil, false
+		}
+	}
+
+	for _, res := range results {
+		if info, err := os.Stat(res.path); err == nil {
+			res.modTime = info.ModTime().UnixNano()
+		}
+	}
+	return results, true
+}
+
+// ripgrepFiles 用 rg --files 列出start下未被忽略的文件（相对start的斜杠路径）；ok为false表示需要回退
+func ripgrepFiles(start string, includeHidden bool) ([]string, bool) {
+	bin := ripgrepBinary()
+	if bin == "" {
+		return nil, false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ripgrepTimeout)
+	defer cancel()
+	args := append([]string{"--files"}, ripgrepWalkArgs(includeHidden)...)
+	args = append(args, "--", start)
+	cmd := exec.CommandContext(ctx, bin, args...)
+	out, err := cmd.Output()
+	if err != nil {
+		exitErr, isExit := err.(*exec.ExitError)
+		if !isExit || exitErr.ExitCode() != 1 || ctx.Err() != nil {
+			return nil, false
+		}
+	}
+
+	var files []string
+	for _, line := range strings.Split(string(out), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			continue
+		}
+		rel, err := filepath.Rel(start, line)
+		if err != nil {
+			rel = line
+		}
+		files = append(files, filepath.ToSlash(rel))
+	}
+	return files, true
+}
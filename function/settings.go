@@ -0,0 +1,73 @@
+package function
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ProjectSettingsFile 项目级设置文件，相对于项目根目录（启动时的工作目录）
+const ProjectSettingsFile = ".lukatin/settings.json"
+
+// PostEditHook 某种语言文件被修改后自动执行的命令
+type PostEditHook struct {
+	Extensions []string `json:"extensions"` // 匹配的扩展名，如 [".go"]
+	Commands   []string `json:"commands"`   // 支持 {file} {dir} {pkg} 占位符
+	Timeout    int      `json:"timeout"`    // 单条命令超时（秒），默认30
+}
+
+// ProjectSettings .lukatin/settings.json 的内容
+type ProjectSettings struct {
+	PostEditHooks      map[string]PostEditHook `json:"postEditHooks"`      // key为语言名
+	AgentModels        map[string]string       `json:"agentModels"`        // 按子代理类型覆盖模型，值为 "model" 或 "provider:model"
+	MaxConcurrentTasks int                     `json:"maxConcurrentTasks"` // 同时运行的Task子代理数，默认3
+}
+
+var (
+	settingsMu      sync.Mutex
+	cachedSettings  *ProjectSettings
+	settingsModTime time.Time
+)
+
+// LoadProjectSettings 读取项目设置，文件未修改时使用缓存；文件不存在时返回空设置
+func LoadProjectSettings() (*ProjectSettings, error) {
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+
+	info, err := os.Stat(ProjectSettingsFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			cachedSettings = nil
+			return &ProjectSettings{}, nil
+		}
+		return &ProjectSettings{}, err
+	}
+	if cachedSettings != nil && info.ModTime().Equal(settingsModTime) {
+		return cachedSettings, nil
+	}
+
+	data, err := os.ReadFile(ProjectSettingsFile)
+	if err != nil {
+		return &ProjectSettings{}, err
+	}
+	var settings ProjectSettings
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return &ProjectSettings{}, fmt.Errorf("failed to parse %s: %v", ProjectSettingsFile, err)
+	}
+
+	cachedSettings = &settings
+	settingsModTime = info.ModTime()
+	return cachedSettings, nil
+}
+
+// ProjectRoot 项目根目录，即设置文件所在的工作目录
+func ProjectRoot() string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "."
+	}
+	return filepath.Clean(wd)
+}
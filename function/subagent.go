@@ -0,0 +1,141 @@
+package function
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ccIisIaIcat/GoAgent/agent/ConversationManager"
+	"github.com/ccIisIaIcat/GoAgent/agent/general"
+)
+
+// 子代理（Task、WebFetch）的模型配置：主程序注入当前会话的提供商、模型和API设置，
+// 以及当前这一轮对话的context；.lukatin/settings.json 的 agentModels 可按代理类型覆盖模型。
+
+// 内置的代理类型，用作 agentModels 的key
+const (
+	AgentTypeTask     = "task"
+	AgentTypeWebFetch = "webfetch"
+)
+
+// SubAgentConfig 子代理继承的配置
+type SubAgentConfig struct {
+	Providers []*general.ProviderConfig // 已配置的提供商及其API设置
+	Provider  general.Provider          // 主会话使用的提供商
+	Model     string                    // 主会话使用的模型
+}
+
+var (
+	subAgentMu     sync.RWMutex
+	subAgentConfig *SubAgentConfig
+	subAgentCtx    = context.Background()
+)
+
+// SetSubAgentConfig 设置子代理继承的提供商、模型和API设置
+func SetSubAgentConfig(cfg SubAgentConfig) {
+	subAgentMu.Lock()
+	defer subAgentMu.Unlock()
+	subAgentConfig = &cfg
+}
+
+// SetSubAgentContext 设置当前这一轮对话的context，取消时正在运行的子代理随之取消
+func SetSubAgentContext(ctx context.Context) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	subAgentMu.Lock()
+	defer subAgentMu.Unlock()
+	subAgentCtx = ctx
+}
+
+// subAgentContext 子代理请求使用的context
+func subAgentContext() context.Context {
+	subAgentMu.RLock()
+	defer subAgentMu.RUnlock()
+	return subAgentCtx
+}
+
+// currentSubAgentConfig 返回注入的配置；未注入时（如单独调用工具）从 LLMConfig.yaml 读取，使用第一个已配置的提供商
+func currentSubAgentConfig() (*SubAgentConfig, error) {
+	subAgentMu.RLock()
+	cfg := subAgentConfig
+	subAgentMu.RUnlock()
+	if cfg != nil {
+		return cfg, nil
+	}
+
+	config, err := general.LoadConfig("./LLMConfig.yaml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %v", err)
+	}
+	providers := config.ToProviderConfigs()
+	if len(providers) == 0 {
+		return nil, fmt.Errorf("no provider configured in LLMConfig.yaml")
+	}
+	return &SubAgentConfig{Providers: providers, Provider: providers[0].Provider, Model: providers[0].Model}, nil
+}
+
+// resolveAgentModel 返回agentType使用的提供商和模型。model为代理定义中指定的模型，为空时使用agentModels中的设置，
+// Task的代理类型（通用代理和自定义代理）未单独设置时使用 agentModels.task；
+// "model" 只换模型，"provider:model" 同时换提供商；都未指定时与主会话相同
+func resolveAgentModel(cfg *SubAgentConfig, agentType string, model string) (general.Provider, string, error) {
+	override := strings.TrimSpace(model)
+	if override == "" {
+		settings, err := LoadProjectSettings()
+		if err != nil {
+			return "", "", err
+		}
+		keys := []string{agentType}
+		if agentType != AgentTypeTask && agentType != AgentTypeWebFetch {
+			keys = append(keys, AgentTypeTask)
+		}
+		for _, key := range keys {
+			if override = strings.TrimSpace(settings.AgentModels[key]); override != "" {
+				break
+			}
+		}
+	}
+	if override == "" {
+		return cfg.Provider, cfg.Model, nil
+	}
+
+	// 冒号前是已配置的提供商时才当作 provider:model，否则整体是模型名（如 llama3:8b）
+	if i := strings.Index(override, ":"); i >= 0 {
+		prefix := general.Provider(strings.ToLower(override[:i]))
+		for _, p := range cfg.Providers {
+			if p.Provider != prefix {
+				continue
+			}
+			if model = override[i+1:]; model == "" {
+				model = p.Model
+			}
+			return prefix, model, nil
+		}
+	}
+	return cfg.Provider, override, nil
+}
+
+// newSubAgent 创建agentType类型的子代理会话，model为空时按agentModels选择模型；返回会话、使用的提供商和模型
+func newSubAgent(agentType string, model string, systemPrompt string) (*ConversationManager.ConversationManager, general.Provider, string, error) {
+	cfg, err := currentSubAgentConfig()
+	if err != nil {
+		return nil, "", "", err
+	}
+	provider, model, err := resolveAgentModel(cfg, agentType, model)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	agentManager := general.NewAgentManager()
+	for _, p := range cfg.Providers {
+		if p.Provider == provider {
+			if err := agentManager.AddProvider(p); err != nil {
+				return nil, "", "", fmt.Errorf("failed to add provider %s: %v", provider, err)
+			}
+		}
+	}
+	cm := ConversationManager.NewConversationManager(agentManager)
+	cm.SetSystemPrompt(systemPrompt)
+	return cm, provider, model, nil
+}
@@ -0,0 +1,635 @@
+package function
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"go/types"
+	"io/fs"
+	"log"
+	"lukatincode/walker"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// Go符号导航：用 go/packages 加载整个模块（含测试文件）并通过 go/types 精确解析定义与引用；
+// 非Go项目或加载失败时回退到正则大纲与单词匹配搜索。
+
+const (
+	symbolMaxDefinitions = 50
+	symbolMaxReferences  = 200
+	symbolMaxSigLines    = 15   // 类型定义的签名最多显示的行数
+	outlineMaxFiles      = 5000 // 回退搜索最多扫描的文件数
+	outlineMaxFileSize   = 1 << 20
+)
+
+// goLoadMode 加载模块时需要的信息；本机Go版本较新时导出数据可能无法读取，依赖也从源码类型检查
+const goLoadMode = packages.NeedName | packages.NeedFiles | packages.NeedCompiledGoFiles | packages.NeedImports | packages.NeedDeps |
+	packages.NeedTypes | packages.NeedTypesInfo | packages.NeedSyntax
+
+// SymbolParams FindDefinition/FindReferences函数签名中的参数顺序
+var SymbolParams = []string{"symbol", "path"}
+
+// goProgram 一次加载的结果，源文件未变化时复用
+type goProgram struct {
+	module *goModule
+	fset   *token.FileSet
+	pkgs   []*packages.Package
+	stamp  string
+}
+
+var (
+	goProgramMu    sync.Mutex
+	goProgramCache = make(map[string]*goProgram)
+)
+
+// loadGoProgram 加载path所在模块的所有包（含测试）
+func loadGoProgram(path string) (*goProgram, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(abs); err == nil && !info.IsDir() {
+		abs = filepath.Dir(abs)
+	}
+	mod, ok := findGoModule(abs)
+	if !ok {
+		return nil, fmt.Errorf("%s is not inside a Go module", path)
+	}
+
+	goProgramMu.Lock()
+	defer goProgramMu.Unlock()
+	stamp := goSourceStamp(mod.Root)
+	if prog, ok := goProgramCache[mod.Root]; ok && prog.stamp == stamp {
+		return prog, nil
+	}
+
+	fset := token.NewFileSet()
+	cfg := &packages.Config{
+		Mode:  goLoadMode,
+		Dir:   mod.Root,
+		Fset:  fset,
+		Tests: true,
+	}
+	pkgs, err := packages.Load(cfg, "./...")
+	if err != nil {
+		return nil, err
+	}
+	prog := &goProgram{module: mod, fset: fset, pkgs: pkgs, stamp: stamp}
+	goProgramCache[mod.Root] = prog
+	return prog, nil
+}
+
+// goSourceStamp 模块内Go源文件的数量、总大小和最新修改时间，用于判断缓存是否失效
+func goSourceStamp(root string) string {
+	var count int
+	var size, latest int64
+	_ = walker.Walk(root, walker.Options{}, func(p string, d fs.DirEntry) error {
+		if d.IsDir() || !strings.HasSuffix(p, ".go") {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			count++
+			size += info.Size()
+			if t := info.ModTime().UnixNano(); t > latest {
+				latest = t
+			}
+		}
+		return nil
+	})
+	return fmt.Sprintf("%d/%d/%d", count, size, latest)
+}
+
+// goSymbol 解析到的一个定义
+type goSymbol struct {
+	obj types.Object
+	pkg *packages.Package
+}
+
+// findGoSymbols 按名称查找定义，支持 Name、Type.Member、pkg.Name、pkg.Type.Member 以及 (*T).M
+func (prog *goProgram) findGoSymbols(symbol string) []goSymbol {
+	symbol = strings.NewReplacer("(", "", ")", "", "*", "").Replace(strings.TrimSpace(symbol))
+	parts := strings.Split(symbol, ".")
+	name := parts[len(parts)-1]
+
+	seen := make(map[string]bool)
+	var found []goSymbol
+	add := func(obj types.Object, pkg *packages.Package) {
+		if obj == nil || !obj.Pos().IsValid() {
+			return
+		}
+		key := prog.fset.Position(obj.Pos()).String()
+		if !seen[key] {
+			seen[key] = true
+			found = append(found, goSymbol{obj: obj, pkg: pkg})
+		}
+	}
+	member := func(pkg *packages.Package, typeName, memberName string) {
+		if tn, ok := pkg.Types.Scope().Lookup(typeName).(*types.TypeName); ok {
+			obj, _, _ := types.LookupFieldOrMethod(tn.Type(), true, pkg.Types, memberName)
+			add(obj, pkg)
+		}
+	}
+
+	for _, pkg := range prog.pkgs {
+		if pkg.Types == nil || pkg.TypesInfo == nil {
+			continue
+		}
+		scope := pkg.Types.Scope()
+		switch len(parts) {
+		case 1:
+			add(scope.Lookup(name), pkg)
+			// 同名的方法与接口方法
+			for _, n := range scope.Names() {
+				tn, ok := scope.Lookup(n).(*types.TypeName)
+				if !ok {
+					continue
+				}
+				named, ok := tn.Type().(*types.Named)
+				if !ok {
+					continue
+				}
+				for i := 0; i < named.NumMethods(); i++ {
+					if m := named.Method(i); m.Name() == name {
+						add(m, pkg)
+					}
+				}
+				if iface, ok := named.Underlying().(*types.Interface); ok {
+					for i := 0; i < iface.NumExplicitMethods(); i++ {
+						if m := iface.ExplicitMethod(i); m.Name() == name {
+							add(m, pkg)
+						}
+					}
+				}
+			}
+		case 2:
+			if pkg.Name == parts[0] {
+				add(scope.Lookup(name), pkg)
+			}
+			member(pkg, parts[0], name)
+		default:
+			if pkg.Name == parts[len(parts)-3] {
+				member(pkg, parts[len(parts)-2], name)
+			}
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		pi, pj := prog.fset.Position(found[i].obj.Pos()), prog.fset.Position(found[j].obj.Pos())
+		if pi.Filename != pj.Filename {
+			return pi.Filename < pj.Filename
+		}
+		return pi.Offset < pj.Offset
+	})
+	return found
+}
+
+// objectKey 跨测试变体稳定的对象标识（同一文件会在包与测试包中各解析一次）
+func (prog *goProgram) objectKey(obj types.Object) string {
+	switch o := obj.(type) {
+	case *types.Func:
+		obj = o.Origin()
+	case *types.Var:
+		obj = o.Origin()
+	}
+	return prog.fset.Position(obj.Pos()).String()
+}
+
+// fileFor 找到包含pos的语法树
+func (sym goSymbol) fileFor(pos token.Pos) *ast.File {
+	for _, f := range sym.pkg.Syntax {
+		if f.FileStart <= pos && pos <= f.FileEnd {
+			return f
+		}
+	}
+	return nil
+}
+
+// describe 返回定义的种类、源码签名与文档注释
+func (prog *goProgram) describe(sym goSymbol) (kind, signature, doc string) {
+	kind = goObjectKind(sym.obj)
+	signature = types.ObjectString(sym.obj, types.RelativeTo(sym.pkg.Types))
+
+	file := sym.fileFor(sym.obj.Pos())
+	if file == nil {
+		return kind, signature, ""
+	}
+	path, _ := astutil.PathEnclosingInterval(file, sym.obj.Pos(), sym.obj.Pos())
+	for i, node := range path {
+		var parentDoc *ast.CommentGroup
+		if i+1 < len(path) {
+			if gen, ok := path[i+1].(*ast.GenDecl); ok {
+				parentDoc = gen.Doc
+			}
+		}
+		switch n := node.(type) {
+		case *ast.FuncDecl:
+			header := *n
+			header.Body = nil
+			header.Doc = nil
+			return kind, prog.printNode(&header), n.Doc.Text()
+		case *ast.TypeSpec:
+			return kind, "type " + prog.printNode(n), firstDoc(n.Doc, parentDoc).Text()
+		case *ast.ValueSpec:
+			keyword := "var"
+			if _, ok := sym.obj.(*types.Const); ok {
+				keyword = "const"
+			}
+			return kind, keyword + " " + prog.printNode(n), firstDoc(n.Doc, parentDoc).Text()
+		case *ast.Field:
+			var names []string
+			for _, ident := range n.Names {
+				names = append(names, ident.Name)
+			}
+			field := strings.TrimSpace(strings.Join(names, ", ") + " " + oneLine(prog.fset, n.Type))
+			return kind, field, firstDoc(n.Doc, n.Comment).Text()
+		}
+	}
+	return kind, signature, ""
+}
+
+func firstDoc(groups ...*ast.CommentGroup) *ast.CommentGroup {
+	for _, g := range groups {
+		if g != nil {
+			return g
+		}
+	}
+	return nil
+}
+
+// printNode 格式化AST节点，过长时截断
+func (prog *goProgram) printNode(node ast.Node) string {
+	var buf bytes.Buffer
+	cfg := printer.Config{Mode: printer.UseSpaces | printer.TabIndent, Tabwidth: 8}
+	if err := cfg.Fprint(&buf, prog.fset, node); err != nil {
+		return ""
+	}
+	lines := strings.Split(buf.String(), "\n")
+	if len(lines) > symbolMaxSigLines {
+		lines = append(lines[:symbolMaxSigLines], fmt.Sprintf("\t// ... %d more lines", len(lines)-symbolMaxSigLines))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func goObjectKind(obj types.Object) string {
+	switch o := obj.(type) {
+	case *types.Func:
+		if sig, ok := o.Type().(*types.Signature); ok && sig.Recv() != nil {
+			return "method"
+		}
+		return "func"
+	case *types.TypeName:
+		return "type"
+	case *types.Const:
+		return "const"
+	case *types.Var:
+		if o.IsField() {
+			return "field"
+		}
+		return "var"
+	default:
+		return "symbol"
+	}
+}
+
+// FindDefinition 查找符号的定义位置、签名与文档注释
+func FindDefinition(symbol string, path string) string {
+	logger, done := symbolLogger("FindDefinition", symbol, path)
+	defer done()
+
+	symbol = strings.TrimSpace(symbol)
+	if symbol == "" {
+		return "Error: symbol is required"
+	}
+	if strings.TrimSpace(path) == "" {
+		path = "."
+	}
+
+	prog, err := loadGoProgram(path)
+	if err == nil {
+		if found := prog.findGoSymbols(symbol); len(found) > 0 {
+			if logger != nil {
+				logger.Printf("FindDefinition函数返回 - go/types找到%d个定义", len(found))
+			}
+			return prog.formatDefinitions(symbol, found)
+		}
+	} else if logger != nil {
+		logger.Printf("加载Go包失败，回退到正则大纲: %v", err)
+	}
+
+	return outlineDefinitions(symbol, path)
+}
+
+func (prog *goProgram) formatDefinitions(symbol string, found []goSymbol) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Found %d definition(s) of %q:\n", len(found), symbol))
+	for i, sym := range found {
+		if i == symbolMaxDefinitions {
+			sb.WriteString(fmt.Sprintf("\n[%d more definition(s) not shown. Qualify the symbol, e.g. Type.Method or pkg.Name.]", len(found)-i))
+			break
+		}
+		pos := prog.fset.Position(sym.obj.Pos())
+		kind, signature, doc := prog.describe(sym)
+		sb.WriteString(fmt.Sprintf("\n%s:%d:%d (%s in package %s)\n", normalizeRel(pos.Filename), pos.Line, pos.Column, kind, sym.pkg.Name))
+		if doc = strings.TrimSpace(doc); doc != "" {
+			for _, line := range strings.Split(doc, "\n") {
+				sb.WriteString("// " + line + "\n")
+			}
+		}
+		sb.WriteString(signature + "\n")
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// FindReferences 查找符号的所有引用位置（包括测试文件）
+func FindReferences(symbol string, path string) string {
+	logger, done := symbolLogger("FindReferences", symbol, path)
+	defer done()
+
+	symbol = strings.TrimSpace(symbol)
+	if symbol == "" {
+		return "Error: symbol is required"
+	}
+	if strings.TrimSpace(path) == "" {
+		path = "."
+	}
+
+	prog, err := loadGoProgram(path)
+	if err == nil {
+		if found := prog.findGoSymbols(symbol); len(found) > 0 {
+			refs := prog.references(found)
+			if logger != nil {
+				logger.Printf("FindReferences函数返回 - %d个定义, %d处引用", len(found), len(refs))
+			}
+			return prog.formatReferences(symbol, found, refs)
+		}
+	} else if logger != nil {
+		logger.Printf("加载Go包失败，回退到单词匹配: %v", err)
+	}
+
+	// 回退：按单词边界做文本搜索
+	name := symbol[strings.LastIndex(symbol, ".")+1:]
+	result := Grep(`\b`+regexp.QuoteMeta(name)+`\b`, path, "", GrepContent, 0, 0, 0, false, false, symbolMaxReferences, 0, false)
+	return fmt.Sprintf("No type-checked definition found for %q; word matches for %q instead:\n%s", symbol, name, result)
+}
+
+// references 收集所有包中引用了目标定义的标识符位置，按文件和行排序去重
+func (prog *goProgram) references(found []goSymbol) []token.Position {
+	targets := make(map[string]bool)
+	for _, sym := range found {
+		targets[prog.objectKey(sym.obj)] = true
+	}
+	seen := make(map[string]bool)
+	var refs []token.Position
+	for _, pkg := range prog.pkgs {
+		if pkg.TypesInfo == nil {
+			continue
+		}
+		for ident, obj := range pkg.TypesInfo.Uses {
+			if !obj.Pos().IsValid() || !targets[prog.objectKey(obj)] {
+				continue
+			}
+			pos := prog.fset.Position(ident.Pos())
+			if key := pos.String(); !seen[key] {
+				seen[key] = true
+				refs = append(refs, pos)
+			}
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].Filename != refs[j].Filename {
+			return refs[i].Filename < refs[j].Filename
+		}
+		return refs[i].Offset < refs[j].Offset
+	})
+	return refs
+}
+
+func (prog *goProgram) formatReferences(symbol string, found []goSymbol, refs []token.Position) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%d reference(s) to %q", len(refs), symbol))
+	var defs []string
+	for _, sym := range found {
+		pos := prog.fset.Position(sym.obj.Pos())
+		defs = append(defs, fmt.Sprintf("%s:%d", normalizeRel(pos.Filename), pos.Line))
+	}
+	sb.WriteString(fmt.Sprintf(" (defined at %s):\n", strings.Join(defs, ", ")))
+
+	lines := make(map[string][]string)
+	for i, pos := range refs {
+		if i == symbolMaxReferences {
+			sb.WriteString(fmt.Sprintf("\n[%d more reference(s) not shown.]", len(refs)-i))
+			break
+		}
+		content, ok := lines[pos.Filename]
+		if !ok {
+			if text, _, err := ReadTextFile(pos.Filename); err == nil {
+				content = strings.Split(text, "\n")
+			}
+			lines[pos.Filename] = content
+		}
+		text := ""
+		if pos.Line-1 < len(content) {
+			text = strings.TrimSpace(content[pos.Line-1])
+		}
+		if len(text) > grepMaxLineLength {
+			text = text[:grepMaxLineLength] + "..."
+		}
+		sb.WriteString(fmt.Sprintf("%s:%d:%d: %s\n", normalizeRel(pos.Filename), pos.Line, pos.Column, text))
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// outlineDefinitions 回退：在path下的非Go文件（以及无法加载的Go文件）大纲中按名称查找
+func outlineDefinitions(symbol, path string) string {
+	name := symbol[strings.LastIndex(symbol, ".")+1:]
+	var results []string
+	scanned := 0
+	_ = walker.Walk(path, walker.Options{}, func(p string, d fs.DirEntry) error {
+		if d.IsDir() {
+			return nil
+		}
+		isGo := strings.HasSuffix(p, ".go")
+		if !isGo && !HasOutline(p) {
+			return nil
+		}
+		if info, err := d.Info(); err != nil || info.Size() > outlineMaxFileSize {
+			return nil
+		}
+		if scanned++; scanned > outlineMaxFiles {
+			return filepath.SkipAll
+		}
+		content, _, err := ReadTextFile(p)
+		if err != nil {
+			return nil
+		}
+		var symbols []OutlineSymbol
+		if isGo {
+			symbols = goFileOutline(p, content)
+		} else {
+			symbols = OutlineText(p, content)
+		}
+		for _, s := range symbols {
+			if s.Name == name && len(results) < symbolMaxDefinitions {
+				results = append(results, fmt.Sprintf("%s:%d (%s)\n%s", normalizeRel(p), s.Line, s.Kind, s.Signature))
+			}
+		}
+		return nil
+	})
+	if len(results) == 0 {
+		return fmt.Sprintf("No definition found for %q", symbol)
+	}
+	return fmt.Sprintf("Found %d definition(s) of %q (regex outline, may be imprecise):\n\n%s", len(results), symbol, strings.Join(results, "\n\n"))
+}
+
+// ListSymbolsParams ListSymbols函数签名中的参数顺序
+var ListSymbolsParams = []string{"file_path"}
+
+// ListSymbols 列出文件中的顶层符号：Go文件用go/parser解析，其他文件用正则大纲
+func ListSymbols(file_path string) string {
+	logger, done := symbolLogger("ListSymbols", file_path, "")
+	defer done()
+
+	content, _, err := ReadTextFile(file_path)
+	if err != nil {
+		if logger != nil {
+			logger.Printf("ListSymbols函数返回 - 读取失败: %v", err)
+		}
+		return fmt.Sprintf("Error reading file: %v", err)
+	}
+	if !strings.HasSuffix(file_path, ".go") {
+		return FormatOutline(normalizeRel(file_path), OutlineText(file_path, content))
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, file_path, content, parser.ParseComments)
+	if err != nil && file == nil {
+		return fmt.Sprintf("Error parsing %s: %v", file_path, err)
+	}
+	var sb strings.Builder
+	count := 0
+	writeSym := func(pos token.Pos, signature string, doc *ast.CommentGroup) {
+		count++
+		sb.WriteString(fmt.Sprintf("  %d: %s\n", fset.Position(pos).Line, signature))
+		if text := strings.TrimSpace(doc.Text()); text != "" {
+			sb.WriteString("      // " + strings.SplitN(text, "\n", 2)[0] + "\n")
+		}
+	}
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			header := *d
+			header.Body = nil
+			header.Doc = nil
+			writeSym(d.Pos(), oneLine(fset, &header), d.Doc)
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					writeSym(s.Pos(), "type "+s.Name.Name+typeParamsText(fset, s)+" "+typeKind(s.Type), firstDoc(s.Doc, d.Doc))
+				case *ast.ValueSpec:
+					for _, n := range s.Names {
+						sig := d.Tok.String() + " " + n.Name
+						if s.Type != nil {
+							sig += " " + oneLine(fset, s.Type)
+						}
+						writeSym(n.Pos(), sig, firstDoc(s.Doc, d.Doc))
+					}
+				}
+			}
+		}
+	}
+	if count == 0 {
+		return fmt.Sprintf("No symbols found in %s", file_path)
+	}
+	header := fmt.Sprintf("%s (package %s, %d symbol(s)):\n", normalizeRel(file_path), file.Name.Name, count)
+	return header + strings.TrimRight(sb.String(), "\n")
+}
+
+// goFileOutline 不依赖类型检查的Go文件大纲，用于回退搜索
+func goFileOutline(path, content string) []OutlineSymbol {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, content, parser.SkipObjectResolution)
+	if file == nil || err != nil && len(file.Decls) == 0 {
+		return nil
+	}
+	var symbols []OutlineSymbol
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			header := *d
+			header.Body = nil
+			kind := "func"
+			if d.Recv != nil {
+				kind = "method"
+			}
+			symbols = append(symbols, OutlineSymbol{Name: d.Name.Name, Kind: kind, Line: fset.Position(d.Pos()).Line, Signature: oneLine(fset, &header)})
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					symbols = append(symbols, OutlineSymbol{Name: s.Name.Name, Kind: "type", Line: fset.Position(s.Pos()).Line, Signature: "type " + s.Name.Name + " " + typeKind(s.Type)})
+				case *ast.ValueSpec:
+					for _, n := range s.Names {
+						symbols = append(symbols, OutlineSymbol{Name: n.Name, Kind: d.Tok.String(), Line: fset.Position(n.Pos()).Line, Signature: d.Tok.String() + " " + n.Name})
+					}
+				}
+			}
+		}
+	}
+	return symbols
+}
+
+// oneLine 把节点格式化为单行
+func oneLine(fset *token.FileSet, node ast.Node) string {
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, node); err != nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(buf.String()), " ")
+}
+
+func typeParamsText(fset *token.FileSet, s *ast.TypeSpec) string {
+	if s.TypeParams == nil {
+		return ""
+	}
+	return "[" + strings.TrimSuffix(strings.TrimPrefix(oneLine(fset, s.TypeParams), "("), ")") + "]"
+}
+
+// typeKind 类型定义的简短描述（结构体与接口不展开字段）
+func typeKind(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StructType:
+		return fmt.Sprintf("struct (%d field(s))", t.Fields.NumFields())
+	case *ast.InterfaceType:
+		return fmt.Sprintf("interface (%d method(s))", t.Methods.NumFields())
+	default:
+		return oneLine(token.NewFileSet(), expr)
+	}
+}
+
+// symbolLogger 记录符号工具调用到 log/symbols.txt
+func symbolLogger(name, arg1, arg2 string) (*log.Logger, func()) {
+	start := time.Now()
+	logFile, err := os.OpenFile("./log/symbols.txt", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, func() {}
+	}
+	logger := log.New(logFile, "", log.LstdFlags)
+	logger.Printf("%s函数调用 - %q %q", name, arg1, arg2)
+	return logger, func() {
+		logger.Printf("%s函数执行完成 - 耗时: %v", name, time.Since(start))
+		logFile.Close()
+	}
+}
@@ -0,0 +1,106 @@
+package function
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// loadSymbolTestProgram 加载testdata中的小模块
+func loadSymbolTestProgram(t *testing.T) *goProgram {
+	t.Helper()
+	prog, err := loadGoProgram(filepath.Join("testdata", "symbolmod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return prog
+}
+
+func TestFindGoSymbolsQualifiers(t *testing.T) {
+	prog := loadSymbolTestProgram(t)
+	tests := []struct {
+		symbol string
+		want   []string // 文件名:行号
+	}{
+		{"NewRect", []string{"shapes.go:20"}},
+		{"shapes.NewRect", []string{"shapes.go:20"}},
+		{"Rect.Area", []string{"shapes.go:15"}},
+		{"(*Rect).Area", []string{"shapes.go:15"}},
+		{"shapes.Rect.Area", []string{"shapes.go:15"}},
+		{"Rect.W", []string{"shapes.go:11"}},
+		// 只写名称时同时找到接口方法和具体方法
+		{"Area", []string{"shapes.go:6", "shapes.go:15"}},
+		{"Shape.Area", []string{"shapes.go:6"}},
+		{"other.NewRect", nil},
+		{"Missing", nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, sym := range prog.findGoSymbols(tt.symbol) {
+			pos := prog.fset.Position(sym.obj.Pos())
+			got = append(got, fmt.Sprintf("%s:%d", filepath.Base(pos.Filename), pos.Line))
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("findGoSymbols(%q) = %q, want %q", tt.symbol, got, tt.want)
+		}
+	}
+}
+
+func TestGoReferencesIncludeTestFiles(t *testing.T) {
+	prog := loadSymbolTestProgram(t)
+	tests := []struct {
+		symbol string
+		want   []string // 文件名:行号
+	}{
+		// 包内测试、外部测试包和其他包中的引用
+		{"NewRect", []string{"example_test.go:10", "main.go:10", "shapes_test.go:6"}},
+		// 通过接口调用的引用属于接口方法
+		{"Shape.Area", []string{"example_test.go:11"}},
+		{"(*Rect).Area", []string{"main.go:11", "shapes_test.go:6"}},
+	}
+	for _, tt := range tests {
+		found := prog.findGoSymbols(tt.symbol)
+		if len(found) == 0 {
+			t.Fatalf("findGoSymbols(%q) found nothing", tt.symbol)
+		}
+		var got []string
+		for _, pos := range prog.references(found) {
+			got = append(got, fmt.Sprintf("%s:%d", filepath.Base(pos.Filename), pos.Line))
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("references(%q) = %q, want %q", tt.symbol, got, tt.want)
+		}
+	}
+}
+
+func TestOutlineText(t *testing.T) {
+	tests := []struct {
+		path    string
+		content string
+		want    []string // 种类 名称
+	}{
+		{"app.py", "class Server:\n    async def handle(self):\n        pass\n# def commented():\n", []string{"class Server", "function handle"}},
+		{"app.ts", "export interface Props {}\nexport type ID = string\nexport default class App {\n  render(): string {\n    if (x) {\n    }\n  }\n}\nexport const load = async (id) => {}\n", []string{"interface Props", "type ID", "class App", "method render", "function load"}},
+		{"Main.java", "public class Main {\n    public static void main(String[] args) {\n    }\n}\n", []string{"class Main", "method main"}},
+		{"Util.kt", "data class Point(val x: Int)\nsuspend fun fetch(): Int {\n", []string{"class Point", "function fetch"}},
+		{"lib.rs", "pub struct Config {}\nimpl Display for Config {\n    pub(crate) async fn run() {}\n}\nmod tests {}\n", []string{"struct Config", "impl Config", "function run", "module tests"}},
+		{"main.c", "typedef struct node {\nint main(int argc, char **argv) {\n", []string{"struct node", "function main"}},
+		{"model.rb", "module Shop\n  class Order\n    def self.total?\n", []string{"module Shop", "class Order", "method total?"}},
+		{"index.php", "final class Cart {\n    public static function &items() {\n", []string{"class Cart", "function items"}},
+		{"build.sh", "build() {\nfunction deploy {\n", []string{"function build", "function deploy"}},
+		{"README.md", "# Title\n## Usage ##\n```\n# not a heading\n```\n", []string{"heading Title", "heading Usage"}},
+		{"init.lua", "function setup()\n", []string{"function setup"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, sym := range OutlineText(tt.path, tt.content) {
+			got = append(got, sym.Kind+" "+sym.Name)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("OutlineText(%s) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
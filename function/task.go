@@ -16,8 +16,9 @@ import (
 )
 
 type TaskRequest struct {
-	Description string `json:"description"`
-	Prompt      string `json:"prompt"`
+	Description  string `json:"description"`
+	Prompt       string `json:"prompt"`
+	SubagentType string `json:"subagent_type"`
 }
 
 type FunctionParam struct {
@@ -35,6 +36,18 @@ type FunctionDescription struct {
 	Parameters  FunctionParameters `json:"parameters"`
 }
 
+// OrderedParams 按函数签名的顺序返回参数名和描述。
+// Properties是map，遍历顺序不固定，参数较多的函数需要显式指定顺序。
+func (d FunctionDescription) OrderedParams(names ...string) ([]string, []string) {
+	var paramNames []string
+	var paramDescs []string
+	for _, name := range names {
+		paramNames = append(paramNames, name)
+		paramDescs = append(paramDescs, d.Parameters.Properties[name].Description)
+	}
+	return paramNames, paramDescs
+}
+
 // logToTaskFile 记录Task函数专用日志
 func logToTaskFile(message string) {
 	// 确保log目录存在
@@ -54,44 +67,100 @@ func logToTaskFile(message string) {
 	fmt.Fprintf(file, "%s %s\n", timestamp, message)
 }
 
-func Task(description string, prompt string) string {
+// Task 执行子代理任务，subagentType选择代理类型（为空时使用通用代理）；
+// 已由 StartTasks 在后台启动时等待其结果，否则直接执行
+func Task(description string, prompt string, subagentType string) string {
+	if run := tasks.claim(description, prompt, subagentType); run != nil {
+		logToTaskFile(fmt.Sprintf("Task函数：等待后台子代理完成 - description: %s", description))
+		<-run.done
+		return run.result
+	}
+	return runTask(subAgentContext(), tasks.newProgress(description), description, prompt, subagentType)
+}
+
+// runTask 创建子代理执行任务，进度记录到p；ctx取消时子代理随之停止
+func runTask(ctx context.Context, p *SubAgentProgress, description string, prompt string, subagentType string) string {
 	// 添加调试日志
-	logToTaskFile(fmt.Sprintf("Task函数开始执行 - description: %s", description))
+	logToTaskFile(fmt.Sprintf("Task函数开始执行 - description: %s, subagent_type: %s", description, subagentType))
 	
 	req := TaskRequest{
-		Description: description,
-		Prompt:      prompt,
+		Description:  description,
+		Prompt:       prompt,
+		SubagentType: subagentType,
 	}
 
-	logToTaskFile("Task函数：正在加载配置文件")
-	config, err := general.LoadConfig("./LLMConfig.yaml")
+	fail := func(err error) string {
+		logToTaskFile(fmt.Sprintf("Task函数：创建子代理失败: %v", err))
+		tasks.update(p, func(p *SubAgentProgress) {
+			p.Status = "failed"
+			p.Error = err.Error()
+			p.FinishedAt = time.Now()
+		})
+		return fmt.Sprintf("Error: %v", err)
+	}
+
+	// 代理类型决定系统提示词、可用工具和模型
+	agent, err := FindAgentDefinition(req.SubagentType)
 	if err != nil {
-		return fmt.Sprintf("Failed to load config: %v", err)
+		return fail(err)
 	}
-	logToTaskFile("Task函数：配置文件加载成功")
+	tasks.update(p, func(p *SubAgentProgress) {
+		p.AgentType = agent.Name
+	})
 
-	logToTaskFile("Task函数：正在创建AgentManager和ConversationManager")
-	agentManager := general.NewAgentManager()
-	agentManager.AddProvider(config.ToProviderConfigs()[0])
+	logToTaskFile(fmt.Sprintf("Task函数：正在创建子代理会话，代理类型: %s", agent.Name))
+	cm, provider, model, err := newSubAgent(agent.Name, agent.Model, agent.SystemPrompt)
+	if err != nil {
+		return fail(err)
+	}
+	tasks.update(p, func(p *SubAgentProgress) {
+		p.Status = "running"
+		p.Provider = provider
+		p.Model = model
+		p.StartedAt = time.Now()
+	})
 
-	cm := ConversationManager.NewConversationManager(agentManager)
-	cm.SetSystemPrompt("You are a helpful AI assistant that can perform various tasks using available tools.")
-	
 	logToTaskFile("Task函数：正在注册函数")
-	// 注册函数
-	registerTaskFunctions(cm)
+	// 注册函数；子代理使用独立的待办事项清单，不影响主代理
+	todoAgent := NewTodoAgent(description)
+	logToTaskFile(fmt.Sprintf("Task函数：子代理待办事项清单: %s", todoAgent))
+	registerTaskFunctions(cm, todoAgent, agent.Tools)
 	logToTaskFile("Task函数：函数注册完成")
 
 	logToTaskFile("Task函数：准备调用Chat方法")
-	logToTaskFile(fmt.Sprintf("请求模型: %s", config.AgentAPIKey.OpenAI.Model))
-	logToTaskFile(fmt.Sprintf("请求提供商: %s", general.ProviderOpenAI))
+	logToTaskFile(fmt.Sprintf("请求模型: %s", model))
+	logToTaskFile(fmt.Sprintf("请求提供商: %s", provider))
 	logToTaskFile(fmt.Sprintf("输入文本长度: %d 字符", len(req.Prompt)))
 	
 	// 记录网络请求时间
 	networkStart := time.Now()
-	ctx := context.Background()
-	messages, _, err, usage := cm.Chat(ctx, general.ProviderOpenAI, config.AgentAPIKey.OpenAI.Model, req.Prompt, []string{}, nil)
+	// 通过info_chan跟踪子代理当前的工具调用和token用量
+	infoChan := make(chan general.Message)
+	watched := make(chan struct{})
+	go func() {
+		defer close(watched)
+		watchSubAgent(cm, p, infoChan)
+	}()
+	messages, _, err, usage := cm.Chat(ctx, provider, model, req.Prompt, []string{}, infoChan)
+	close(infoChan)
+	<-watched
 	networkDuration := time.Since(networkStart)
+	tasks.update(p, func(p *SubAgentProgress) {
+		p.CurrentTool = ""
+		p.FinishedAt = time.Now()
+		if usage != nil {
+			p.Usage = *usage
+		}
+		switch {
+		case ctx.Err() != nil:
+			p.Status = "cancelled"
+		case err != nil:
+			p.Status = "failed"
+			p.Error = err.Error()
+		default:
+			p.Status = "completed"
+		}
+	})
 	
 	logToTaskFile(fmt.Sprintf("Task函数：Chat方法调用完成，网络耗时: %v", networkDuration))
 	
@@ -113,7 +182,7 @@ func Task(description string, prompt string) string {
 	}
 	
 	// 记录到网络性能日志
-	logTaskNetworkPerformance(req.Prompt, config.AgentAPIKey.OpenAI.Model, networkDuration, usage, err)
+	logTaskNetworkPerformance(req.Prompt, provider, model, networkDuration, usage, err)
 
 	if err != nil {
 		logToTaskFile(fmt.Sprintf("Task函数：Chat方法出错: %v", err))
@@ -146,8 +215,8 @@ func Task(description string, prompt string) string {
 	return result
 }
 
-// registerTaskFunctions 注册Task子代理需要的所有函数
-func registerTaskFunctions(cm *ConversationManager.ConversationManager) {
+// registerTaskFunctions 注册子代理可以使用的工具tools，TodoRead/TodoWrite读写todoAgent的清单
+func registerTaskFunctions(cm *ConversationManager.ConversationManager, todoAgent string, tools []string) {
 	logToTaskFile("registerTaskFunctions：开始注册函数")
 	// 读取函数描述文件
 	functionDescFile := "./function/function_description.json"
@@ -166,8 +235,8 @@ func registerTaskFunctions(cm *ConversationManager.ConversationManager) {
 		return // 如果解析失败，跳过注册
 	}
 
-	// 注册指定的函数列表
-	functionList := []string{"Bash", "Glob", "Grep", "LS", "Read", "Edit", "MultiEdit", "Write", "WebFetch", "TodoRead", "TodoWrite", "WebSearch"}
+	// 注册代理类型允许的函数
+	functionList := tools
 	logToTaskFile(fmt.Sprintf("registerTaskFunctions：准备注册%d个函数", len(functionList)))
 	
 	for _, funcName := range functionList {
@@ -204,11 +273,26 @@ func registerTaskFunctions(cm *ConversationManager.ConversationManager) {
 				// 注意：Task子代理无法访问主程序的LukatinCode实例，所以使用简化的Bash函数
 				cm.RegisterFunction("Bash", desc.Description, SimpleBash, paramNames, paramDescs)
 			case "Glob":
+				paramNames, paramDescs = desc.OrderedParams(GlobParams...)
 				cm.RegisterFunction("Glob", desc.Description, Glob, paramNames, paramDescs)
 			case "Grep":
+				paramNames, paramDescs = desc.OrderedParams(GrepParams...)
 				cm.RegisterFunction("Grep", desc.Description, Grep, paramNames, paramDescs)
 			case "LS":
+				paramNames, paramDescs = desc.OrderedParams(LSParams...)
 				cm.RegisterFunction("LS", desc.Description, LS, paramNames, paramDescs)
+			case "FindDefinition":
+				paramNames, paramDescs = desc.OrderedParams(SymbolParams...)
+				cm.RegisterFunction("FindDefinition", desc.Description, FindDefinition, paramNames, paramDescs)
+			case "FindReferences":
+				paramNames, paramDescs = desc.OrderedParams(SymbolParams...)
+				cm.RegisterFunction("FindReferences", desc.Description, FindReferences, paramNames, paramDescs)
+			case "ListSymbols":
+				paramNames, paramDescs = desc.OrderedParams(ListSymbolsParams...)
+				cm.RegisterFunction("ListSymbols", desc.Description, ListSymbols, paramNames, paramDescs)
+			case "CodeSearch":
+				paramNames, paramDescs = desc.OrderedParams(CodeSearchParams...)
+				cm.RegisterFunction("CodeSearch", desc.Description, CodeSearch, paramNames, paramDescs)
 			case "Read":
 				cm.RegisterFunction("Read", desc.Description, Read, paramNames, paramDescs)
 			case "Edit":
@@ -217,12 +301,24 @@ func registerTaskFunctions(cm *ConversationManager.ConversationManager) {
 				cm.RegisterFunction("MultiEdit", desc.Description, MultiEdit, paramNames, paramDescs)
 			case "Write":
 				cm.RegisterFunction("Write", desc.Description, Write, paramNames, paramDescs)
+			case "ApplyPatch":
+				cm.RegisterFunction("ApplyPatch", desc.Description, ApplyPatch, paramNames, paramDescs)
+			case "MultiFileEdit":
+				cm.RegisterFunction("MultiFileEdit", desc.Description, MultiFileEdit, paramNames, paramDescs)
+			case "RenameSymbol":
+				paramNames, paramDescs = desc.OrderedParams(RenameParams...)
+				cm.RegisterFunction("RenameSymbol", desc.Description, RenameSymbol, paramNames, paramDescs)
+			case "NotebookRead":
+				cm.RegisterFunction("NotebookRead", desc.Description, NotebookRead, paramNames, paramDescs)
+			case "NotebookEdit":
+				paramNames, paramDescs = desc.OrderedParams(NotebookEditParams...)
+				cm.RegisterFunction("NotebookEdit", desc.Description, NotebookEdit, paramNames, paramDescs)
 			case "WebFetch":
 				cm.RegisterFunction("WebFetch", desc.Description, WebFetch, paramNames, paramDescs)
 			case "TodoRead":
-				cm.RegisterFunction("TodoRead", desc.Description, TodoRead, paramNames, paramDescs)
+				cm.RegisterFunction("TodoRead", desc.Description, TodoReadFor(todoAgent), paramNames, paramDescs)
 			case "TodoWrite":
-				cm.RegisterFunction("TodoWrite", desc.Description, TodoWrite, paramNames, paramDescs)
+				cm.RegisterFunction("TodoWrite", desc.Description, TodoWriteFor(todoAgent), paramNames, paramDescs)
 			case "WebSearch":
 				cm.RegisterFunction("WebSearch", desc.Description, WebSearch, paramNames, paramDescs)
 			}
@@ -252,8 +348,8 @@ func SimpleBash(command string, description string, timeout int) string {
 	timeoutDuration := time.Duration(timeout) * time.Millisecond
 	logToTaskFile(fmt.Sprintf("SimpleBash：设置超时时间: %v", timeoutDuration))
 	
-	// 创建带超时的上下文
-	ctx, cancel := context.WithTimeout(context.Background(), timeoutDuration)
+	// 创建带超时的上下文；用户取消这一轮对话时命令一并终止
+	ctx, cancel := context.WithTimeout(subAgentContext(), timeoutDuration)
 	defer cancel()
 	
 	var cmd *exec.Cmd
@@ -292,7 +388,7 @@ func SimpleBash(command string, description string, timeout int) string {
 }
 
 // logTaskNetworkPerformance 记录Task网络性能数据到专门的日志文件
-func logTaskNetworkPerformance(input, model string, networkDuration time.Duration, usage *general.Usage, err error) {
+func logTaskNetworkPerformance(input string, provider general.Provider, model string, networkDuration time.Duration, usage *general.Usage, err error) {
 	// 确保log目录存在
 	if _, err := os.Stat("log"); os.IsNotExist(err) {
 		os.MkdirAll("log", 0755)
@@ -323,7 +419,7 @@ func logTaskNetworkPerformance(input, model string, networkDuration time.Duratio
 	
 	fmt.Fprintf(file, "%s | %s | %s | Input:%d chars | Network:%v | %s | Source:TASK | %s\n",
 		timestamp,
-		general.ProviderOpenAI,
+		provider,
 		model,
 		len(input),
 		networkDuration,
@@ -0,0 +1,228 @@
+package function
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ccIisIaIcat/GoAgent/agent/ConversationManager"
+	"github.com/ccIisIaIcat/GoAgent/agent/general"
+)
+
+// 并行执行Task：模型在一次回复中发起的工具调用是依次执行的。第一个Task被调用时，
+// 主程序通过 StartTasks 同时启动这条回复中的所有Task（受 maxConcurrentTasks 限制），
+// 之后的Task调用直接等待各自的结果。SubAgentSnapshot 供TUI显示每个子代理的进度。
+
+const (
+	defaultMaxConcurrentTasks = 3 // 未配置 maxConcurrentTasks 时同时运行的子代理数
+	subAgentRecentTools       = 5 // 进度中保留的最近工具调用数
+)
+
+// TaskCall 模型发起的一次Task调用
+type TaskCall struct {
+	ID           string // 工具调用ID
+	Description  string
+	Prompt       string
+	SubagentType string
+}
+
+// SubAgentProgress 子代理的进度
+type SubAgentProgress struct {
+	ID          string
+	Description string
+	AgentType   string // 代理类型，确定之前为空
+	Status      string // queued, running, completed, failed, cancelled
+	Provider    general.Provider
+	Model       string
+	CurrentTool string   // 正在执行的工具调用，等待模型回复时为空
+	RecentTools []string // 最近的工具调用，最新的在最后
+	ToolCount   int
+	Usage       general.Usage
+	StartedAt   time.Time
+	FinishedAt  time.Time
+	Error       string
+
+	pending []string // 这次回复中尚未返回结果的工具调用
+}
+
+// taskRun 已启动的Task，等待主代理的Task调用取走结果
+type taskRun struct {
+	call    TaskCall
+	done    chan struct{}
+	result  string
+	claimed bool
+}
+
+type taskRunner struct {
+	mu       sync.Mutex
+	runs     []*taskRun
+	launched map[string]bool // 已启动的工具调用ID
+	progress []*SubAgentProgress
+	nextID   int
+}
+
+var tasks = &taskRunner{launched: make(map[string]bool)}
+
+// maxConcurrentTasks 同时运行的子代理数，来自 .lukatin/settings.json 的 maxConcurrentTasks
+func maxConcurrentTasks() int {
+	settings, err := LoadProjectSettings()
+	if err != nil || settings.MaxConcurrentTasks <= 0 {
+		return defaultMaxConcurrentTasks
+	}
+	return settings.MaxConcurrentTasks
+}
+
+// ResetSubAgents 开始新一轮对话时清空上一轮的子代理进度
+func ResetSubAgents() {
+	tasks.mu.Lock()
+	defer tasks.mu.Unlock()
+	tasks.runs = nil
+	tasks.launched = make(map[string]bool)
+	tasks.progress = nil
+}
+
+// StartTasks 在后台启动calls中尚未启动的Task，最多同时运行maxConcurrentTasks个，其余排队；
+// 使用当前这一轮的context，取消时排队和运行中的子代理都会停止
+func StartTasks(calls []TaskCall) {
+	ctx := subAgentContext()
+	sem := make(chan struct{}, maxConcurrentTasks())
+
+	tasks.mu.Lock()
+	defer tasks.mu.Unlock()
+	for _, call := range calls {
+		if call.ID != "" && tasks.launched[call.ID] {
+			continue
+		}
+		tasks.launched[call.ID] = true
+		run := &taskRun{call: call, done: make(chan struct{})}
+		p := tasks.newProgressLocked(call.Description)
+		tasks.runs = append(tasks.runs, run)
+
+		go func() {
+			defer close(run.done)
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				tasks.update(p, func(p *SubAgentProgress) {
+					p.Status = "cancelled"
+					p.FinishedAt = time.Now()
+				})
+				run.result = "Error: task was cancelled before it started"
+				return
+			}
+			defer func() { <-sem }()
+			run.result = runTask(ctx, p, call.Description, call.Prompt, call.SubagentType)
+		}()
+	}
+}
+
+// claim 取走与参数相同、尚未被取走的已启动Task
+func (r *taskRunner) claim(description, prompt, subagentType string) *taskRun {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, run := range r.runs {
+		if !run.claimed && run.call.Description == description && run.call.Prompt == prompt && run.call.SubagentType == subagentType {
+			run.claimed = true
+			return run
+		}
+	}
+	return nil
+}
+
+func (r *taskRunner) newProgressLocked(description string) *SubAgentProgress {
+	r.nextID++
+	p := &SubAgentProgress{
+		ID:          fmt.Sprintf("agent-%d", r.nextID),
+		Description: description,
+		Status:      "queued",
+	}
+	r.progress = append(r.progress, p)
+	return p
+}
+
+func (r *taskRunner) newProgress(description string) *SubAgentProgress {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.newProgressLocked(description)
+}
+
+// update 在锁内修改子代理进度
+func (r *taskRunner) update(p *SubAgentProgress, fn func(p *SubAgentProgress)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fn(p)
+}
+
+// SubAgentSnapshot 本轮所有子代理的进度，按启动顺序
+func SubAgentSnapshot() []SubAgentProgress {
+	tasks.mu.Lock()
+	defer tasks.mu.Unlock()
+	snapshot := make([]SubAgentProgress, len(tasks.progress))
+	for i, p := range tasks.progress {
+		snapshot[i] = *p
+		snapshot[i].RecentTools = append([]string(nil), p.RecentTools...)
+	}
+	return snapshot
+}
+
+// watchSubAgent 消费子代理的info_chan，更新当前工具调用和token用量。
+// info_chan不带缓冲：收到助手消息时Chat已更新TotalUsage，且在工具结果被接收之前不会再次修改
+func watchSubAgent(cm *ConversationManager.ConversationManager, p *SubAgentProgress, infoChan chan general.Message) {
+	for msg := range infoChan {
+		switch msg.Role {
+		case general.RoleAssistant:
+			var usage general.Usage
+			if cm.TotalUsage != nil {
+				usage = *cm.TotalUsage
+			}
+			tasks.update(p, func(p *SubAgentProgress) {
+				p.Usage = usage
+				p.pending = nil
+				for _, toolCall := range msg.ToolCalls {
+					summary := toolCallSummary(toolCall)
+					p.pending = append(p.pending, summary)
+					p.ToolCount++
+					p.RecentTools = append(p.RecentTools, summary)
+				}
+				if len(p.RecentTools) > subAgentRecentTools {
+					p.RecentTools = p.RecentTools[len(p.RecentTools)-subAgentRecentTools:]
+				}
+				p.CurrentTool = ""
+				if len(p.pending) > 0 {
+					p.CurrentTool = p.pending[0]
+				}
+			})
+		case general.RoleTool:
+			// 工具按顺序执行，一个结果返回后轮到下一个
+			tasks.update(p, func(p *SubAgentProgress) {
+				if len(p.pending) > 0 {
+					p.pending = p.pending[1:]
+				}
+				p.CurrentTool = ""
+				if len(p.pending) > 0 {
+					p.CurrentTool = p.pending[0]
+				}
+			})
+		}
+	}
+}
+
+// toolCallSummary 形如 Grep(TODO) 的简短描述，参数取路径、模式、命令等最有代表性的一个
+func toolCallSummary(toolCall general.ToolCall) string {
+	var args map[string]interface{}
+	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
+		return toolCall.Function.Name
+	}
+	for _, key := range []string{"file_path", "notebook_path", "path", "pattern", "command", "url", "query", "symbol", "description"} {
+		if value, ok := args[key].(string); ok && value != "" {
+			value = strings.Join(strings.Fields(value), " ")
+			if runes := []rune(value); len(runes) > 40 {
+				value = string(runes[:40]) + "…"
+			}
+			return fmt.Sprintf("%s(%s)", toolCall.Function.Name, value)
+		}
+	}
+	return toolCall.Function.Name
+}
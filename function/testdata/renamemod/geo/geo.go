@@ -0,0 +1,49 @@
+// Package geo 几何图形
+package geo
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Shape 有面积的图形
+type Shape interface {
+	Area() float64
+}
+
+// Rect 矩形
+type Rect struct {
+	W, H float64
+}
+
+// Area 返回矩形的面积
+func (r *Rect) Area() float64 {
+	return r.W * r.H
+}
+
+// Perimeter 返回矩形的周长
+func (r *Rect) Perimeter() float64 {
+	return 2 * (r.W + r.H)
+}
+
+// String 实现 fmt.Stringer
+func (r *Rect) String() string {
+	return fmt.Sprintf("%gx%g", r.W, r.H)
+}
+
+// NewRect 创建矩形，边长不能为负
+func NewRect(w, h float64) (*Rect, error) {
+	if w < 0 || h < 0 {
+		return nil, errors.New("negative size")
+	}
+	return &Rect{W: w, H: h}, nil
+}
+
+// Total 返回所有图形的面积之和
+func Total(shapes []Shape) float64 {
+	sum := 0.0
+	for i := 0; i < len(shapes); i++ {
+		sum += shapes[i].Area()
+	}
+	return sum
+}
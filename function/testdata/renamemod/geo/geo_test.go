@@ -0,0 +1,13 @@
+package geo
+
+import "testing"
+
+func TestTotal(t *testing.T) {
+	r, err := NewRect(2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Total([]Shape{r}); got != r.Area() {
+		t.Errorf("Total = %g, want %g", got, r.Area())
+	}
+}
@@ -0,0 +1,15 @@
+package main
+
+import (
+	"fmt"
+
+	"example.com/renamemod/geo"
+)
+
+func main() {
+	r, err := geo.NewRect(3, 4)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(r, geo.Total([]geo.Shape{r}))
+}
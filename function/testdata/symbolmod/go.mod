@@ -0,0 +1,3 @@
+module example.com/symbolmod
+
+go 1.24
@@ -0,0 +1,12 @@
+package main
+
+import (
+	"fmt"
+
+	"example.com/symbolmod/shapes"
+)
+
+func main() {
+	r := shapes.NewRect(3, 4)
+	fmt.Println(r.Area(), r.W)
+}
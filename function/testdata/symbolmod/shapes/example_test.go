@@ -0,0 +1,13 @@
+package shapes_test
+
+import (
+	"fmt"
+
+	"example.com/symbolmod/shapes"
+)
+
+func ExampleNewRect() {
+	var s shapes.Shape = shapes.NewRect(1, 2)
+	fmt.Println(s.Area())
+	// Output: 2
+}
@@ -0,0 +1,22 @@
+// Package shapes 符号导航测试用的小包
+package shapes
+
+// Shape 有面积的图形
+type Shape interface {
+	Area() float64
+}
+
+// Rect 矩形
+type Rect struct {
+	W, H float64
+}
+
+// Area 矩形的面积
+func (r *Rect) Area() float64 {
+	return r.W * r.H
+}
+
+// NewRect 创建矩形
+func NewRect(w, h float64) *Rect {
+	return &Rect{W: w, H: h}
+}
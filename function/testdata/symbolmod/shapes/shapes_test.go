@@ -0,0 +1,9 @@
+package shapes
+
+import "testing"
+
+func TestArea(t *testing.T) {
+	if NewRect(2, 3).Area() != 6 {
+		t.Fail()
+	}
+}
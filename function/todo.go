@@ -8,7 +8,6 @@ import (
 	"os"
 	"regexp"
 	"strings"
-	"sync"
 )
 
 // TodoItem 待办事项结构
@@ -17,20 +16,11 @@ type TodoItem struct {
 	Content  string `json:"content"`  // 任务内容
 	Status   string `json:"status"`   // pending, in_progress, completed
 	Priority string `json:"priority"` // high, medium, low
-}
-
-// TodoList 待办事项列表
-type TodoList struct {
-	Items []TodoItem `json:"items"`
-	mu    sync.RWMutex
-}
 
-var globalTodoList = &TodoList{
-	Items: make([]TodoItem, 0),
+	ParentID  string   `json:"parent_id,omitempty"`  // 父任务ID，可选
+	BlockedBy []string `json:"blocked_by,omitempty"` // 需要先完成的任务ID，可选
 }
 
-const todoFilePath = "./log/todolist.json"
-
 var todoLogger *log.Logger
 var todoLogFile *os.File
 
@@ -52,20 +42,23 @@ type TodoWriteRequest struct {
 	Todos []TodoItem `json:"todos"`
 }
 
-// TodoRead 读取当前所有待办事项
+// TodoRead 读取主代理当前所有待办事项
 func TodoRead() string {
+	return todoReadFor(MainTodoAgent)
+}
+
+// todoReadFor 读取agent当前所有待办事项
+func todoReadFor(agent string) string {
 	// 记录日志
 	logFile, err := os.OpenFile("./log/todoread.txt", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
 	if err == nil {
 		defer logFile.Close()
 		logger := log.New(logFile, "", log.LstdFlags)
-		logger.Printf("TodoRead函数调用")
+		logger.Printf("TodoRead函数调用 - agent: %s", agent)
 	}
 
-	globalTodoList.mu.RLock()
-	defer globalTodoList.mu.RUnlock()
-
-	if len(globalTodoList.Items) == 0 {
+	items := todos.todoItems(agent)
+	if len(items) == 0 {
 		if logFile != nil {
 			logger := log.New(logFile, "", log.LstdFlags)
 			logger.Printf("TodoRead函数返回 - 暂无待办事项")
@@ -73,7 +66,7 @@ func TodoRead() string {
 		return "暂无待办事项"
 	}
 
-	data, err := json.Marshal(globalTodoList.Items)
+	data, err := json.Marshal(items)
 	if err != nil {
 		if logFile != nil {
 			logger := log.New(logFile, "", log.LstdFlags)
@@ -85,14 +78,19 @@ func TodoRead() string {
 	result := string(data)
 	if logFile != nil {
 		logger := log.New(logFile, "", log.LstdFlags)
-		logger.Printf("TodoRead函数返回 - 成功读取%d项待办事项", len(globalTodoList.Items))
+		logger.Printf("TodoRead函数返回 - 成功读取%d项待办事项", len(items))
 	}
 	return result
 }
 
-// TodoWrite 写入完整的待办事项列表
+// TodoWrite 写入主代理完整的待办事项列表
 func TodoWrite(requestJSON string) string {
-	todoLogger.Printf("[DEBUG] TodoWrite开始执行，参数长度: %d", len(requestJSON))
+	return todoWriteFor(MainTodoAgent, requestJSON)
+}
+
+// todoWriteFor 写入agent完整的待办事项列表，并保存当前会话
+func todoWriteFor(agent string, requestJSON string) string {
+	todoLogger.Printf("[DEBUG] TodoWrite开始执行，agent: %s，参数长度: %d", agent, len(requestJSON))
 
 	// 首先尝试解析为包含request字段的格式
 	type WrappedRequest struct {
@@ -137,6 +135,8 @@ func TodoWrite(requestJSON string) string {
 				if err3 := json.Unmarshal([]byte(fixedJSON), &directItems); err3 == nil {
 					todoLogger.Printf("[DEBUG] 修复后成功解析为TodoItem数组，items数量: %d", len(directItems))
 					req.Todos = directItems
+				} else if err4 := json.Unmarshal([]byte(fixedJSON), &req); err4 == nil && len(req.Todos) > 0 {
+					todoLogger.Printf("[DEBUG] 修复后成功解析为TodoWriteRequest，todos数量: %d", len(req.Todos))
 				} else {
 					todoLogger.Printf("[DEBUG] 修复后仍然解析失败: %v", err3)
 
@@ -168,11 +168,6 @@ func TodoWrite(requestJSON string) string {
 		}
 	}
 
-	todoLogger.Printf("[DEBUG] 开始获取锁")
-	globalTodoList.mu.Lock()
-	defer globalTodoList.mu.Unlock()
-	todoLogger.Printf("[DEBUG] 成功获取锁")
-
 	// 验证数据
 	todoLogger.Printf("[DEBUG] 开始验证todos数据")
 	if err := validateTodos(req.Todos); err != nil {
@@ -181,14 +176,15 @@ func TodoWrite(requestJSON string) string {
 	}
 	todoLogger.Printf("[DEBUG] 数据验证通过")
 
-	// 更新全局列表
-	todoLogger.Printf("[DEBUG] 开始更新全局列表")
-	globalTodoList.Items = req.Todos
-	todoLogger.Printf("[DEBUG] 全局列表更新完成，当前items数量: %d", len(globalTodoList.Items))
+	// 更新该代理的列表并保存会话；保存失败不影响本次更新
+	todoLogger.Printf("[DEBUG] 开始更新列表")
+	if err := todos.replace(agent, req.Todos); err != nil {
+		todoLogger.Printf("保存待办事项失败: %v", err)
+	}
+	todoLogger.Printf("[DEBUG] 列表更新完成，当前items数量: %d", len(req.Todos))
 
-	// 直接格式化结果，避免死锁
 	todoLogger.Printf("[DEBUG] 开始生成返回结果")
-	result := formatTodoList(globalTodoList.Items)
+	result := formatTodoList(req.Todos)
 	todoLogger.Printf("[DEBUG] TodoWrite执行完成，返回结果长度: %d", len(result))
 	return result
 }
@@ -235,7 +231,8 @@ func validateTodos(todos []TodoItem) error {
 		}
 	}
 
-	return nil
+	// 检查父任务和依赖关系
+	return validateTodoLinks(todos)
 }
 
 // formatTodoList 格式化待办事项列表（无锁版本，供内部使用）
@@ -250,8 +247,9 @@ func formatTodoList(items []TodoItem) string {
 	todoLogger.Printf("[DEBUG] 开始构建结果字符串")
 	var result strings.Builder
 
-	// 按创建顺序显示所有任务
+	// 按创建顺序显示所有任务，子任务按层级缩进，未完成的依赖标注在后面
 	todoLogger.Printf("[DEBUG] 开始遍历items")
+	byID := todoIndex(items)
 	for i, item := range items {
 		todoLogger.Printf("[DEBUG] 处理item %d: ID=%s, Status=%s", i, item.ID, item.Status)
 		var statusIcon, taskLine string
@@ -269,7 +267,13 @@ func formatTodoList(items []TodoItem) string {
 			// 已完成的任务
 			taskLine = fmt.Sprintf("%s %s", statusIcon, item.Content)
 		}
+		if item.Status != "completed" {
+			if open := openBlockers(item, byID); len(open) > 0 {
+				taskLine += fmt.Sprintf("（被 #%s 阻塞）", strings.Join(open, ", #"))
+			}
+		}
 
+		result.WriteString(strings.Repeat("  ", todoDepth(item, byID)))
 		result.WriteString(taskLine)
 		result.WriteString("\n")
 		todoLogger.Printf("[DEBUG] 完成item %d处理", i)
@@ -300,16 +304,12 @@ func formatTodoList(items []TodoItem) string {
 	return finalResult
 }
 
-// listTodos 列出所有待办事项
+// listTodos 列出主代理的所有待办事项
 func listTodos() string {
 	todoLogger.Printf("[DEBUG] listTodos开始执行")
 
-	todoLogger.Printf("[DEBUG] 尝试获取读锁")
-	globalTodoList.mu.RLock()
-	items := make([]TodoItem, len(globalTodoList.Items))
-	copy(items, globalTodoList.Items)
-	globalTodoList.mu.RUnlock()
-	todoLogger.Printf("[DEBUG] 成功获取读锁并复制数据，items数量: %d", len(items))
+	items := todos.todoItems(MainTodoAgent)
+	todoLogger.Printf("[DEBUG] 复制数据完成，items数量: %d", len(items))
 
 	// 使用无锁版本的格式化函数
 	return formatTodoList(items)
@@ -323,8 +323,16 @@ func ListTodosFormatted() string {
 // fixJSONFormat 修复常见的JSON格式问题
 func fixJSONFormat(jsonStr string) string {
 	// 修复数字ID：将 "id": 123 转换为 "id": "123"
-	re := regexp.MustCompile(`"id"\s*:\s*(\d+(\.\d+)?)`)
-	fixed := re.ReplaceAllString(jsonStr, `"id": "$1"`)
+	re := regexp.MustCompile(`"(id|parent_id)"\s*:\s*(\d+(\.\d+)?)`)
+	fixed := re.ReplaceAllString(jsonStr, `"$1": "$2"`)
+
+	// 修复blocked_by中的数字ID：[1, 2] 转换为 ["1", "2"]
+	blockedRe := regexp.MustCompile(`"blocked_by"\s*:\s*\[[^\]]*\]`)
+	numRe := regexp.MustCompile(`(^|[\[,\s])(\d+(\.\d+)?)`)
+	fixed = blockedRe.ReplaceAllStringFunc(fixed, func(s string) string {
+		i := strings.Index(s, "[")
+		return s[:i] + numRe.ReplaceAllString(s[i:], `$1"$2"`)
+	})
 
 	// 确保priority字段有默认值
 	if !strings.Contains(fixed, "priority") {
@@ -0,0 +1,131 @@
+package function
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 待办事项之间的关系：parent_id 把任务拆成子任务，blocked_by 声明需要先完成的任务
+
+// validateTodoLinks 检查parent_id和blocked_by：引用的ID必须存在、不能形成循环，
+// 被阻塞（依赖的任务未完成）的任务不能处于进行中
+func validateTodoLinks(todos []TodoItem) error {
+	byID := todoIndex(todos)
+
+	for _, item := range todos {
+		if item.ParentID != "" {
+			if item.ParentID == item.ID {
+				return fmt.Errorf("任务 %s 的 parent_id 不能是自身", item.ID)
+			}
+			if _, ok := byID[item.ParentID]; !ok {
+				return fmt.Errorf("任务 %s 的 parent_id %s 不存在", item.ID, item.ParentID)
+			}
+		}
+		for _, dep := range item.BlockedBy {
+			if dep == item.ID {
+				return fmt.Errorf("任务 %s 不能被自身阻塞", item.ID)
+			}
+			if _, ok := byID[dep]; !ok {
+				return fmt.Errorf("任务 %s 的 blocked_by 中的 %s 不存在", item.ID, dep)
+			}
+		}
+	}
+
+	// 父任务链：从每个任务向上走，回到走过的任务即为循环
+	for _, item := range todos {
+		path := []string{item.ID}
+		seen := map[string]bool{item.ID: true}
+		for id := byID[item.ID].ParentID; id != ""; id = byID[id].ParentID {
+			path = append(path, id)
+			if seen[id] {
+				return fmt.Errorf("parent_id 存在循环: %s", strings.Join(path, " → "))
+			}
+			seen[id] = true
+		}
+	}
+
+	// 依赖关系：深度优先搜索，遇到仍在栈上的任务即为循环
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int, len(todos))
+	var stack []string
+	var visit func(id string) error
+	visit = func(id string) error {
+		state[id] = visiting
+		stack = append(stack, id)
+		for _, dep := range byID[id].BlockedBy {
+			switch state[dep] {
+			case visiting:
+				start := 0
+				for i, s := range stack {
+					if s == dep {
+						start = i
+					}
+				}
+				cycle := append(append([]string{}, stack[start:]...), dep)
+				return fmt.Errorf("blocked_by 存在循环: %s", strings.Join(cycle, " → "))
+			case unvisited:
+				if err := visit(dep); err != nil {
+					return err
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[id] = done
+		return nil
+	}
+	for _, item := range todos {
+		if state[item.ID] == unvisited {
+			if err := visit(item.ID); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, item := range todos {
+		if item.Status != "in_progress" {
+			continue
+		}
+		if open := openBlockers(item, byID); len(open) > 0 {
+			return fmt.Errorf("任务 %s 被 %s 阻塞，需先完成这些任务才能开始", item.ID, strings.Join(open, ", "))
+		}
+	}
+	return nil
+}
+
+// openBlockers 返回item依赖的任务中尚未完成的ID
+func openBlockers(item TodoItem, byID map[string]TodoItem) []string {
+	var open []string
+	for _, dep := range item.BlockedBy {
+		if blocker, ok := byID[dep]; ok && blocker.Status != "completed" {
+			open = append(open, dep)
+		}
+	}
+	return open
+}
+
+// todoIndex 按ID索引待办事项
+func todoIndex(todos []TodoItem) map[string]TodoItem {
+	byID := make(map[string]TodoItem, len(todos))
+	for _, item := range todos {
+		byID[item.ID] = item
+	}
+	return byID
+}
+
+// todoDepth 任务在父任务链中的层级，顶层为0；遇到循环或缺失的父任务时停止
+func todoDepth(item TodoItem, byID map[string]TodoItem) int {
+	depth := 0
+	seen := map[string]bool{item.ID: true}
+	for id := item.ParentID; id != "" && !seen[id]; id = byID[id].ParentID {
+		if _, ok := byID[id]; !ok {
+			break
+		}
+		seen[id] = true
+		depth++
+	}
+	return depth
+}
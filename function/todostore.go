@@ -0,0 +1,411 @@
+package function
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 待办事项按会话和代理隔离：主代理和每个Task子代理各有一份清单，
+// 每次TodoWrite后整个会话写入 log/todos/<会话ID>.json，恢复会话时从该文件读回。
+
+const (
+	todoDir             = "./log/todos"
+	MainTodoAgent       = "main" // 主代理的清单
+	maxTodoHistory      = 500    // 每个清单保留的状态变化条数
+	todoReportHistory   = 20     // /todos 中每个清单显示的最近变化条数
+	todoSessionIDLayout = "20060102_150405"
+)
+
+// TodoList 一个代理的待办事项列表及其状态变化历史
+type TodoList struct {
+	Agent     string       `json:"agent"`
+	Title     string       `json:"title,omitempty"` // 子代理的任务描述
+	Items     []TodoItem   `json:"items"`
+	History   []TodoChange `json:"history,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+// TodoChange 一次状态变化；From为空表示新增，To为空表示移除
+type TodoChange struct {
+	Time    time.Time `json:"time"`
+	ID      string    `json:"id"`
+	Content string    `json:"content"`
+	From    string    `json:"from,omitempty"`
+	To      string    `json:"to,omitempty"`
+	By      string    `json:"by,omitempty"` // "user"表示用户在面板中修改，为空表示代理自己写入
+}
+
+// todoSessionFile 会话文件的内容
+type todoSessionFile struct {
+	Session   string               `json:"session"`
+	UpdatedAt time.Time            `json:"updated_at"`
+	Lists     map[string]*TodoList `json:"lists"`
+}
+
+// todoStore 当前会话的所有清单
+type todoStore struct {
+	mu        sync.RWMutex
+	session   string
+	lists     map[string]*TodoList
+	nextAgent int                 // 下一个子代理的编号
+	userEdits map[string][]string // 用户修改后尚未告知代理的说明
+}
+
+var todos = &todoStore{lists: make(map[string]*TodoList), userEdits: make(map[string][]string)}
+
+// NewTodoSessionID 生成新的会话ID（与主日志文件名中的时间戳格式一致）
+func NewTodoSessionID() string {
+	return time.Now().Format(todoSessionIDLayout)
+}
+
+// StartTodoSession 切换到会话id；该会话已有保存的清单时读回，返回是否恢复了已有会话
+func StartTodoSession(id string) (bool, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || strings.Contains(id, "..") {
+		return false, fmt.Errorf("无效的会话ID: %q", id)
+	}
+
+	lists := make(map[string]*TodoList)
+	restored := false
+	data, err := os.ReadFile(todoSessionPath(id))
+	switch {
+	case err == nil:
+		var file todoSessionFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			return false, fmt.Errorf("会话文件 %s 格式错误: %v", todoSessionPath(id), err)
+		}
+		for agent, list := range file.Lists {
+			if list == nil {
+				continue
+			}
+			list.Agent = agent
+			lists[agent] = list
+		}
+		restored = true
+	case !os.IsNotExist(err):
+		return false, err
+	}
+
+	todos.mu.Lock()
+	defer todos.mu.Unlock()
+	todos.session = id
+	todos.lists = lists
+	todos.nextAgent = len(lists) + 1
+	todos.userEdits = make(map[string][]string)
+	todoLogger.Printf("待办事项会话: %s, 恢复=%v, 清单数: %d", id, restored, len(lists))
+	return restored, nil
+}
+
+// LatestTodoSession 返回最近保存过的会话ID
+func LatestTodoSession() (string, error) {
+	entries, err := os.ReadDir(todoDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("没有可恢复的会话")
+		}
+		return "", err
+	}
+	latest := ""
+	var latestTime time.Time
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		if latest == "" || info.ModTime().After(latestTime) {
+			latest = strings.TrimSuffix(e.Name(), ".json")
+			latestTime = info.ModTime()
+		}
+	}
+	if latest == "" {
+		return "", fmt.Errorf("没有可恢复的会话")
+	}
+	return latest, nil
+}
+
+// TodoSessionID 返回当前会话ID
+func TodoSessionID() string {
+	todos.mu.RLock()
+	defer todos.mu.RUnlock()
+	return todos.session
+}
+
+func todoSessionPath(id string) string {
+	return filepath.Join(todoDir, id+".json")
+}
+
+// NewTodoAgent 为一个Task子代理分配独立的清单，返回代理ID
+func NewTodoAgent(title string) string {
+	todos.mu.Lock()
+	defer todos.mu.Unlock()
+	for {
+		agent := fmt.Sprintf("task-%d", todos.nextAgent)
+		todos.nextAgent++
+		if _, exists := todos.lists[agent]; !exists {
+			now := time.Now()
+			todos.lists[agent] = &TodoList{Agent: agent, Title: title, CreatedAt: now, UpdatedAt: now}
+			return agent
+		}
+	}
+}
+
+// TodoReadFor 返回只读取agent清单的TodoRead，供子代理注册
+func TodoReadFor(agent string) func() string {
+	return func() string {
+		return todoReadFor(agent)
+	}
+}
+
+// TodoWriteFor 返回只写入agent清单的TodoWrite，供子代理注册
+func TodoWriteFor(agent string) func(string) string {
+	return func(requestJSON string) string {
+		return todoWriteFor(agent, requestJSON)
+	}
+}
+
+// TodoProgress 带计时的待办事项，开始和完成时间由状态变化历史推算
+type TodoProgress struct {
+	TodoItem
+	StartedAt  time.Time // 最近一次进入in_progress的时间，未开始时为零值
+	FinishedAt time.Time // 完成时间，未完成时为零值
+	Depth      int       // 在父任务链中的层级，顶层为0
+	Blocked    []string  // 依赖的任务中尚未完成的ID
+}
+
+// TodoSnapshot 返回agent当前的待办事项（带计时）和清单的最后更新时间
+func TodoSnapshot(agent string) ([]TodoProgress, time.Time) {
+	todos.mu.RLock()
+	defer todos.mu.RUnlock()
+	list, ok := todos.lists[agent]
+	if !ok {
+		return nil, time.Time{}
+	}
+
+	started := make(map[string]time.Time)
+	finished := make(map[string]time.Time)
+	for _, c := range list.History {
+		switch c.To {
+		case "in_progress":
+			started[c.ID] = c.Time
+			delete(finished, c.ID)
+		case "completed":
+			finished[c.ID] = c.Time
+		default:
+			// 回到pending或被移除：重新计时
+			delete(started, c.ID)
+			delete(finished, c.ID)
+		}
+	}
+
+	byID := todoIndex(list.Items)
+	items := make([]TodoProgress, len(list.Items))
+	for i, item := range list.Items {
+		items[i] = TodoProgress{
+			TodoItem:   item,
+			StartedAt:  started[item.ID],
+			FinishedAt: finished[item.ID],
+			Depth:      todoDepth(item, byID),
+			Blocked:    openBlockers(item, byID),
+		}
+	}
+	return items, list.UpdatedAt
+}
+
+// todoItems 复制agent当前的待办事项
+func (s *todoStore) todoItems(agent string) []TodoItem {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	list, ok := s.lists[agent]
+	if !ok {
+		return nil
+	}
+	items := make([]TodoItem, len(list.Items))
+	copy(items, list.Items)
+	return items
+}
+
+// EditTodos 用户修改agent的清单：edit在当前清单的副本上修改，校验通过后保存，
+// description在代理的下一轮告知代理
+func EditTodos(agent string, description string, edit func([]TodoItem) []TodoItem) error {
+	todos.mu.Lock()
+	defer todos.mu.Unlock()
+	var items []TodoItem
+	if list, ok := todos.lists[agent]; ok {
+		items = make([]TodoItem, len(list.Items))
+		copy(items, list.Items)
+	}
+	items = edit(items)
+	if err := validateTodos(items); err != nil {
+		return err
+	}
+	todoLogger.Printf("[%s] 用户修改: %s", agent, description)
+	todos.userEdits[agent] = append(todos.userEdits[agent], description)
+	return todos.replaceLocked(agent, items, "user")
+}
+
+// TakeUserTodoEdits 返回并清空agent清单上尚未告知代理的用户修改
+func TakeUserTodoEdits(agent string) []string {
+	todos.mu.Lock()
+	defer todos.mu.Unlock()
+	edits := todos.userEdits[agent]
+	delete(todos.userEdits, agent)
+	return edits
+}
+
+// replace 用items替换agent的清单，记录状态变化并保存会话
+func (s *todoStore) replace(agent string, items []TodoItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.replaceLocked(agent, items, "")
+}
+
+// replaceLocked 同replace，调用方持有锁；by记录修改者
+func (s *todoStore) replaceLocked(agent string, items []TodoItem, by string) error {
+	now := time.Now()
+	list, ok := s.lists[agent]
+	if !ok {
+		list = &TodoList{Agent: agent, CreatedAt: now}
+		s.lists[agent] = list
+	}
+	changes := diffTodos(list.Items, items, now)
+	for i := range changes {
+		changes[i].By = by
+	}
+	for _, c := range changes {
+		todoLogger.Printf("[%s] #%s %s: %s -> %s", agent, c.ID, c.Content, orDash(c.From), orDash(c.To))
+	}
+	list.History = append(list.History, changes...)
+	if len(list.History) > maxTodoHistory {
+		list.History = list.History[len(list.History)-maxTodoHistory:]
+	}
+	list.Items = items
+	list.UpdatedAt = now
+	return s.save()
+}
+
+// save 将整个会话写入磁盘（调用方持有锁）；未开始会话时新建一个
+func (s *todoStore) save() error {
+	if s.session == "" {
+		s.session = NewTodoSessionID()
+	}
+	file := todoSessionFile{Session: s.session, UpdatedAt: time.Now(), Lists: make(map[string]*TodoList)}
+	for agent, list := range s.lists {
+		// 未写入过的子代理清单不保存
+		if len(list.Items) == 0 && len(list.History) == 0 {
+			continue
+		}
+		file.Lists[agent] = list
+	}
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(todoDir, 0755); err != nil {
+		return err
+	}
+	path := todoSessionPath(s.session)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// diffTodos 按ID比较新旧清单，得到新增、状态变化和移除的事项
+func diffTodos(old, items []TodoItem, now time.Time) []TodoChange {
+	oldByID := make(map[string]TodoItem, len(old))
+	for _, item := range old {
+		oldByID[item.ID] = item
+	}
+	var changes []TodoChange
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		seen[item.ID] = true
+		prev, existed := oldByID[item.ID]
+		if existed && prev.Status == item.Status {
+			continue
+		}
+		change := TodoChange{Time: now, ID: item.ID, Content: item.Content, To: item.Status}
+		if existed {
+			change.From = prev.Status
+		}
+		changes = append(changes, change)
+	}
+	for _, item := range old {
+		if !seen[item.ID] {
+			changes = append(changes, TodoChange{Time: now, ID: item.ID, Content: item.Content, From: item.Status})
+		}
+	}
+	return changes
+}
+
+func orDash(status string) string {
+	if status == "" {
+		return "-"
+	}
+	return status
+}
+
+// TodoReport 返回当前会话所有清单及其最近的状态变化，供 /todos 命令显示；
+// fullHistory为true时显示完整历史
+func TodoReport(fullHistory bool) string {
+	todos.mu.RLock()
+	defer todos.mu.RUnlock()
+
+	var lists []*TodoList
+	for _, list := range todos.lists {
+		if len(list.Items) > 0 || len(list.History) > 0 {
+			lists = append(lists, list)
+		}
+	}
+	// 主代理在前，子代理按创建顺序
+	sort.Slice(lists, func(i, j int) bool {
+		if (lists[i].Agent == MainTodoAgent) != (lists[j].Agent == MainTodoAgent) {
+			return lists[i].Agent == MainTodoAgent
+		}
+		return lists[i].CreatedAt.Before(lists[j].CreatedAt)
+	})
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📝 会话 %s 的待办事项", todos.session))
+	if len(lists) == 0 {
+		sb.WriteString("\n\n暂无待办事项")
+		return sb.String()
+	}
+	for _, list := range lists {
+		if list.Agent == MainTodoAgent {
+			sb.WriteString("\n\n【主代理】\n")
+		} else {
+			sb.WriteString(fmt.Sprintf("\n\n【子代理 %s】%s\n", list.Agent, list.Title))
+		}
+		sb.WriteString(formatTodoList(list.Items))
+
+		history := list.History
+		if !fullHistory && len(history) > todoReportHistory {
+			sb.WriteString(fmt.Sprintf("\n\n状态变化（最近 %d 条，共 %d 条）:", todoReportHistory, len(history)))
+			history = history[len(history)-todoReportHistory:]
+		} else if len(history) > 0 {
+			sb.WriteString(fmt.Sprintf("\n\n状态变化（共 %d 条）:", len(history)))
+		}
+		for _, c := range history {
+			by := ""
+			if c.By == "user" {
+				by = "（用户）"
+			}
+			sb.WriteString(fmt.Sprintf("\n  %s #%s %s: %s → %s%s", c.Time.Format("01-02 15:04:05"), c.ID, c.Content, orDash(c.From), orDash(c.To), by))
+		}
+	}
+	if todos.session != "" {
+		sb.WriteString(fmt.Sprintf("\n\n保存于 %s", todoSessionPath(todos.session)))
+	}
+	return sb.String()
+}
@@ -1,7 +1,6 @@
 package function
 
 import (
-	"context"
 	"fmt"
 	"io"
 	"log"
@@ -10,7 +9,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/ccIisIaIcat/GoAgent/agent/ConversationManager"
 	"github.com/ccIisIaIcat/GoAgent/agent/general"
 )
 
@@ -48,6 +46,8 @@ func WebFetch(url string, prompt string) string {
 	client := &http.Client{
 		Timeout: 30 * time.Second,
 	}
+	// 使用主会话这一轮的context，用户按ESC取消时抓取和处理一并取消
+	ctx := subAgentContext()
 
 	if logger != nil {
 		logger.Printf("开始HTTP请求 - URL: %s", url)
@@ -55,7 +55,11 @@ func WebFetch(url string, prompt string) string {
 	
 	// 记录HTTP请求开始时间
 	httpStart := time.Now()
-	resp, err := client.Get(url)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Sprintf("Error: invalid url: %v", err)
+	}
+	resp, err := client.Do(httpReq)
 	httpDuration := time.Since(httpStart)
 	
 	if logger != nil {
@@ -105,34 +109,26 @@ func WebFetch(url string, prompt string) string {
 		}
 	}
 
-	config, err := general.LoadConfig("./LLMConfig.yaml")
+	cm, provider, model, err := newSubAgent(AgentTypeWebFetch, "", "You are a helpful AI assistant that processes web content.")
 	if err != nil {
 		if logger != nil {
-			logger.Printf("配置加载失败: %v", err)
+			logger.Printf("创建子代理失败: %v", err)
 		}
-		return fmt.Sprintf("Failed to load config: %v", err)
+		return fmt.Sprintf("Error: %v", err)
 	}
 
-	agentManager := general.NewAgentManager()
-	agentManager.AddProvider(config.ToProviderConfigs()[0])
-
-	cm := ConversationManager.NewConversationManager(agentManager)
-	cm.SetSystemPrompt("You are a helpful AI assistant that processes web content.")
-
 	fullPrompt := fmt.Sprintf("Content from %s:\n\n%s\n\nUser request: %s", url, content, prompt)
 	
 	if logger != nil {
 		logger.Printf("构建AI处理提示 - 完整提示长度: %d字符", len(fullPrompt))
-		logger.Printf("开始AI内容处理 - 模型: %s", config.AgentAPIKey.OpenAI.Model)
+		logger.Printf("开始AI内容处理 - 提供商: %s, 模型: %s", provider, model)
 	}
 
 	info_chan := make(chan general.Message, 10)
 	
-	ctx := context.Background()
-	
 	// 记录AI处理开始时间
 	aiStart := time.Now()
-	_, _, err, usage := cm.Chat(ctx, general.ProviderOpenAI, config.AgentAPIKey.OpenAI.Model, fullPrompt, []string{}, info_chan)
+	_, _, err, usage := cm.Chat(ctx, provider, model, fullPrompt, []string{}, info_chan)
 	aiDuration := time.Since(aiStart)
 
 	// 手动关闭通道，确保for range能够结束
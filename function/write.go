@@ -43,21 +43,22 @@ func Write(file_path string, content string) string {
 	// 2. 检查是否为现有文件，需要先使用Read工具
 	fileExists := false
 	var existingSize int64 = 0
+	format := DefaultTextFormat()
 	if fileInfo, err := os.Stat(file_path); err == nil {
 		fileExists = true
 		existingSize = fileInfo.Size()
 		
-		// 检查是否在最近5分钟内使用过Read工具读取此文件
-		if lastRead, readExists := readHistory[file_path]; readExists {
-			if time.Since(lastRead) > 5*time.Minute {
-				if logger != nil {
-					logger.Printf("警告: 文件 %s 上次读取时间超过5分钟，建议先使用Read工具", file_path)
-				}
-			}
-		} else {
+		// 检查文件已被Read且之后未被修改
+		if err := CheckFileFresh(file_path); err != nil {
 			if logger != nil {
-				logger.Printf("警告: 未检测到对现有文件 %s 的Read操作历史，建议先使用Read工具", file_path)
+				logger.Printf("Write函数返回 - 读取状态校验失败: %v", err)
 			}
+			return err.Error()
+		}
+
+		// 覆盖现有文件时保持其编码、BOM与换行符
+		if existingData, readErr := os.ReadFile(file_path); readErr == nil {
+			_, format = DecodeText(existingData)
 		}
 	}
 
@@ -109,7 +110,15 @@ func Write(file_path string, content string) string {
 	}
 
 	// 8. 大文件警告和备份
-	contentSize := len(content)
+	data := []byte(content)
+	if fileExists {
+		encoded, err := EncodeText(content, format)
+		if err != nil {
+			return fmt.Sprintf("Error: %v", err)
+		}
+		data = encoded
+	}
+	contentSize := len(data)
 	if fileExists && (existingSize > 50*1024 || int64(contentSize) > 50*1024) { // 50KB
 		// 创建备份
 		backupPath := file_path + ".backup." + time.Now().Format("20060102_150405")
@@ -123,7 +132,7 @@ func Write(file_path string, content string) string {
 	}
 
 	// 9. 写入文件
-	err = os.WriteFile(file_path, []byte(content), 0644)
+	err = os.WriteFile(file_path, data, 0644)
 	if err != nil {
 		errorMsg := fmt.Sprintf("Error writing file: %v", err)
 		if logger != nil {
@@ -131,6 +140,7 @@ func Write(file_path string, content string) string {
 		}
 		return errorMsg
 	}
+	RecordFileWrite(file_path)
 
 	// 10. 构建结果
 	operation := "created"
@@ -145,6 +155,9 @@ func Write(file_path string, content string) string {
 		result += fmt.Sprintf(". Size changed by %+d bytes (%d -> %d)", sizeDelta, existingSize, contentSize)
 	}
 
+	// 执行项目设置中的格式化/检查hook，诊断信息附加到结果中
+	result += RunPostEditHooks(file_path)
+
 	if logger != nil {
 		logger.Printf("Write函数返回 - 成功写入: %s", result)
 	}
@@ -174,22 +187,22 @@ func isDocumentationFile(fileName, ext string) bool {
 	return false
 }
 
+// Windows系统路径
+var winSensitivePaths = []string{
+	"c:\\windows\\", "c:\\program files\\", "c:\\program files (x86)\\",
+	"\\system32\\", "\\syswow64\\",
+}
+
+// Unix系统路径
+var unixSensitivePaths = []string{
+	"/etc/", "/bin/", "/sbin/", "/usr/bin/", "/usr/sbin/",
+	"/sys/", "/proc/", "/dev/", "/boot/",
+}
+
 // isSensitivePath 检查是否为敏感系统路径
 func isSensitivePath(filePath string) bool {
 	lowerPath := strings.ToLower(filePath)
 	
-	// Windows系统路径
-	winSensitivePaths := []string{
-		"c:\\windows\\", "c:\\program files\\", "c:\\program files (x86)\\",
-		"\\system32\\", "\\syswow64\\",
-	}
-	
-	// Unix系统路径
-	unixSensitivePaths := []string{
-		"/etc/", "/bin/", "/sbin/", "/usr/bin/", "/usr/sbin/",
-		"/sys/", "/proc/", "/dev/", "/boot/",
-	}
-	
 	for _, path := range winSensitivePaths {
 		if strings.Contains(lowerPath, path) {
 			return true
@@ -238,4 +251,4 @@ func checkWritePermissions(dir string) error {
 	// 清理临时文件
 	os.Remove(tempFile)
 	return nil
-}
\ No newline at end of file
+}
\ No newline at end of file
@@ -0,0 +1,418 @@
+// Package index 工作区的本地代码检索索引：按固定行数把文件切成块，对块建立倒排索引并用BM25排序，
+// 启动时在后台构建并持久化到 .lukatin/cache，之后由文件系统监听增量更新。不依赖网络或向量服务。
+package index
+
+import (
+	"bytes"
+	"encoding/gob"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	chunkLines     = 30         // 每个块的行数
+	maxFileSize    = 512 * 1024 // 超过该大小的文件不建索引
+	maxFiles       = 50000      // 最多索引的文件数
+	binarySniffLen = 8000       // 检查前多少字节判断二进制文件
+	pathWeight     = 2          // 文件路径中的词在每个块中计入的词频
+	maxHitsPerFile = 3          // 同一文件最多返回的块数
+
+	bm25K1 = 1.2
+	bm25B  = 0.75
+
+	snapshotVersion = 1
+)
+
+// CacheDir 索引文件所在目录（相对项目根目录）
+const CacheDir = ".lukatin/cache"
+
+const cacheFile = "index.gob"
+
+// fileEntry 一个已索引的文件
+type fileEntry struct {
+	ModTime int64
+	Size    int64
+	Chunks  []int
+}
+
+// chunk 文件中连续的若干行
+type chunk struct {
+	File  string // 相对项目根目录的斜杠路径
+	Start int    // 起始行号（从1开始）
+	End   int
+	Len   int            // 词数
+	Terms map[string]int // 词频
+}
+
+// snapshot 持久化到磁盘的内容，倒排表在加载后由块重建
+type snapshot struct {
+	Version int
+	Root    string
+	NextID  int
+	Files   map[string]*fileEntry
+	Chunks  map[int]*chunk
+}
+
+// Index 内存中的倒排索引，所有方法可并发调用
+type Index struct {
+	Root string
+
+	mu       sync.RWMutex
+	files    map[string]*fileEntry
+	chunks   map[int]*chunk
+	postings map[string]map[int]int // 词 → 块ID → 词频
+	totalLen int
+	nextID   int
+	dirty    bool
+
+	state indexState
+}
+
+// New 创建root（绝对路径）的空索引
+func New(root string) *Index {
+	return &Index{
+		Root:     root,
+		files:    make(map[string]*fileEntry),
+		chunks:   make(map[int]*chunk),
+		postings: make(map[string]map[int]int),
+	}
+}
+
+// Stats 索引的文件数和块数
+func (idx *Index) Stats() (files, chunks int) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.files), len(idx.chunks)
+}
+
+// upToDate 判断rel的索引是否与磁盘上的修改时间和大小一致
+func (idx *Index) upToDate(rel string, info os.FileInfo) bool {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	entry, ok := idx.files[rel]
+	return ok && entry.ModTime == info.ModTime().UnixNano() && entry.Size == info.Size()
+}
+
+// indexFile 读取并（重新）索引相对路径rel；文件过大、是二进制或已被删除时移除其旧索引
+func (idx *Index) indexFile(rel string) {
+	abs := filepath.Join(idx.Root, filepath.FromSlash(rel))
+	info, err := os.Stat(abs)
+	if err != nil || info.IsDir() || info.Size() > maxFileSize {
+		idx.remove(rel)
+		return
+	}
+	if idx.upToDate(rel, info) {
+		return
+	}
+	data, err := os.ReadFile(abs)
+	if err != nil || isBinary(data) {
+		idx.remove(rel)
+		return
+	}
+
+	chunks := splitChunks(rel, string(data))
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if _, exists := idx.files[rel]; !exists && len(idx.files) >= maxFiles {
+		return
+	}
+	idx.removeLocked(rel)
+	entry := &fileEntry{ModTime: info.ModTime().UnixNano(), Size: info.Size()}
+	for _, c := range chunks {
+		id := idx.nextID
+		idx.nextID++
+		idx.addChunkLocked(id, c)
+		entry.Chunks = append(entry.Chunks, id)
+	}
+	idx.files[rel] = entry
+	idx.dirty = true
+}
+
+// remove 删除rel的索引
+func (idx *Index) remove(rel string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.removeLocked(rel)
+}
+
+// removePrefix 删除目录dir（相对路径）下所有文件的索引
+func (idx *Index) removePrefix(dir string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	prefix := strings.TrimSuffix(dir, "/") + "/"
+	for rel := range idx.files {
+		if strings.HasPrefix(rel, prefix) {
+			idx.removeLocked(rel)
+		}
+	}
+}
+
+func (idx *Index) removeLocked(rel string) {
+	entry, ok := idx.files[rel]
+	if !ok {
+		return
+	}
+	for _, id := range entry.Chunks {
+		c := idx.chunks[id]
+		if c == nil {
+			continue
+		}
+		for term := range c.Terms {
+			if posting := idx.postings[term]; posting != nil {
+				delete(posting, id)
+				if len(posting) == 0 {
+					delete(idx.postings, term)
+				}
+			}
+		}
+		idx.totalLen -= c.Len
+		delete(idx.chunks, id)
+	}
+	delete(idx.files, rel)
+	idx.dirty = true
+}
+
+func (idx *Index) addChunkLocked(id int, c *chunk) {
+	idx.chunks[id] = c
+	idx.totalLen += c.Len
+	for term, tf := range c.Terms {
+		posting := idx.postings[term]
+		if posting == nil {
+			posting = make(map[int]int)
+			idx.postings[term] = posting
+		}
+		posting[id] = tf
+	}
+}
+
+// indexedFiles 返回所有已索引文件的相对路径
+func (idx *Index) indexedFiles() []string {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	files := make([]string, 0, len(idx.files))
+	for rel := range idx.files {
+		files = append(files, rel)
+	}
+	return files
+}
+
+// splitChunks 把文件内容按chunkLines切块，每个块额外计入文件路径中的词
+func splitChunks(rel, content string) []*chunk {
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	pathTerms := Tokenize(rel)
+
+	var chunks []*chunk
+	for start := 0; start < len(lines) || start == 0; start += chunkLines {
+		end := start + chunkLines
+		if end > len(lines) {
+			end = len(lines)
+		}
+		c := &chunk{File: rel, Start: start + 1, End: end, Terms: make(map[string]int)}
+		for _, line := range lines[start:end] {
+			for _, term := range Tokenize(line) {
+				c.Terms[term]++
+				c.Len++
+			}
+		}
+		for _, term := range pathTerms {
+			c.Terms[term] += pathWeight
+			c.Len += pathWeight
+		}
+		chunks = append(chunks, c)
+		if end == len(lines) {
+			break
+		}
+	}
+	return chunks
+}
+
+// isBinary 前binarySniffLen字节中包含NUL即视为二进制文件
+func isBinary(data []byte) bool {
+	if len(data) > binarySniffLen {
+		data = data[:binarySniffLen]
+	}
+	return bytes.IndexByte(data, 0) >= 0
+}
+
+// Hit 一个命中的块
+type Hit struct {
+	File  string
+	Start int
+	End   int
+	Score float64
+	Terms []string // 块中出现的查询词
+}
+
+// Search 用BM25对块排序，返回得分最高的limit个；pathPrefix非空时只返回该目录下（或该文件）的结果。
+// 同一文件最多返回 maxHitsPerFile 个块，避免一个大文件占满结果。
+func (idx *Index) Search(query, pathPrefix string, limit int) (hits []Hit, queryTerms []string) {
+	queryTerms = uniqueTerms(Tokenize(query))
+	if len(queryTerms) == 0 || limit <= 0 {
+		return nil, queryTerms
+	}
+	pathPrefix = strings.Trim(filepath.ToSlash(pathPrefix), "/")
+	if pathPrefix == "." {
+		pathPrefix = ""
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	n := float64(len(idx.chunks))
+	if n == 0 {
+		return nil, queryTerms
+	}
+	avgLen := float64(idx.totalLen) / n
+
+	scores := make(map[int]float64)
+	matched := make(map[int][]string)
+	for _, term := range queryTerms {
+		posting := idx.postings[term]
+		if len(posting) == 0 {
+			continue
+		}
+		df := float64(len(posting))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for id, tf := range posting {
+			c := idx.chunks[id]
+			if pathPrefix != "" && c.File != pathPrefix && !strings.HasPrefix(c.File, pathPrefix+"/") {
+				continue
+			}
+			f := float64(tf)
+			scores[id] += idf * f * (bm25K1 + 1) / (f + bm25K1*(1-bm25B+bm25B*float64(c.Len)/avgLen))
+			matched[id] = append(matched[id], term)
+		}
+	}
+
+	// 命中的查询词越多越靠前：按覆盖率放大得分
+	for id, score := range scores {
+		coverage := float64(len(matched[id])) / float64(len(queryTerms))
+		scores[id] = score * (0.5 + coverage)
+	}
+
+	ids := make([]int, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if scores[ids[i]] != scores[ids[j]] {
+			return scores[ids[i]] > scores[ids[j]]
+		}
+		a, b := idx.chunks[ids[i]], idx.chunks[ids[j]]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Start < b.Start
+	})
+
+	perFile := make(map[string]int)
+	for _, id := range ids {
+		c := idx.chunks[id]
+		if perFile[c.File] >= maxHitsPerFile {
+			continue
+		}
+		perFile[c.File]++
+		hits = append(hits, Hit{File: c.File, Start: c.Start, End: c.End, Score: scores[id], Terms: matched[id]})
+		if len(hits) >= limit {
+			break
+		}
+	}
+	return hits, queryTerms
+}
+
+func uniqueTerms(terms []string) []string {
+	seen := make(map[string]bool)
+	var unique []string
+	for _, t := range terms {
+		if !seen[t] {
+			seen[t] = true
+			unique = append(unique, t)
+		}
+	}
+	return unique
+}
+
+// IDF 返回词的逆文档频率，用于给片段中的行打分
+func (idx *Index) IDF(term string) float64 {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	n := float64(len(idx.chunks))
+	df := float64(len(idx.postings[term]))
+	return math.Log(1 + (n-df+0.5)/(df+0.5))
+}
+
+// cachePath 索引文件的绝对路径
+func (idx *Index) cachePath() string {
+	return filepath.Join(idx.Root, filepath.FromSlash(CacheDir), cacheFile)
+}
+
+// Save 把索引写入 .lukatin/cache/index.gob（先写临时文件再改名），没有变化时不写
+func (idx *Index) Save() error {
+	idx.mu.Lock()
+	if !idx.dirty {
+		idx.mu.Unlock()
+		return nil
+	}
+	snap := snapshot{Version: snapshotVersion, Root: idx.Root, NextID: idx.nextID, Files: idx.files, Chunks: idx.chunks}
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(&snap)
+	if err == nil {
+		idx.dirty = false
+	}
+	idx.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(idx.cachePath())
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	// 缓存目录不应被提交
+	gitignore := filepath.Join(dir, ".gitignore")
+	if _, err := os.Stat(gitignore); os.IsNotExist(err) {
+		os.WriteFile(gitignore, []byte("*\n"), 0644)
+	}
+	tmp := idx.cachePath() + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, idx.cachePath())
+}
+
+// Load 读取磁盘上的索引；文件不存在、版本不同或属于其他目录时返回错误，索引保持为空
+func (idx *Index) Load() error {
+	data, err := os.ReadFile(idx.cachePath())
+	if err != nil {
+		return err
+	}
+	var snap snapshot
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&snap); err != nil {
+		return err
+	}
+	if snap.Version != snapshotVersion || snap.Root != idx.Root {
+		return os.ErrNotExist
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.files = snap.Files
+	idx.chunks = make(map[int]*chunk, len(snap.Chunks))
+	idx.postings = make(map[string]map[int]int)
+	idx.totalLen = 0
+	idx.nextID = snap.NextID
+	for id, c := range snap.Chunks {
+		idx.addChunkLocked(id, c)
+	}
+	if idx.files == nil {
+		idx.files = make(map[string]*fileEntry)
+	}
+	return nil
+}
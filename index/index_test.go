@@ -0,0 +1,231 @@
+package index
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestIndex 在临时目录中写入files并逐个建立索引
+func newTestIndex(t *testing.T, files map[string]string) *Index {
+	t.Helper()
+	root := t.TempDir()
+	idx := New(root)
+	for rel, content := range files {
+		writeTestFile(t, root, rel, content)
+		idx.indexFile(rel)
+	}
+	return idx
+}
+
+func writeTestFile(t *testing.T, root, rel, content string) {
+	t.Helper()
+	path := filepath.Join(root, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// checkConsistency 检查totalLen、倒排表与块、文件之间的簿记是否一致
+func checkConsistency(t *testing.T, idx *Index) {
+	t.Helper()
+	total := 0
+	for id, c := range idx.chunks {
+		total += c.Len
+		for term, tf := range c.Terms {
+			if idx.postings[term][id] != tf {
+				t.Errorf("posting %q for chunk %d = %d, want %d", term, id, idx.postings[term][id], tf)
+			}
+		}
+	}
+	if idx.totalLen != total {
+		t.Errorf("totalLen = %d, want %d", idx.totalLen, total)
+	}
+	for term, posting := range idx.postings {
+		if len(posting) == 0 {
+			t.Errorf("empty posting list left for %q", term)
+		}
+		for id := range posting {
+			if _, ok := idx.chunks[id]; !ok {
+				t.Errorf("posting %q refers to removed chunk %d", term, id)
+			}
+		}
+	}
+	owned := 0
+	for rel, entry := range idx.files {
+		for _, id := range entry.Chunks {
+			if c, ok := idx.chunks[id]; !ok || c.File != rel {
+				t.Errorf("file %s refers to missing chunk %d", rel, id)
+			}
+			owned++
+		}
+	}
+	if owned != len(idx.chunks) {
+		t.Errorf("%d chunks belong to files, index has %d", owned, len(idx.chunks))
+	}
+}
+
+func hitFiles(hits []Hit) []string {
+	var files []string
+	for _, h := range hits {
+		files = append(files, h.File)
+	}
+	return files
+}
+
+func TestSearchRanking(t *testing.T) {
+	idx := newTestIndex(t, map[string]string{
+		"auth/token.go":   "func validateToken(token string) error {\n\t// validate the token signature\n\treturn checkToken(token)\n}\n",
+		"auth/session.go": "func newSession() *Session {\n\t// token is created later\n\treturn &Session{}\n}\n",
+		"util/strings.go": "func reverse(s string) string {\n\treturn s\n}\n",
+	})
+	checkConsistency(t, idx)
+
+	hits, terms := idx.Search("where is the token validated", "", 10)
+	if want := []string{"token", "valid"}; strings.Join(terms, ",") != strings.Join(want, ",") {
+		t.Errorf("query terms = %q, want %q", terms, want)
+	}
+	if got := hitFiles(hits); len(got) != 2 || got[0] != "auth/token.go" || got[1] != "auth/session.go" {
+		t.Fatalf("hits = %q, want auth/token.go ranked before auth/session.go", got)
+	}
+	if hits[0].Score <= hits[1].Score {
+		t.Errorf("scores %v, %v are not in descending order", hits[0].Score, hits[1].Score)
+	}
+
+	// pathPrefix 只返回该目录下的结果
+	hits, _ = idx.Search("token", "util", 10)
+	if len(hits) != 0 {
+		t.Errorf("hits under util = %q, want none", hitFiles(hits))
+	}
+	hits, _ = idx.Search("token", "auth/session.go", 10)
+	if got := hitFiles(hits); len(got) != 1 || got[0] != "auth/session.go" {
+		t.Errorf("hits for file prefix = %q, want only auth/session.go", got)
+	}
+
+	// 只有停用词的查询没有结果
+	if hits, terms := idx.Search("where is the", "", 10); hits != nil || len(terms) != 0 {
+		t.Errorf("stopword query returned hits %q, terms %q", hitFiles(hits), terms)
+	}
+}
+
+func TestSearchPerFileCap(t *testing.T) {
+	// big.go 有10个块都包含needle，small.go只有一个块
+	var big strings.Builder
+	for i := 0; i < 10*chunkLines; i++ {
+		fmt.Fprintf(&big, "needle line %d\n", i)
+	}
+	idx := newTestIndex(t, map[string]string{
+		"big.go":   big.String(),
+		"small.go": "one needle here\n",
+	})
+
+	hits, _ := idx.Search("needle", "", 20)
+	perFile := map[string]int{}
+	for _, h := range hits {
+		perFile[h.File]++
+	}
+	if perFile["big.go"] != maxHitsPerFile {
+		t.Errorf("big.go returned %d chunks, want %d", perFile["big.go"], maxHitsPerFile)
+	}
+	if perFile["small.go"] != 1 {
+		t.Errorf("small.go returned %d chunks, want 1", perFile["small.go"])
+	}
+
+	if hits, _ := idx.Search("needle", "", 2); len(hits) != 2 {
+		t.Errorf("limit 2 returned %d hits", len(hits))
+	}
+}
+
+func TestReindexAndRemove(t *testing.T) {
+	idx := newTestIndex(t, map[string]string{
+		"a.go": "alpha beta\n",
+		"b.go": "gamma delta\n",
+	})
+	checkConsistency(t, idx)
+
+	// 修改后重新索引：旧词的倒排项被清理，新词可以检索
+	writeTestFile(t, idx.Root, "a.go", "epsilon zeta theta\n")
+	idx.indexFile("a.go")
+	checkConsistency(t, idx)
+	if _, ok := idx.postings["alpha"]; ok {
+		t.Error("postings for alpha were not removed after re-indexing")
+	}
+	if hits, _ := idx.Search("epsilon", "", 5); len(hits) != 1 || hits[0].File != "a.go" {
+		t.Errorf("search after re-index = %q, want a.go", hitFiles(hits))
+	}
+
+	// 文件被删除
+	os.Remove(filepath.Join(idx.Root, "a.go"))
+	idx.indexFile("a.go")
+	checkConsistency(t, idx)
+	if files, _ := idx.Stats(); files != 1 {
+		t.Errorf("indexed files = %d, want 1", files)
+	}
+
+	// 删除目录下的所有文件
+	writeTestFile(t, idx.Root, "sub/c.go", "iota kappa\n")
+	idx.indexFile("sub/c.go")
+	idx.removePrefix("sub")
+	idx.remove("b.go")
+	checkConsistency(t, idx)
+	if files, chunks := idx.Stats(); files != 0 || chunks != 0 || idx.totalLen != 0 || len(idx.postings) != 0 {
+		t.Errorf("after removing everything: files=%d chunks=%d totalLen=%d postings=%d", files, chunks, idx.totalLen, len(idx.postings))
+	}
+}
+
+func TestSaveLoadRoundTrip(t *testing.T) {
+	idx := newTestIndex(t, map[string]string{
+		"auth/token.go":   "func validateToken(token string) error { return nil }\n",
+		"auth/session.go": "func newSession() { token := 1 }\n",
+	})
+	if err := idx.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(idx.Root, CacheDir, ".gitignore")); err != nil {
+		t.Errorf("cache .gitignore was not written: %v", err)
+	}
+
+	loaded := New(idx.Root)
+	if err := loaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	checkConsistency(t, loaded)
+	if loaded.totalLen != idx.totalLen || loaded.nextID != idx.nextID {
+		t.Errorf("loaded totalLen=%d nextID=%d, want %d %d", loaded.totalLen, loaded.nextID, idx.totalLen, idx.nextID)
+	}
+	want, _ := idx.Search("validate token", "", 10)
+	got, _ := loaded.Search("validate token", "", 10)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("search after load = %v, want %v", got, want)
+	}
+
+	// 加载后未修改的文件不会重新索引
+	info, err := os.Stat(filepath.Join(idx.Root, "auth", "token.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.upToDate("auth/token.go", info) {
+		t.Error("loaded index does not consider auth/token.go up to date")
+	}
+
+	// 属于其他目录的缓存不会被加载
+	other := New(t.TempDir())
+	if err := os.MkdirAll(filepath.Dir(other.cachePath()), 0755); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(idx.cachePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(other.cachePath(), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Load(); err == nil {
+		t.Error("Load accepted a cache written for a different root")
+	}
+}
@@ -0,0 +1,138 @@
+package index
+
+import (
+	"strings"
+	"unicode"
+)
+
+// 分词：标识符按 camelCase / snake_case 拆分，同时保留完整的小写标识符；
+// 英文词做轻量的后缀归一（validation/validate/validating → valid），中文按单字和相邻双字切分。
+
+const (
+	minTokenLen = 2
+	maxTokenLen = 64
+)
+
+// stopwords 查询和文档中都丢弃的常见词，自然语言查询里的 where/is/the 等不参与打分
+var stopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "by": true,
+	"can": true, "do": true, "does": true, "for": true, "from": true, "how": true, "if": true,
+	"in": true, "into": true, "is": true, "it": true, "its": true, "of": true, "on": true, "or": true,
+	"that": true, "the": true, "this": true, "to": true, "was": true, "we": true, "what": true,
+	"when": true, "where": true, "which": true, "who": true, "why": true, "with": true, "you": true,
+	"there": true, "any": true, "all": true,
+	"的": true, "了": true, "在": true, "是": true, "和": true, "哪": true, "里": true,
+}
+
+// suffixes 按顺序尝试的后缀，剩余部分至少保留3个字符
+var suffixes = []struct{ suffix, replace string }{
+	{"ations", ""}, {"ation", ""}, {"ating", ""}, {"ates", ""}, {"ated", ""}, {"ate", ""},
+	{"ings", ""}, {"ing", ""}, {"ers", ""}, {"er", ""}, {"ies", "y"}, {"es", ""}, {"ed", ""}, {"s", ""},
+}
+
+// stem 去掉常见的英文词尾
+func stem(word string) string {
+	for _, s := range suffixes {
+		if strings.HasSuffix(word, s.suffix) && len(word)-len(s.suffix) >= 3 {
+			rest := word[:len(word)-len(s.suffix)]
+			if s.suffix == "s" && strings.HasSuffix(word, "ss") {
+				return word
+			}
+			// es 只在 s/x/z/ch/sh 之后去掉（matches → match），其余情况只去掉 s（files → file）
+			if s.suffix == "es" && !strings.HasSuffix(rest, "s") && !strings.HasSuffix(rest, "x") &&
+				!strings.HasSuffix(rest, "z") && !strings.HasSuffix(rest, "ch") && !strings.HasSuffix(rest, "sh") {
+				return word[:len(word)-1]
+			}
+			return rest + s.replace
+		}
+	}
+	return word
+}
+
+// Tokenize 把文本切分为索引词，同一个词可能出现多次（用于统计词频）
+func Tokenize(text string) []string {
+	var tokens []string
+	emit := func(word string) {
+		if len(word) < minTokenLen || len(word) > maxTokenLen || stopwords[word] {
+			return
+		}
+		tokens = append(tokens, stem(word))
+	}
+
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case isWordRune(r):
+			j := i
+			for j < len(runes) && isWordRune(runes[j]) {
+				j++
+			}
+			parts := splitIdentifier(runes[i:j])
+			if len(parts) > 1 {
+				emit(strings.ToLower(strings.Trim(string(runes[i:j]), "_")))
+			}
+			for _, part := range parts {
+				emit(part)
+			}
+			i = j
+		case unicode.Is(unicode.Han, r):
+			j := i
+			for j < len(runes) && unicode.Is(unicode.Han, runes[j]) {
+				j++
+			}
+			for k := i; k < j; k++ {
+				if !stopwords[string(runes[k])] {
+					tokens = append(tokens, string(runes[k]))
+				}
+				if k+1 < j {
+					tokens = append(tokens, string(runes[k:k+2]))
+				}
+			}
+			i = j
+		default:
+			i++
+		}
+	}
+	return tokens
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || (r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)))
+}
+
+// splitIdentifier 按下划线、大小写边界和字母数字边界拆分标识符，返回小写的各部分
+// 例如 HTTPServer → http, server；todo_write2 → todo, write, 2
+func splitIdentifier(word []rune) []string {
+	var parts []string
+	start := -1
+	flush := func(end int) {
+		if start >= 0 && end > start {
+			parts = append(parts, strings.ToLower(string(word[start:end])))
+		}
+		start = -1
+	}
+	for i, r := range word {
+		if r == '_' {
+			flush(i)
+			continue
+		}
+		if start < 0 {
+			start = i
+			continue
+		}
+		prev := word[i-1]
+		switch {
+		case unicode.IsUpper(r) && unicode.IsLower(prev),
+			unicode.IsDigit(r) != unicode.IsDigit(prev):
+			flush(i)
+			start = i
+		case unicode.IsUpper(r) && unicode.IsUpper(prev) && i+1 < len(word) && unicode.IsLower(word[i+1]):
+			// 连续大写后接小写：最后一个大写字母属于下一个词
+			flush(i)
+			start = i
+		}
+	}
+	flush(len(word))
+	return parts
+}
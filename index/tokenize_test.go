@@ -0,0 +1,68 @@
+package index
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStem(t *testing.T) {
+	tests := []struct {
+		word, want string
+	}{
+		{"validation", "valid"},
+		{"validate", "valid"},
+		{"validating", "valid"},
+		{"validated", "valid"},
+		{"validates", "valid"},
+		{"matches", "match"},
+		{"files", "file"},
+		{"queries", "query"},
+		{"class", "class"}, // ss 结尾不去掉 s
+		{"go", "go"},       // 剩余部分不足3个字符
+		{"uses", "use"},
+	}
+	for _, tt := range tests {
+		if got := stem(tt.word); got != tt.want {
+			t.Errorf("stem(%q) = %q, want %q", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestSplitIdentifier(t *testing.T) {
+	tests := []struct {
+		word string
+		want []string
+	}{
+		{"HTTPServer", []string{"http", "server"}},
+		{"todo_write2", []string{"todo", "write", "2"}},
+		{"parseHTTPRequest", []string{"parse", "http", "request"}},
+		{"getID", []string{"get", "id"}},
+		{"_private", []string{"private"}},
+		{"snake_case_name", []string{"snake", "case", "name"}},
+		{"x", []string{"x"}},
+	}
+	for _, tt := range tests {
+		if got := splitIdentifier([]rune(tt.word)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitIdentifier(%q) = %q, want %q", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		// 拆分后的标识符同时保留完整的小写形式，停用词被丢弃
+		{"Where is the HTTPServer?", []string{"httpserv", "http", "serv"}},
+		{"validation validate validating", []string{"valid", "valid", "valid"}},
+		{"todo_write2(x)", []string{"todo_write2", "todo", "write"}},
+		// 中文按单字和相邻双字切分
+		{"待办事项", []string{"待", "待办", "办", "办事", "事", "事项", "项"}},
+	}
+	for _, tt := range tests {
+		if got := Tokenize(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Tokenize(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
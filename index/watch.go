@@ -0,0 +1,286 @@
+package index
+
+import (
+	"io/fs"
+	"log"
+	"lukatincode/walker"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// 后台维护：启动时读取磁盘上的索引并与工作区对账（按修改时间和大小只重建变化的文件），
+// 之后用fsnotify监听每个未被忽略的目录，事件合并后增量更新；无法监听时退回定时全量对账。
+
+const (
+	eventDebounce = 300 * time.Millisecond
+	saveInterval  = 30 * time.Second
+	pollInterval  = time.Minute
+)
+
+// skipDirs 项目根目录下不建索引的目录：程序自身的运行日志持续写入，对检索没有意义
+var skipDirs = map[string]bool{"log": true}
+
+// indexState 后台构建的进度
+type indexState struct {
+	mu       sync.Mutex
+	building bool
+	ready    bool // 至少完成过一次全量对账
+	scanned  int  // 当前这次对账已处理的文件数
+}
+
+// Status 返回索引是否完成过全量构建、是否正在构建以及本次构建已处理的文件数
+func (idx *Index) Status() (ready, building bool, scanned int) {
+	idx.state.mu.Lock()
+	defer idx.state.mu.Unlock()
+	return idx.state.ready, idx.state.building, idx.state.scanned
+}
+
+func (idx *Index) setBuilding(building bool) {
+	idx.state.mu.Lock()
+	defer idx.state.mu.Unlock()
+	idx.state.building = building
+	if building {
+		idx.state.scanned = 0
+	} else {
+		idx.state.ready = true
+	}
+}
+
+func (idx *Index) addScanned(n int) {
+	idx.state.mu.Lock()
+	idx.state.scanned += n
+	idx.state.mu.Unlock()
+}
+
+// Start 在后台加载、构建并持续更新索引，立即返回
+func (idx *Index) Start(logger *log.Logger) {
+	go idx.run(logger)
+}
+
+// watchSet 已监听的目录（绝对路径），只在后台goroutine中访问
+type watchSet struct {
+	watcher *fsnotify.Watcher
+	dirs    map[string]bool
+	failed  bool // 添加监听失败（如超出inotify上限），改为定时对账
+}
+
+func (idx *Index) run(logger *log.Logger) {
+	start := time.Now()
+	if err := idx.Load(); err == nil {
+		files, chunks := idx.Stats()
+		logger.Printf("代码索引: 从缓存加载 %d 个文件, %d 个块", files, chunks)
+	}
+
+	ws := &watchSet{dirs: make(map[string]bool)}
+	if watcher, err := fsnotify.NewWatcher(); err != nil {
+		logger.Printf("代码索引: 创建文件监听失败，改为每%v对账一次: %v", pollInterval, err)
+	} else {
+		ws.watcher = watcher
+	}
+
+	idx.scan(ws)
+	files, chunks := idx.Stats()
+	logger.Printf("代码索引: 构建完成 %d 个文件, %d 个块, 耗时 %v", files, chunks, time.Since(start))
+	if err := idx.Save(); err != nil {
+		logger.Printf("代码索引: 保存失败: %v", err)
+	}
+
+	if ws.failed && ws.watcher != nil {
+		logger.Printf("代码索引: 部分目录无法监听，改为每%v对账一次", pollInterval)
+		ws.watcher.Close()
+		ws.watcher = nil
+	}
+	if ws.watcher == nil {
+		idx.poll(logger)
+		return
+	}
+	idx.watch(ws, logger)
+}
+
+// poll 定时全量对账
+func (idx *Index) poll(logger *log.Logger) {
+	ws := &watchSet{dirs: make(map[string]bool)}
+	for range time.Tick(pollInterval) {
+		idx.scan(ws)
+		if err := idx.Save(); err != nil {
+			logger.Printf("代码索引: 保存失败: %v", err)
+		}
+	}
+}
+
+// scan 遍历整个工作区：索引新增和变化的文件、删除已不存在的文件，并为每个目录添加监听
+func (idx *Index) scan(ws *watchSet) {
+	idx.setBuilding(true)
+	defer idx.setBuilding(false)
+
+	seen := make(map[string]bool)
+	idx.walkDir(ws, idx.Root, seen)
+	for _, rel := range idx.indexedFiles() {
+		if !seen[rel] {
+			idx.remove(rel)
+		}
+	}
+}
+
+// walkDir 索引dir（绝对路径）下所有未被忽略的文件，文件的读取和分词并行执行
+func (idx *Index) walkDir(ws *watchSet, dir string, seen map[string]bool) {
+	paths := make(chan string, 256)
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rel := range paths {
+				idx.indexFile(rel)
+				idx.addScanned(1)
+			}
+		}()
+	}
+
+	files, _ := idx.Stats()
+	walker.Walk(dir, walker.Options{}, func(path string, d fs.DirEntry) error {
+		rel := idx.rel(path)
+		if d.IsDir() {
+			if skipDirs[rel] {
+				return filepath.SkipDir
+			}
+			ws.add(path)
+			return nil
+		}
+		if seen != nil {
+			seen[rel] = true
+		}
+		if files >= maxFiles && !idx.has(rel) {
+			return nil
+		}
+		paths <- rel
+		return nil
+	})
+	close(paths)
+	wg.Wait()
+}
+
+func (ws *watchSet) add(dir string) {
+	if ws.dirs[dir] {
+		return
+	}
+	ws.dirs[dir] = true
+	if ws.watcher != nil && !ws.failed {
+		if err := ws.watcher.Add(dir); err != nil {
+			ws.failed = true
+		}
+	}
+}
+
+// forget 删除dir及其子目录的监听记录（目录被删除时fsnotify会自动移除监听）
+func (ws *watchSet) forget(dir string) {
+	prefix := dir + string(filepath.Separator)
+	for d := range ws.dirs {
+		if d == dir || strings.HasPrefix(d, prefix) {
+			delete(ws.dirs, d)
+		}
+	}
+}
+
+func (idx *Index) has(rel string) bool {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	_, ok := idx.files[rel]
+	return ok
+}
+
+// rel 绝对路径转为相对根目录的斜杠路径
+func (idx *Index) rel(path string) string {
+	rel, err := filepath.Rel(idx.Root, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// watch 处理文件系统事件：合并eventDebounce内的事件后统一更新，定时保存
+func (idx *Index) watch(ws *watchSet, logger *log.Logger) {
+	defer ws.watcher.Close()
+	pending := make(map[string]bool)
+	rescan := false
+	debounce := time.NewTimer(time.Hour)
+	debounce.Stop()
+	saveTicker := time.NewTicker(saveInterval)
+	defer saveTicker.Stop()
+
+	for {
+		select {
+		case ev, ok := <-ws.watcher.Events:
+			if !ok {
+				return
+			}
+			if ev.Op == fsnotify.Chmod {
+				continue
+			}
+			pending[ev.Name] = true
+			debounce.Reset(eventDebounce)
+		case err, ok := <-ws.watcher.Errors:
+			if !ok {
+				return
+			}
+			// 事件队列溢出等错误可能丢失事件，做一次全量对账
+			logger.Printf("代码索引: 文件监听错误: %v", err)
+			rescan = true
+			debounce.Reset(eventDebounce)
+		case <-debounce.C:
+			if !rescan {
+				rescan = idx.applyEvents(ws, pending)
+			}
+			if rescan {
+				idx.scan(ws)
+				rescan = false
+			}
+			pending = make(map[string]bool)
+		case <-saveTicker.C:
+			if err := idx.Save(); err != nil {
+				logger.Printf("代码索引: 保存失败: %v", err)
+			}
+		}
+	}
+}
+
+// applyEvents 按路径更新索引；返回true表示忽略规则文件有变化，需要全量对账
+func (idx *Index) applyEvents(ws *watchSet, paths map[string]bool) bool {
+	for path := range paths {
+		for _, name := range walker.IgnoreFileNames {
+			if filepath.Base(path) == name {
+				return true
+			}
+		}
+	}
+
+	for path := range paths {
+		// 父目录未被监听说明它被忽略或不在工作区内
+		if !ws.dirs[filepath.Dir(path)] {
+			continue
+		}
+		rel := idx.rel(path)
+		info, err := os.Stat(path)
+		switch {
+		case err != nil:
+			idx.remove(rel)
+			idx.removePrefix(rel)
+			ws.forget(path)
+		case info.IsDir():
+			if !ws.dirs[path] && !skipDirs[rel] && !walker.Excluded(path, true, walker.Options{}) {
+				idx.walkDir(ws, path, nil)
+			}
+		case walker.Excluded(path, false, walker.Options{}):
+			idx.remove(rel)
+		default:
+			idx.indexFile(rel)
+		}
+	}
+	return false
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"lukatincode/coder"
@@ -10,6 +11,9 @@ import (
 )
 
 func main() {
+	skipPermissions := flag.Bool("dangerously-skip-permissions", false, "跳过所有工具调用的权限确认（bypass模式）")
+	resume := flag.String("resume", "", "继续之前的会话并恢复其待办事项：会话ID（log/todos/下的文件名）或 latest")
+	flag.Parse()
 
 	config, err := general.LoadConfig("./LLMConfig.yaml")
 	if err != nil {
@@ -25,6 +29,14 @@ func main() {
 	// 转换为字符串并启动TUI界面
 	content := string(data)
 	lukatinCode := coder.GenLukatinCode(config, content)
+	if *skipPermissions {
+		lukatinCode.EnableBypassPermissions()
+	}
+	if *resume != "" {
+		if err := lukatinCode.ResumeSession(*resume); err != nil {
+			log.Fatalf("恢复会话失败: %v", err)
+		}
+	}
 
 	fmt.Println("正在启动 LukatinCode Bubble Tea TUI 界面...")
 	if err := lukatinCode.StartBubbleTUI(); err != nil {
@@ -0,0 +1,210 @@
+package walker
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// doublestar glob：
+//   - ** 作为完整路径段时匹配零个或多个目录
+//   - * 和 ? 不跨越 /
+//   - [abc]、[a-z]、[!abc]、[^abc] 字符类，\ 转义
+//   - {a,b} 可嵌套，如 *.{go,{proto,pb.go}}
+//   - 不含 / 的模式匹配任意层级的文件名（等价于 **/pattern）
+
+// maxBraceExpansions 大括号展开的上限，防止病态模式组合爆炸
+const maxBraceExpansions = 1024
+
+// GlobSet 编译后的一组glob模式；! 开头的模式用于排除
+type GlobSet struct {
+	include [][]string
+	exclude [][]string
+}
+
+// CompileGlobs 编译模式列表，模式非法时返回错误
+func CompileGlobs(patterns []string) (*GlobSet, error) {
+	set := &GlobSet{}
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		negate := strings.HasPrefix(p, "!")
+		if negate {
+			p = p[1:]
+		}
+		for _, expanded := range ExpandBraces(p) {
+			segments, err := compileGlob(expanded)
+			if err != nil {
+				return nil, err
+			}
+			if negate {
+				set.exclude = append(set.exclude, segments)
+			} else {
+				set.include = append(set.include, segments)
+			}
+		}
+	}
+	if len(set.include) == 0 && len(set.exclude) > 0 {
+		// 只有排除模式时默认包含所有文件
+		set.include = append(set.include, []string{"**"})
+	}
+	return set, nil
+}
+
+// Empty 没有任何模式
+func (g *GlobSet) Empty() bool {
+	return len(g.include) == 0
+}
+
+// Match 判断斜杠分隔的相对路径是否匹配任一包含模式且不匹配任何排除模式
+func (g *GlobSet) Match(rel string) bool {
+	parts := strings.Split(strings.TrimPrefix(rel, "./"), "/")
+	matched := false
+	for _, segments := range g.include {
+		if matchSegments(segments, parts) {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return false
+	}
+	for _, segments := range g.exclude {
+		if matchSegments(segments, parts) {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchGlob 单个模式的便捷匹配，非法模式视为不匹配
+func MatchGlob(pattern, rel string) bool {
+	set, err := CompileGlobs([]string{pattern})
+	if err != nil {
+		return false
+	}
+	return set.Match(rel)
+}
+
+// compileGlob 把单个（已展开大括号的）模式拆成路径段并校验
+func compileGlob(original string) ([]string, error) {
+	pattern := strings.TrimPrefix(original, "./")
+	if !strings.Contains(pattern, "/") {
+		pattern = "**/" + pattern
+	}
+	pattern = strings.TrimPrefix(pattern, "/")
+	var segments []string
+	for _, seg := range strings.Split(pattern, "/") {
+		if seg == "" {
+			continue
+		}
+		// 连续的 ** 等价于一个
+		if seg == "**" && len(segments) > 0 && segments[len(segments)-1] == "**" {
+			continue
+		}
+		seg = normalizeClass(seg)
+		if _, err := path.Match(seg, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob pattern %q: %v", original, err)
+		}
+		segments = append(segments, seg)
+	}
+	return segments, nil
+}
+
+// normalizeClass 把 [!...] 转换为 path.Match 支持的 [^...]
+func normalizeClass(seg string) string {
+	if !strings.Contains(seg, "[!") {
+		return seg
+	}
+	var sb strings.Builder
+	for i := 0; i < len(seg); i++ {
+		c := seg[i]
+		if c == '\\' && i+1 < len(seg) {
+			sb.WriteByte(c)
+			sb.WriteByte(seg[i+1])
+			i++
+			continue
+		}
+		if c == '[' && i+1 < len(seg) && seg[i+1] == '!' {
+			sb.WriteString("[^")
+			i++
+			continue
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String()
+}
+
+// ExpandBraces 展开模式中所有（包括嵌套的）{a,b}，结果去重并保持顺序；没有大括号时返回原模式
+func ExpandBraces(pattern string) []string {
+	var out []string
+	seen := make(map[string]bool)
+	expandBraces(pattern, 0, func(p string) bool {
+		if !seen[p] {
+			seen[p] = true
+			out = append(out, p)
+		}
+		return len(out) < maxBraceExpansions
+	})
+	return out
+}
+
+// expandBraces 展开from之后第一个顶层的大括号组，对每个结果递归；emit 返回false时停止
+func expandBraces(p string, from int, emit func(string) bool) bool {
+	depth, start := 0, -1
+	for i := from; i < len(p); i++ {
+		switch p[i] {
+		case '\\':
+			i++
+		case '{':
+			if depth == 0 {
+				start = i
+			}
+			depth++
+		case '}':
+			if depth == 0 {
+				continue
+			}
+			depth--
+			if depth > 0 {
+				continue
+			}
+			alternatives := splitAlternatives(p[start+1 : i])
+			if len(alternatives) < 2 {
+				// {a} 不是可选项组，大括号按字面处理，继续展开其内部和后面的部分
+				return expandBraces(p, start+1, emit)
+			}
+			for _, alt := range alternatives {
+				if !expandBraces(p[:start]+alt+p[i+1:], start, emit) {
+					return false
+				}
+			}
+			return true
+		}
+	}
+	return emit(p)
+}
+
+// splitAlternatives 在顶层逗号处拆分大括号内容
+func splitAlternatives(s string) []string {
+	var parts []string
+	depth, last := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[last:i])
+				last = i + 1
+			}
+		}
+	}
+	return append(parts, s[last:])
+}
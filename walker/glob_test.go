@@ -0,0 +1,110 @@
+package walker
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGlobSetMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		path     string
+		want     bool
+	}{
+		// ** 在开头、中间、结尾
+		{"leading ** at root", []string{"**/*.go"}, "main.go", true},
+		{"leading ** nested", []string{"**/*.go"}, "a/b/c/main.go", true},
+		{"leading ** wrong ext", []string{"**/*.go"}, "a/b/main.rs", false},
+		{"middle ** zero dirs", []string{"src/**/main.go"}, "src/main.go", true},
+		{"middle ** many dirs", []string{"src/**/main.go"}, "src/a/b/main.go", true},
+		{"middle ** wrong root", []string{"src/**/main.go"}, "lib/a/main.go", false},
+		{"trailing ** file", []string{"vendor/**"}, "vendor/x.go", true},
+		{"trailing ** deep", []string{"vendor/**"}, "vendor/a/b/x.go", true},
+		{"trailing ** other dir", []string{"vendor/**"}, "src/vendor.go", false},
+		{"repeated ** collapses", []string{"a/**/**/b"}, "a/b", true},
+
+		// 不含 / 的模式匹配任意层级，* 和 ? 不跨越 /
+		{"basename pattern nested", []string{"*.go"}, "a/b/main.go", true},
+		{"star does not cross slash", []string{"src/*.go"}, "src/a/main.go", false},
+		{"question mark single char", []string{"a?.txt"}, "ab.txt", true},
+		{"question mark not slash", []string{"a/?/c"}, "a/b/c", true},
+		{"leading ./ ignored", []string{"./src/*.go"}, "src/main.go", true},
+		{"leading / anchors", []string{"/src/*.go"}, "src/main.go", true},
+
+		// 大括号，包括嵌套
+		{"braces first", []string{"*.{go,rs}"}, "main.go", true},
+		{"braces second", []string{"*.{go,rs}"}, "main.rs", true},
+		{"braces miss", []string{"*.{go,rs}"}, "main.py", false},
+		{"nested braces outer", []string{"x.{a,{b,c}}"}, "x.a", true},
+		{"nested braces inner", []string{"x.{a,{b,c}}"}, "x.c", true},
+		{"nested braces miss", []string{"x.{a,{b,c}}"}, "x.d", false},
+		{"nested braces with dot", []string{"*.{go,{proto,pb.go}}"}, "api/x.pb.go", true},
+		{"braces across segments", []string{"{cmd,internal}/**/*.go"}, "internal/a/x.go", true},
+
+		// 字符类和取反
+		{"class match", []string{"file[abc].txt"}, "fileb.txt", true},
+		{"class miss", []string{"file[abc].txt"}, "filed.txt", false},
+		{"range match", []string{"v[0-9].go"}, "v7.go", true},
+		{"range miss", []string{"v[0-9].go"}, "vx.go", false},
+		{"bang negation excludes", []string{"file[!abc].txt"}, "filea.txt", false},
+		{"bang negation includes", []string{"file[!abc].txt"}, "filed.txt", true},
+		{"caret negation excludes", []string{"file[^abc].txt"}, "fileb.txt", false},
+		{"caret negation includes", []string{"file[^abc].txt"}, "filez.txt", true},
+
+		// ! 排除
+		{"exclude removes match", []string{"**/*.go", "!**/*_test.go"}, "a/x_test.go", false},
+		{"exclude keeps others", []string{"**/*.go", "!**/*_test.go"}, "a/x.go", true},
+		{"exclude only includes rest", []string{"!vendor/**"}, "src/x.go", true},
+		{"exclude only drops excluded", []string{"!vendor/**"}, "vendor/x.go", false},
+		{"exclude with braces", []string{"*", "!*.{log,tmp}"}, "a/b.tmp", false},
+
+		// 转义的元字符
+		{"escaped star literal", []string{`a\*b`}, "a*b", true},
+		{"escaped star not wildcard", []string{`a\*b`}, "axb", false},
+		{"escaped question literal", []string{`what\?.md`}, "what?.md", true},
+		{"escaped question not wildcard", []string{`what\?.md`}, "whatx.md", false},
+		{"escaped bracket literal", []string{`\[id\].tsx`}, "pages/[id].tsx", true},
+		{"escaped bracket not class", []string{`\[id\].tsx`}, "pages/i.tsx", false},
+		{"escaped brace literal", []string{`\{a,b\}.txt`}, "{a,b}.txt", true},
+		{"escaped brace not expanded", []string{`\{a,b\}.txt`}, "a.txt", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set, err := CompileGlobs(tt.patterns)
+			if err != nil {
+				t.Fatalf("CompileGlobs(%q) error: %v", tt.patterns, err)
+			}
+			if got := set.Match(tt.path); got != tt.want {
+				t.Errorf("CompileGlobs(%q).Match(%q) = %v, want %v", tt.patterns, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileGlobsInvalid(t *testing.T) {
+	for _, pattern := range []string{"[abc", "src/[", `a/b\`} {
+		if _, err := CompileGlobs([]string{pattern}); err == nil {
+			t.Errorf("CompileGlobs(%q) succeeded, want error", pattern)
+		}
+	}
+}
+
+func TestExpandBraces(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"*.go", []string{"*.go"}},
+		{"*.{go,rs}", []string{"*.go", "*.rs"}},
+		{"{a,{b,c}}", []string{"a", "b", "c"}},
+		{"{a,b}/{c,d}", []string{"a/c", "a/d", "b/c", "b/d"}},
+		{"{a,a,b}", []string{"a", "b"}},
+		{`\{a,b\}`, []string{`\{a,b\}`}},
+	}
+	for _, tt := range tests {
+		if got := ExpandBraces(tt.pattern); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ExpandBraces(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
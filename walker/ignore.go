@@ -0,0 +1,205 @@
+package walker
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// 忽略规则：语义与git一致——子目录的规则在父目录之后生效，同一文件中后出现的规则优先，
+// ! 开头的规则重新包含之前被忽略的路径，以 / 结尾的规则只匹配目录
+
+// IgnoreFileNames 每个目录中读取的忽略文件，按优先级从低到高
+var IgnoreFileNames = []string{".gitignore", ".ignore", ".lukatinignore"}
+
+// LukatinIgnoreFile 项目专用的忽略文件名
+const LukatinIgnoreFile = ".lukatinignore"
+
+type ignoreRule struct {
+	base     string   // 规则所在目录（绝对路径，斜杠分隔）
+	segments []string // 按 / 拆分后的模式
+	negate   bool
+	dirOnly  bool
+	anchored bool // 模式中含 / 时相对base匹配，否则匹配任意层级的名称
+}
+
+// Matcher 按顺序生效的一组忽略规则，创建后不再修改，子目录在其基础上追加规则
+type Matcher struct {
+	rules []ignoreRule
+}
+
+// ForDir 加载从仓库根目录（含.git的最近祖先目录，找不到时为当前工作目录）到dir的所有忽略规则
+func ForDir(dir string) *Matcher {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return &Matcher{}
+	}
+
+	// chain 从dir向上直到仓库根目录
+	var chain []string
+	top := ""
+	for d := abs; ; {
+		chain = append(chain, d)
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil {
+			top = d
+			break
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			break
+		}
+		d = parent
+	}
+	if top == "" {
+		// 不在git仓库中：只向上追溯到当前工作目录
+		limit := 0
+		if cwd, err := os.Getwd(); err == nil {
+			for i, d := range chain {
+				if d == cwd {
+					limit = i
+					break
+				}
+			}
+		}
+		chain = chain[:limit+1]
+	}
+
+	m := &Matcher{}
+	if top != "" {
+		m.rules = append(m.rules, parseIgnoreFile(filepath.Join(top, ".git", "info", "exclude"), top)...)
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		m = m.WithDir(chain[i])
+	}
+	return m
+}
+
+// WithDir 返回追加了dir中忽略文件规则的Matcher；dir中没有忽略文件时返回自身
+func (m *Matcher) WithDir(dir string) *Matcher {
+	var added []ignoreRule
+	for _, name := range IgnoreFileNames {
+		added = append(added, parseIgnoreFile(filepath.Join(dir, name), dir)...)
+	}
+	if len(added) == 0 {
+		return m
+	}
+	rules := make([]ignoreRule, 0, len(m.rules)+len(added))
+	rules = append(rules, m.rules...)
+	rules = append(rules, added...)
+	return &Matcher{rules: rules}
+}
+
+// Ignored 判断绝对路径p是否被忽略；只检查p本身，不检查其父目录（遍历时父目录已先行过滤）
+func (m *Matcher) Ignored(p string, isDir bool) bool {
+	if m == nil || len(m.rules) == 0 {
+		return false
+	}
+	p = filepath.ToSlash(p)
+	ignored := false
+	for i := range m.rules {
+		if m.rules[i].match(p, isDir) {
+			ignored = !m.rules[i].negate
+		}
+	}
+	return ignored
+}
+
+func (r *ignoreRule) match(p string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	prefix := r.base
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	if !strings.HasPrefix(p, prefix) {
+		return false
+	}
+	rel := p[len(prefix):]
+	if rel == "" {
+		return false
+	}
+	if r.anchored {
+		return matchSegments(r.segments, strings.Split(rel, "/"))
+	}
+	matched, _ := path.Match(r.segments[0], path.Base(rel))
+	return matched
+}
+
+// matchSegments 按路径段匹配，** 匹配零个或多个段
+func matchSegments(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(pattern[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
+
+// parseIgnoreFile 读取gitignore格式的文件，文件不存在时返回nil
+func parseIgnoreFile(file, dir string) []ignoreRule {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	base := filepath.ToSlash(dir)
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if r, ok := parseIgnoreLine(scanner.Text(), base); ok {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+func parseIgnoreLine(line, base string) (ignoreRule, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	// 行尾空格被忽略，除非用 \ 转义
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	r := ignoreRule{base: base}
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+	r.anchored = strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	for _, seg := range strings.Split(line, "/") {
+		r.segments = append(r.segments, normalizeClass(seg))
+	}
+	return r, true
+}
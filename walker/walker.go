@@ -0,0 +1,139 @@
+// Package walker 是Grep、Glob、LS和代码索引共用的目录遍历：
+// 遵循嵌套的 .gitignore、.ignore 和项目的 .lukatinignore，默认跳过隐藏文件和目录。
+package walker
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Options 遍历选项
+type Options struct {
+	IncludeHidden bool // 包含以 . 开头的文件和目录（.git 始终跳过）
+}
+
+// alwaysSkip 无论忽略规则如何都不进入的目录
+var alwaysSkip = map[string]bool{".git": true, "node_modules": true}
+
+// WalkFunc 对每个未被忽略的条目调用，path与root的形式一致（root为相对路径时也是相对路径）。
+// 对目录返回 filepath.SkipDir 跳过该目录，返回其他错误终止遍历。
+type WalkFunc func(path string, d fs.DirEntry) error
+
+// Walk 遍历root，跳过被忽略的文件和目录；root是文件时只对它本身调用fn
+func Walk(root string, opts Options, fn WalkFunc) error {
+	info, err := os.Stat(root)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fn(root, fs.FileInfoToDirEntry(info))
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+
+	type dirState struct {
+		abs     string
+		matcher *Matcher
+	}
+	root = filepath.Clean(root)
+	dirs := map[string]dirState{root: {abs: absRoot, matcher: ForDir(absRoot)}}
+
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if p == root {
+			return fn(p, d)
+		}
+		parent, ok := dirs[filepath.Dir(p)]
+		if !ok {
+			return nil
+		}
+
+		name := d.Name()
+		isDir := d.IsDir()
+		abs := filepath.Join(parent.abs, name)
+		if excluded(parent.matcher, abs, name, isDir, opts) {
+			if isDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if isDir {
+			dirs[p] = dirState{abs: abs, matcher: parent.matcher.WithDir(abs)}
+		}
+		return fn(p, d)
+	})
+}
+
+// ListDir 读取dir的直接子项并过滤掉被忽略的条目
+func ListDir(dir string, opts Options) ([]fs.DirEntry, error) {
+	d, err := OpenDir(dir, opts)
+	if err != nil {
+		return nil, err
+	}
+	return d.Entries()
+}
+
+// Dir 带忽略规则的目录，用于逐层读取（如LS的树形输出），子目录通过Sub获得以复用已加载的规则
+type Dir struct {
+	Path    string
+	abs     string
+	matcher *Matcher
+	opts    Options
+}
+
+// OpenDir 加载dir及其祖先目录的忽略规则
+func OpenDir(dir string, opts Options) (*Dir, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Dir{Path: dir, abs: abs, matcher: ForDir(abs), opts: opts}, nil
+}
+
+// Entries 读取直接子项并过滤掉被忽略的条目
+func (d *Dir) Entries() ([]fs.DirEntry, error) {
+	entries, err := os.ReadDir(d.Path)
+	if err != nil {
+		return nil, err
+	}
+	kept := entries[:0]
+	for _, entry := range entries {
+		if !excluded(d.matcher, filepath.Join(d.abs, entry.Name()), entry.Name(), entry.IsDir(), d.opts) {
+			kept = append(kept, entry)
+		}
+	}
+	return kept, nil
+}
+
+// Sub 返回子目录name，追加其中的忽略规则
+func (d *Dir) Sub(name string) *Dir {
+	abs := filepath.Join(d.abs, name)
+	return &Dir{Path: filepath.Join(d.Path, name), abs: abs, matcher: d.matcher.WithDir(abs), opts: d.opts}
+}
+
+func excluded(m *Matcher, abs, name string, isDir bool, opts Options) bool {
+	if isDir && alwaysSkip[name] {
+		return true
+	}
+	if !opts.IncludeHidden && strings.HasPrefix(name, ".") {
+		return true
+	}
+	return m.Ignored(abs, isDir)
+}
+
+// Excluded 判断单个路径是否会被Walk跳过，用于处理文件系统事件等不经过遍历的场景；
+// 只检查path本身，调用方需保证其父目录未被忽略
+func Excluded(path string, isDir bool, opts Options) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	return excluded(ForDir(filepath.Dir(abs)), abs, filepath.Base(abs), isDir, opts)
+}